@@ -0,0 +1,75 @@
+// Package apps implements the apps command
+package apps
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/getoutreach/devenv/pkg/appregistry"
+	"github.com/getoutreach/devenv/pkg/cmdutil"
+	"github.com/getoutreach/devenv/pkg/kube"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+//nolint:gochecknoglobals
+var (
+	appsLongDesc = `
+		apps lists the applications deployed into your developer environment with deploy-app, what they were deployed from and when.
+	`
+	appsExample = `
+		# List the deployed applications
+		devenv apps
+	`
+)
+
+type Options struct {
+	log      logrus.FieldLogger
+	out      io.Writer
+	registry *appregistry.Registry
+}
+
+func NewOptions(log logrus.FieldLogger) (*Options, error) {
+	k, err := kube.GetKubeClient()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create kubernetes client")
+	}
+
+	return &Options{
+		log:      log,
+		out:      os.Stdout,
+		registry: appregistry.New(k),
+	}, nil
+}
+
+func NewCmdApps(log logrus.FieldLogger) *cli.Command {
+	return &cli.Command{
+		Name:        "apps",
+		Usage:       "List the applications deployed into the developer environment",
+		Description: cmdutil.NewDescription(appsLongDesc, appsExample),
+		Action: func(c *cli.Context) error {
+			o, err := NewOptions(log)
+			if err != nil {
+				return err
+			}
+
+			return o.Run(c.Context)
+		},
+	}
+}
+
+func (o *Options) Run(ctx context.Context) error {
+	entries, err := o.registry.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(entries) == 0 {
+		o.log.Info("No applications have been deployed")
+		return nil
+	}
+
+	return appregistry.PrintEntries(o.out, entries)
+}
@@ -0,0 +1,170 @@
+// Package cache implements the cache command
+package cache
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/getoutreach/devenv/pkg/cmdutil"
+	"github.com/getoutreach/devenv/pkg/repocache"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+//nolint:gochecknoglobals
+var (
+	cacheLongDesc = `
+		Manage the cache of application repositories used by deploy-app. Repositories are fetched into the cache
+		once, and only updated afterwards, so already cached versions can be deployed while offline.
+	`
+	cacheExample = `
+		# List the cached repositories
+		devenv cache list
+
+		# Remove all cached repositories
+		devenv cache clean
+
+		# Remove repositories that haven't been used in a week, and shrink the cache to 5Gi
+		devenv cache clean --max-age 168h --max-size 5Gi
+	`
+
+	// legacyCachePath is the path, relative to the user's home directory,
+	// repositories used to be cloned into
+	legacyCachePath = filepath.Join(".outreach", ".cache", "dev-environment", "deploy-app-v2")
+)
+
+type Options struct {
+	log   logrus.FieldLogger
+	out   io.Writer
+	cache *repocache.Cache
+
+	// MaxAge is how long a repository can go unused before it's removed
+	MaxAge time.Duration
+
+	// MaxSize is the size, in bytes, the cache is shrunk to
+	MaxSize int64
+}
+
+func NewOptions(log logrus.FieldLogger) (*Options, error) {
+	cache, err := repocache.NewDefault()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find repository cache")
+	}
+
+	return &Options{
+		log:   log,
+		out:   os.Stdout,
+		cache: cache,
+	}, nil
+}
+
+func NewCmdCache(log logrus.FieldLogger) *cli.Command {
+	var o *Options
+
+	return &cli.Command{
+		Name:        "cache",
+		Usage:       "Manage the cache of application repositories",
+		Description: cmdutil.NewDescription(cacheLongDesc, cacheExample),
+		Before: func(c *cli.Context) error {
+			var err error
+			o, err = NewOptions(log)
+			return err
+		},
+		Subcommands: []*cli.Command{
+			{
+				Name:  "list",
+				Usage: "List the cached repositories",
+				Action: func(c *cli.Context) error {
+					return o.List()
+				},
+			},
+			{
+				Name:  "clean",
+				Usage: "Remove cached repositories, by default all of them",
+				Flags: []cli.Flag{
+					&cli.DurationFlag{
+						Name:  "max-age",
+						Usage: "Only remove repositories that haven't been used for this long, e.g. 168h",
+					},
+					&cli.StringFlag{
+						Name:  "max-size",
+						Usage: "Remove the least recently used repositories until the cache is smaller than this, e.g. 5Gi",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					o.MaxAge = c.Duration("max-age")
+					if maxSize := c.String("max-size"); maxSize != "" {
+						q, err := resource.ParseQuantity(maxSize)
+						if err != nil {
+							return errors.Wrap(err, "failed to parse --max-size")
+						}
+						o.MaxSize = q.Value()
+					}
+
+					return o.Clean()
+				},
+			},
+		},
+	}
+}
+
+// List prints the cached repositories
+func (o *Options) List() error {
+	entries, err := o.cache.List()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(o.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REPOSITORY\tSIZE\tLAST USED")
+
+	for i := range entries {
+		e := &entries[i]
+		fmt.Fprintf(w, "%s\t%s\t%s\n", e.Name, resource.NewQuantity(e.Size, resource.BinarySI), e.LastUsed.Format(time.RFC822))
+	}
+
+	return w.Flush()
+}
+
+// Clean removes repositories from the cache, if neither MaxAge or MaxSize
+// are set all repositories are removed
+func (o *Options) Clean() error {
+	if o.MaxAge == 0 && o.MaxSize == 0 {
+		if err := o.cache.Clean(); err != nil {
+			return err
+		}
+
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return errors.Wrap(err, "failed to read user's home dir")
+		}
+
+		if err := os.RemoveAll(filepath.Join(homeDir, legacyCachePath)); err != nil {
+			return errors.Wrap(err, "failed to remove legacy repository cache")
+		}
+
+		o.log.Info("Removed all cached repositories")
+		return nil
+	}
+
+	removed, err := o.cache.Prune(o.MaxAge, o.MaxSize)
+	if err != nil {
+		return err
+	}
+
+	for i := range removed {
+		o.log.WithField("repository", removed[i].Name).Info("Removed cached repository")
+	}
+
+	if len(removed) == 0 {
+		o.log.Info("No cached repositories were removed")
+	}
+
+	return nil
+}
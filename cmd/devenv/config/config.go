@@ -0,0 +1,200 @@
+// Package config implements the config command
+package config
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/getoutreach/devenv/pkg/cmdutil"
+	devenvconfig "github.com/getoutreach/devenv/pkg/config"
+	"github.com/getoutreach/devenv/pkg/kubernetesruntime"
+	"github.com/getoutreach/gobox/pkg/box"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+//nolint:gochecknoglobals
+var (
+	configLongDesc = `
+		Inspect and change devenv settings. Settings are stored in the devenv config, and settings starting with
+		box. override the values of the box configuration. Every setting can also be overridden by an environment
+		variable, e.g. DEVENV_BOX_ENABLED_RUNTIMES for box.enabledRuntimes, which takes precedence over both.
+	`
+	configExample = `
+		# List all settings and where their values came from
+		devenv config list
+
+		# Only enable the kind and loft runtimes
+		devenv config set box.enabledRuntimes kind,loft
+
+		# Get the runtimes that are enabled
+		devenv config get box.enabledRuntimes
+
+		# Use the runtimes from the box configuration again
+		devenv config unset box.enabledRuntimes
+	`
+)
+
+type Options struct {
+	log logrus.FieldLogger
+	out io.Writer
+
+	// b is the box configuration without any overrides applied
+	b *box.Config
+
+	// runtimeNames returns the names of all kubernetes runtimes, used to
+	// validate settings that refer to a runtime. It's only called when a
+	// setting is changed, as it discovers runtime plugins.
+	runtimeNames func() []string
+}
+
+func NewOptions(log logrus.FieldLogger) (*Options, error) {
+	b, err := box.LoadBox()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load box configuration")
+	}
+
+	return &Options{
+		log:          log,
+		out:          os.Stdout,
+		b:            b,
+		runtimeNames: kubernetesruntime.GetRuntimeNames,
+	}, nil
+}
+
+func NewCmdConfig(log logrus.FieldLogger) *cli.Command {
+	var o *Options
+
+	return &cli.Command{
+		Name:        "config",
+		Usage:       "Inspect and change devenv settings",
+		Description: cmdutil.NewDescription(configLongDesc, configExample),
+		Before: func(c *cli.Context) error {
+			var err error
+			o, err = NewOptions(log)
+			return err
+		},
+		Subcommands: []*cli.Command{
+			{
+				Name:      "get",
+				Usage:     "Print the value of a setting",
+				ArgsUsage: "<key>",
+				Action: func(c *cli.Context) error {
+					return o.Get(c.Context, c.Args().First())
+				},
+			},
+			{
+				Name:      "set",
+				Usage:     "Change the value of a setting",
+				ArgsUsage: "<key> <value>",
+				Action: func(c *cli.Context) error {
+					if c.NArg() != 2 {
+						return fmt.Errorf("expected a key and a value")
+					}
+					return o.Set(c.Context, c.Args().Get(0), c.Args().Get(1))
+				},
+			},
+			{
+				Name:      "unset",
+				Usage:     "Remove the value of a setting from the devenv config",
+				ArgsUsage: "<key>",
+				Action: func(c *cli.Context) error {
+					return o.Set(c.Context, c.Args().First(), "")
+				},
+			},
+			{
+				Name:  "list",
+				Usage: "List all settings, their values and where they came from",
+				Action: func(c *cli.Context) error {
+					return o.List(c.Context)
+				},
+			},
+		},
+	}
+}
+
+// getSetting returns a setting by key, or an error listing the
+// available keys
+func getSetting(key string) (*devenvconfig.Setting, error) {
+	if key == "" {
+		return nil, fmt.Errorf("missing key")
+	}
+
+	if s := devenvconfig.GetSetting(key); s != nil {
+		return s, nil
+	}
+
+	keys := make([]string, 0)
+	for _, s := range devenvconfig.Settings() {
+		keys = append(keys, s.Key)
+	}
+	sort.Strings(keys)
+
+	return nil, fmt.Errorf("unknown key '%s', available keys: %v", key, keys)
+}
+
+// Get prints the effective value of a setting
+func (o *Options) Get(ctx context.Context, key string) error {
+	s, err := getSetting(key)
+	if err != nil {
+		return err
+	}
+
+	conf, err := devenvconfig.LoadConfig(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to load devenv config")
+	}
+
+	v, _ := s.Resolve(conf, o.b)
+	fmt.Fprintln(o.out, v)
+	return nil
+}
+
+// Set validates and stores the value of a setting in the devenv config,
+// an empty value removes it
+func (o *Options) Set(ctx context.Context, key, value string) error {
+	s, err := getSetting(key)
+	if err != nil {
+		return err
+	}
+
+	err = devenvconfig.UpdateConfig(ctx, func(conf *devenvconfig.Config) error {
+		if value == "" {
+			s.Unset(conf)
+			return nil
+		}
+		return s.Set(conf, value, o.runtimeNames())
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to update devenv config")
+	}
+
+	if v := os.Getenv(s.EnvVar()); v != "" {
+		o.log.WithField("value", v).Warnf("%s is set, it takes precedence over the devenv config", s.EnvVar())
+	}
+
+	return nil
+}
+
+// List prints all settings, their values and where they came from
+func (o *Options) List(ctx context.Context) error {
+	conf, err := devenvconfig.LoadConfig(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to load devenv config")
+	}
+
+	w := tabwriter.NewWriter(o.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tTYPE\tVALUE\tSOURCE\tENV")
+
+	for _, s := range devenvconfig.Settings() {
+		v, src := s.Resolve(conf, o.b)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", s.Key, s.Type, v, src, s.EnvVar())
+	}
+
+	return w.Flush()
+}
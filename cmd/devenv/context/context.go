@@ -3,8 +3,11 @@ package context
 import (
 	gocontext "context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/getoutreach/devenv/pkg/cmdutil"
@@ -23,11 +26,32 @@ import (
 type Options struct {
 	log            logrus.FieldLogger
 	DesiredContext string
+
+	// b and runtimes are loaded from the box when Run is called, unless
+	// provided by NewOptionsWithRuntimes
+	b        *box.Config
+	runtimes []kubernetesruntime.Runtime
+
+	// out is where contexts are displayed
+	out io.Writer
 }
 
 func NewOptions(log logrus.FieldLogger) *Options {
 	return &Options{
 		log: log,
+		out: os.Stdout,
+	}
+}
+
+// NewOptionsWithRuntimes creates options that only look for contexts
+// in the provided runtimes, instead of those enabled in the box, and
+// display them to out.
+func NewOptionsWithRuntimes(log logrus.FieldLogger, b *box.Config, runtimes []kubernetesruntime.Runtime, out io.Writer) *Options {
+	return &Options{
+		log:      log,
+		b:        b,
+		runtimes: runtimes,
+		out:      out,
 	}
 }
 
@@ -53,8 +77,8 @@ Display all available contexts:
 }
 
 func (o *Options) displayContexts(_ gocontext.Context, conf *config.Config, clusters []*kubernetesruntime.RuntimeCluster) error {
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "CURRENT\tCLUSTER NAME\tRUNTIME\tCONTEXT NAME")
+	w := tabwriter.NewWriter(o.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CURRENT\tCLUSTER NAME\tRUNTIME\tCONTEXT NAME\tDETAILS")
 
 	for _, c := range clusters {
 		var current string
@@ -62,13 +86,38 @@ func (o *Options) displayContexts(_ gocontext.Context, conf *config.Config, clus
 			current = "*"
 		}
 
-		fmt.Fprintln(w, current+"\t"+c.Name+"\t"+c.RuntimeName+"\t"+c.RuntimeName+":"+c.Name)
+		name := c.Name
+		if c.Shared {
+			name += " (shared)"
+		}
+
+		fmt.Fprintln(w, current+"\t"+name+"\t"+c.RuntimeName+"\t"+c.RuntimeName+":"+c.Name+"\t"+formatDetails(c.Details))
 	}
 
 	return w.Flush()
 }
 
-func (o *Options) setContext(ctx gocontext.Context, conf *config.Config, clusters []*kubernetesruntime.RuntimeCluster) error { //nolint:funlen
+// formatDetails returns the details of a cluster as a sorted list
+// of key=value pairs, empty values are omitted.
+func formatDetails(details map[string]string) string {
+	keys := make([]string, 0, len(details))
+	for k, v := range details {
+		if v == "" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + details[k]
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func (o *Options) setContext(ctx gocontext.Context, clusters []*kubernetesruntime.RuntimeCluster) error { //nolint:funlen
 	newConfig := &config.Config{CurrentContext: o.DesiredContext}
 
 	newRuntime, newClusterName := newConfig.ParseContext()
@@ -89,7 +138,6 @@ func (o *Options) setContext(ctx gocontext.Context, conf *config.Config, cluster
 	}
 
 	o.log.Infof("Setting context to %s", o.DesiredContext)
-	conf.CurrentContext = o.DesiredContext
 
 	// Create a Kubernetes client for the new context
 	ccc := clientcmd.NewDefaultClientConfig(*cluster.KubeConfig, &clientcmd.ConfigOverrides{})
@@ -122,7 +170,11 @@ func (o *Options) setContext(ctx gocontext.Context, conf *config.Config, cluster
 		return errors.Wrap(err, "failed to run script to setup /etc/hosts to point to context")
 	}
 
-	if err := config.SaveConfig(ctx, conf); err != nil { //nolint:govet // Why: err shadow
+	err = config.UpdateConfig(ctx, func(conf *config.Config) error {
+		conf.CurrentContext = o.DesiredContext
+		return nil
+	})
+	if err != nil {
 		return errors.Wrap(err, "failed to save devenv config")
 	}
 
@@ -130,18 +182,25 @@ func (o *Options) setContext(ctx gocontext.Context, conf *config.Config, cluster
 }
 
 func (o *Options) Run(ctx gocontext.Context) error {
-	b, err := box.LoadBox()
-	if err != nil {
-		return err
+	b := o.b
+	if b == nil {
+		var err error
+		b, err = config.LoadBox()
+		if err != nil {
+			return err
+		}
 	}
 
 	conf, err := config.LoadConfig(ctx)
 	if err != nil {
-		conf = &config.Config{}
+		conf = config.NewConfig()
 		o.log.WithError(err).Warn("failed to read devenv configuration")
 	}
 
-	runtimes := kubernetesruntime.GetEnabledRuntimes(b)
+	runtimes := o.runtimes
+	if runtimes == nil {
+		runtimes = kubernetesruntime.GetEnabledRuntimes(b)
+	}
 
 	clusters := make([]*kubernetesruntime.RuntimeCluster, 0)
 	for _, r := range runtimes {
@@ -161,7 +220,7 @@ func (o *Options) Run(ctx gocontext.Context) error {
 	}
 
 	if o.DesiredContext != "" {
-		return o.setContext(ctx, conf, clusters)
+		return o.setContext(ctx, clusters)
 	}
 
 	return o.displayContexts(ctx, conf, clusters)
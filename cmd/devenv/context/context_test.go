@@ -0,0 +1,81 @@
+package context
+
+import (
+	"bytes"
+	gocontext "context"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/getoutreach/devenv/pkg/config"
+	"github.com/getoutreach/devenv/pkg/kubernetesruntime"
+	"github.com/getoutreach/devenv/pkg/kubernetesruntime/fake"
+	"github.com/getoutreach/gobox/pkg/box"
+	"github.com/sirupsen/logrus"
+)
+
+func TestOptions_Run(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if err := config.SaveConfig(gocontext.Background(), &config.Config{CurrentContext: "kind:dev-environment"}); err != nil {
+		t.Fatal(err)
+	}
+
+	log := logrus.New()
+	log.Out = ioutil.Discard
+
+	kind := fake.NewProvisionedRuntime("kind")
+	loft := fake.NewRuntime("loft")
+	loft.Clusters = []*kubernetesruntime.RuntimeCluster{
+		{RuntimeName: "loft", Name: "jane-devenv", Shared: true, Details: map[string]string{"space": "jane", "cluster": ""}},
+	}
+	broken := fake.NewProvisionedRuntime("broken")
+	broken.Errors["PreCreate"] = errors.New("failed to authenticate")
+
+	tests := []struct {
+		name           string
+		desiredContext string
+		want           []string
+		wantErr        bool
+	}{
+		{
+			name: "should display contexts of all runtimes",
+			want: []string{
+				"*  dev-environment  kind  kind:dev-environment",
+				"   jane-devenv (shared)  loft  loft:jane-devenv  space=jane",
+			},
+		},
+		{
+			name:           "should fail to switch to an unknown context",
+			desiredContext: "broken:dev-environment",
+			wantErr:        true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			runtimes := []kubernetesruntime.Runtime{kind, loft, broken}
+
+			o := NewOptionsWithRuntimes(log, &box.Config{}, runtimes, &out)
+			o.DesiredContext = tt.desiredContext
+
+			if err := o.Run(gocontext.Background()); (err != nil) != tt.wantErr {
+				t.Fatalf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			for _, want := range tt.want {
+				var found bool
+				for _, line := range lines {
+					if strings.Join(strings.Fields(line), " ") == strings.Join(strings.Fields(want), " ") {
+						found = true
+					}
+				}
+				if !found {
+					t.Errorf("Run() output missing %q, got:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+}
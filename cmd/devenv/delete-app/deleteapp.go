@@ -3,14 +3,15 @@ package deleteapp
 import (
 	"context"
 	"fmt"
+	"os"
 
 	"github.com/getoutreach/devenv/internal/vault"
 	"github.com/getoutreach/devenv/pkg/app"
+	"github.com/getoutreach/devenv/pkg/appregistry"
 	"github.com/getoutreach/devenv/pkg/cmdutil"
 	"github.com/getoutreach/devenv/pkg/config"
 	"github.com/getoutreach/devenv/pkg/devenvutil"
 	"github.com/getoutreach/devenv/pkg/kube"
-	"github.com/getoutreach/gobox/pkg/box"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
@@ -32,6 +33,9 @@ var (
 
 		# Delete a local application in the developer environment
 		devenv deploy-app ./outreach-accounts
+
+		# Print what deleting an application would do, without deleting it
+		devenv delete-app --dry-run <appName>
 	`
 )
 
@@ -41,6 +45,10 @@ type Options struct {
 	conf *rest.Config
 
 	App string
+
+	// DryRun denotes that what deleting the application would do should
+	// be printed, instead of deleting it
+	DryRun bool
 }
 
 func NewOptions(log logrus.FieldLogger) (*Options, error) {
@@ -61,7 +69,12 @@ func NewCmdDeleteApp(log logrus.FieldLogger) *cli.Command {
 		Name:        "delete-app",
 		Usage:       "Delete an application in the developer environment",
 		Description: cmdutil.NewDescription(deployAppLongDesc, deployAppExample),
-		Flags:       []cli.Flag{},
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Print what deleting the application would do, without deleting it",
+			},
+		},
 		Action: func(c *cli.Context) error {
 			if c.Args().Len() == 0 {
 				return fmt.Errorf("missing application")
@@ -72,13 +85,14 @@ func NewCmdDeleteApp(log logrus.FieldLogger) *cli.Command {
 			}
 
 			o.App = c.Args().First()
+			o.DryRun = c.Bool("dry-run")
 			return o.Run(c.Context)
 		},
 	}
 }
 
 func (o *Options) Run(ctx context.Context) error {
-	b, err := box.LoadBox()
+	b, err := config.LoadBox()
 	if err != nil {
 		return errors.Wrap(err, "failed to load box configuration")
 	}
@@ -92,6 +106,7 @@ func (o *Options) Run(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	kr.Configure(o.log, b)
 
 	if b.DeveloperEnvironmentConfig.VaultConfig.Enabled {
 		if err := vault.EnsureLoggedIn(ctx, o.log, b, o.k); err != nil {
@@ -99,5 +114,24 @@ func (o *Options) Run(ctx context.Context) error {
 		}
 	}
 
-	return app.Delete(ctx, o.log, o.k, o.conf, o.App, kr.GetConfig())
+	// Delete applications from what they were deployed from, e.g. the
+	// same version or local path
+	ref := o.App
+	if e, err := appregistry.New(o.k).Get(ctx, o.App); err != nil { //nolint:govet // Why: We're OK shadowing err
+		o.log.WithError(err).Warn("failed to look up deployed application")
+	} else if e != nil {
+		ref = e.Ref()
+	}
+
+	if o.DryRun {
+		r, err := app.DryRunDelete(ctx, o.log, o.k, o.conf, ref, kr) //nolint:govet // Why: We're OK shadowing err
+		if err != nil {
+			return err
+		}
+
+		r.Print(os.Stdout, true)
+		return nil
+	}
+
+	return app.Delete(ctx, o.log, o.k, o.conf, ref, kr)
 }
@@ -3,6 +3,10 @@ package deployapp
 import (
 	"context"
 	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/getoutreach/devenv/internal/vault"
 	"github.com/getoutreach/devenv/pkg/app"
@@ -10,7 +14,6 @@ import (
 	"github.com/getoutreach/devenv/pkg/config"
 	"github.com/getoutreach/devenv/pkg/devenvutil"
 	"github.com/getoutreach/devenv/pkg/kube"
-	"github.com/getoutreach/gobox/pkg/box"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
@@ -22,16 +25,38 @@ import (
 var (
 	deployAppLongDesc = `
 		deploy-app deploys an Outreach application into your developer environment. The application name (appName) provided should match, exactly, an Outreach repository name.
+		Repositories outside of the default org, or on other hosts, are referenced by host and path, e.g. github.com/org/repo, or by URL.
 	`
 	deployAppExample = `
 		# Deploy an application to the developer environment
 		devenv deploy-app <appName>
 
+		# Deploy a specific version of an application from a fork
+		devenv deploy-app github.com/<org>/<appName>@v1.2.3
+
+		# Deploy an application from another git host
+		devenv deploy-app gitlab.example.com/<group>/<appName>
+
 		# Deploy a local directory application to the developer environment
 		devenv deploy-app .
 
 		# Deploy a local application to the developer environment
 		devenv deploy-app ./outreach-accounts
+
+		# Print the order an application and its dependencies would be deployed in
+		devenv deploy-app --plan <appName>
+
+		# Print what deploying an application would do, without deploying it
+		devenv deploy-app --dry-run <appName>
+
+		# Deploy an application without deploying its dependencies
+		devenv deploy-app --no-deps <appName>
+
+		# Deploy an application, failing if it isn't ready within 5 minutes
+		devenv deploy-app --timeout 5m <appName>
+
+		# Deploy multiple applications, building up to two at a time
+		devenv deploy-app --concurrency 2 <appName> <appName>
 	`
 )
 
@@ -40,7 +65,27 @@ type Options struct {
 	k    kubernetes.Interface
 	conf *rest.Config
 
-	App string
+	Apps []string
+
+	// NoDeps denotes that the dependencies of the applications should
+	// not be deployed
+	NoDeps bool
+
+	// Plan denotes that the order the applications and their dependencies
+	// would be deployed in should be printed, instead of deploying them
+	Plan bool
+
+	// DryRun denotes that what deploying the applications would do should
+	// be printed, instead of deploying them
+	DryRun bool
+
+	// Concurrency is the maximum number of applications that are fetched,
+	// built or waited on at the same time
+	Concurrency int
+
+	// Timeout is how long to wait for each application to become ready
+	// after deploying it
+	Timeout time.Duration
 }
 
 func NewOptions(log logrus.FieldLogger) (*Options, error) {
@@ -67,6 +112,28 @@ func NewCmdDeployApp(log logrus.FieldLogger) *cli.Command {
 				Hidden: true,
 				Usage:  "Deploy an application from local disk --local <path>",
 			},
+			&cli.BoolFlag{
+				Name:  "no-deps",
+				Usage: "Don't deploy the dependencies of the applications",
+			},
+			&cli.BoolFlag{
+				Name:  "plan",
+				Usage: "Print the order the applications and their dependencies would be deployed in, without deploying them",
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Print what deploying the applications would do, e.g. the jobs deleted and images built, without deploying them",
+			},
+			&cli.IntFlag{
+				Name:  "concurrency",
+				Usage: "Maximum number of applications to fetch, build or wait on at the same time",
+				Value: app.DefaultConcurrency,
+			},
+			&cli.DurationFlag{
+				Name:  "timeout",
+				Usage: "How long to wait for each application to become ready after deploying it",
+				Value: app.DefaultHealthCheckTimeout,
+			},
 		},
 		Action: func(c *cli.Context) error {
 			if c.Args().Len() == 0 {
@@ -77,14 +144,19 @@ func NewCmdDeployApp(log logrus.FieldLogger) *cli.Command {
 				return err
 			}
 
-			o.App = c.Args().First()
+			o.Apps = c.Args().Slice()
+			o.NoDeps = c.Bool("no-deps")
+			o.Plan = c.Bool("plan")
+			o.DryRun = c.Bool("dry-run")
+			o.Concurrency = c.Int("concurrency")
+			o.Timeout = c.Duration("timeout")
 			return o.Run(c.Context)
 		},
 	}
 }
 
 func (o *Options) Run(ctx context.Context) error {
-	b, err := box.LoadBox()
+	b, err := config.LoadBox()
 	if err != nil {
 		return errors.Wrap(err, "failed to load box configuration")
 	}
@@ -98,6 +170,7 @@ func (o *Options) Run(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	kr.Configure(o.log, b)
 
 	if b.DeveloperEnvironmentConfig.VaultConfig.Enabled {
 		if err := vault.EnsureLoggedIn(ctx, o.log, b, o.k); err != nil {
@@ -105,5 +178,68 @@ func (o *Options) Run(ctx context.Context) error {
 		}
 	}
 
-	return app.Deploy(ctx, o.log, o.k, o.conf, o.App, kr.GetConfig())
+	p, err := app.NewPlan(ctx, o.log, o.k, o.conf, kr, app.PlanOptions{
+		Apps:        o.Apps,
+		NoDeps:      o.NoDeps,
+		Concurrency: o.Concurrency,
+		Timeout:     o.Timeout,
+		DryRun:      o.DryRun || o.Plan,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to resolve applications")
+	}
+	defer p.Close()
+
+	if o.Plan {
+		printPlan(p)
+		return nil
+	}
+
+	if o.DryRun {
+		results, err := p.DryRun(ctx) //nolint:govet // Why: We're OK shadowing err
+		if err != nil {
+			return err
+		}
+
+		for i, r := range results {
+			if i != 0 {
+				fmt.Println()
+			}
+			r.Print(os.Stdout, false)
+		}
+		return nil
+	}
+
+	err = p.Deploy(ctx)
+	printResults(p.Results())
+	return err
+}
+
+// printPlan prints the order applications in a plan are deployed in
+func printPlan(p *app.Plan) {
+	for i, step := range p.Steps() {
+		fmt.Printf("%d. %s\n", i+1, strings.Join(step, ", "))
+	}
+
+	if deployed := p.AlreadyDeployed(); len(deployed) != 0 {
+		fmt.Printf("Already deployed: %s\n", strings.Join(deployed, ", "))
+	}
+}
+
+// printResults prints a table with the outcome of deploying each
+// application
+func printResults(results []app.Result) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "APPLICATION\tSTATUS\tDURATION\tERROR")
+
+	for _, r := range results {
+		var errMsg string
+		if r.Err != nil {
+			errMsg = r.Err.Error()
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.App, r.Status, r.Duration.Round(time.Second), errMsg)
+	}
+
+	w.Flush() //nolint:errcheck // Why: Best effort
 }
@@ -44,7 +44,7 @@ func NewOptions(log logrus.FieldLogger) (*Options, error) {
 		return nil, errors.Wrap(err, "failed to create docker client")
 	}
 
-	b, err := box.LoadBox()
+	b, err := config.LoadBox()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to read box config")
 	}
@@ -66,6 +66,14 @@ func NewOptions(log logrus.FieldLogger) (*Options, error) {
 
 	r.Configure(log, b)
 
+	return NewOptionsWithClients(log, d, b, r, clusterName), nil
+}
+
+// NewOptionsWithClients creates options that destroy clusterName using
+// the provided runtime and clients instead of creating them from the
+// environment. The runtime must already be configured.
+func NewOptionsWithClients(log logrus.FieldLogger, d dockerclient.APIClient, b *box.Config,
+	r kubernetesruntime.Runtime, clusterName string) *Options {
 	return &Options{
 		log: log,
 		d:   d,
@@ -74,7 +82,7 @@ func NewOptions(log logrus.FieldLogger) (*Options, error) {
 		// Defaults
 		CurrentClusterName: clusterName,
 		KubernetesRuntime:  r,
-	}, nil
+	}
 }
 
 func NewCmdDestroy(log logrus.FieldLogger) *cli.Command {
@@ -116,15 +124,25 @@ func (o *Options) Run(ctx context.Context) error {
 	// nolint:errcheck // Why: Failing to remove a cluster is OK.
 	o.KubernetesRuntime.Destroy(ctx)
 
+	err := config.UpdateConfig(ctx, func(conf *config.Config) error {
+		if cc := conf.GetContextConfig(config.ContextName(o.KubernetesRuntime.GetConfig().Name, o.CurrentClusterName)); cc != nil {
+			cc.DeployedApps = nil
+		}
+		return nil
+	})
+	if err != nil {
+		o.log.WithError(err).Warn("failed to remove deployed applications from devenv config")
+	}
+
 	if o.RemoveImageCache {
-		if o.KubernetesRuntime.GetConfig().Type == kubernetesruntime.RuntimeTypeLocal {
+		if o.KubernetesRuntime.GetConfig().Capabilities.PersistsImageCache {
 			o.log.Info("Removing Kubernetes Docker image cache ...")
-			err := o.d.VolumeRemove(ctx, containerruntime.ContainerName+"-containerd", false)
+			err = o.d.VolumeRemove(ctx, containerruntime.GetContainerName(o.CurrentClusterName)+"-containerd", false)
 			if err != nil && !dockerclient.IsErrNotFound(err) {
 				return errors.Wrap(err, "failed to remove image volume")
 			}
 		} else {
-			o.log.Warn("--remove-image-cache has no effect on a remote kubernetes runtime")
+			o.log.Warn("--remove-image-cache has no effect on this kubernetes runtime")
 		}
 	}
 
@@ -0,0 +1,93 @@
+package destroy
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/getoutreach/devenv/pkg/containerruntime"
+	"github.com/getoutreach/devenv/pkg/kubernetesruntime"
+	"github.com/getoutreach/devenv/pkg/kubernetesruntime/fake"
+	"github.com/getoutreach/gobox/pkg/box"
+	"github.com/sirupsen/logrus"
+)
+
+func TestOptions_Run(t *testing.T) {
+	volume := containerruntime.GetContainerName(kubernetesruntime.KindClusterName) + "-containerd"
+
+	type args struct {
+		clusterName        string
+		persistsImageCache bool
+		removeImageCache   bool
+	}
+	tests := []struct {
+		name          string
+		args          args
+		wantErr       bool
+		wantDestroyed bool
+		wantVolume    bool
+	}{
+		{
+			name: "should destroy the cluster",
+			args: args{
+				clusterName: kubernetesruntime.KindClusterName,
+			},
+			wantDestroyed: true,
+			wantVolume:    true,
+		},
+		{
+			name: "should refuse to destroy clusters of other runtimes",
+			args: args{
+				clusterName: "someone-else",
+			},
+			wantErr:    true,
+			wantVolume: true,
+		},
+		{
+			name: "should remove the image cache",
+			args: args{
+				clusterName:        kubernetesruntime.KindClusterName,
+				persistsImageCache: true,
+				removeImageCache:   true,
+			},
+			wantDestroyed: true,
+		},
+		{
+			name: "should ignore the image cache of runtimes that don't persist it",
+			args: args{
+				clusterName:      kubernetesruntime.KindClusterName,
+				removeImageCache: true,
+			},
+			wantDestroyed: true,
+			wantVolume:    true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+
+			log := logrus.New()
+			log.Out = ioutil.Discard
+
+			r := fake.NewProvisionedRuntime("kind")
+			r.Config.Capabilities.PersistsImageCache = tt.args.persistsImageCache
+			d := fake.NewDockerClient(volume)
+
+			o := NewOptionsWithClients(log, d, &box.Config{}, r, tt.args.clusterName)
+			o.RemoveImageCache = tt.args.removeImageCache
+
+			if err := o.Run(context.Background()); (err != nil) != tt.wantErr {
+				t.Errorf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got := r.Called("Destroy") != 0; got != tt.wantDestroyed {
+				t.Errorf("Run() destroyed = %v, want %v", got, tt.wantDestroyed)
+			}
+
+			if got := d.HasVolume(volume); got != tt.wantVolume {
+				t.Errorf("Run() kept image cache = %v, want %v", got, tt.wantVolume)
+			}
+		})
+	}
+}
@@ -33,7 +33,10 @@ import (
 
 	// Place any extra imports for your startup code here
 	///Block(imports)
+	"github.com/getoutreach/devenv/cmd/devenv/apps"
+	cmdcache "github.com/getoutreach/devenv/cmd/devenv/cache"
 	"github.com/getoutreach/devenv/cmd/devenv/completion"
+	cmdconfig "github.com/getoutreach/devenv/cmd/devenv/config"
 	cmdcontext "github.com/getoutreach/devenv/cmd/devenv/context"
 	deleteapp "github.com/getoutreach/devenv/cmd/devenv/delete-app"
 	deployapp "github.com/getoutreach/devenv/cmd/devenv/deploy-app"
@@ -42,6 +45,8 @@ import (
 	"github.com/getoutreach/devenv/cmd/devenv/kubectl"
 	localapp "github.com/getoutreach/devenv/cmd/devenv/local-app"
 	"github.com/getoutreach/devenv/cmd/devenv/provision"
+	rollbackapp "github.com/getoutreach/devenv/cmd/devenv/rollback-app"
+	"github.com/getoutreach/devenv/cmd/devenv/share"
 	"github.com/getoutreach/devenv/cmd/devenv/snapshot"
 	"github.com/getoutreach/devenv/cmd/devenv/start"
 	"github.com/getoutreach/devenv/cmd/devenv/status"
@@ -50,6 +55,7 @@ import (
 	"github.com/getoutreach/devenv/cmd/devenv/tunnel"
 	updateapp "github.com/getoutreach/devenv/cmd/devenv/update-app"
 	"github.com/getoutreach/devenv/pkg/cmdutil"
+	"github.com/getoutreach/devenv/pkg/kubernetesruntime"
 	///EndBlock(imports)
 )
 
@@ -190,8 +196,10 @@ func main() { //nolint:funlen // Why: We can't dwindle this down anymore without
 		provision.NewCmdProvision(log),
 		deployapp.NewCmdDeployApp(log),
 		deleteapp.NewCmdDeleteApp(log),
+		rollbackapp.NewCmdRollbackApp(log),
+		apps.NewCmdApps(log),
 		destroy.NewCmdDestroy(log),
-		status.NewCmdStatus(log),
+		status.NewCmdStatus(log, kubernetesruntime.GetContextStatus),
 		localapp.NewCmdLocalApp(log),
 		tunnel.NewCmdTunnel(log),
 		kubectl.NewCmdKubectl(log),
@@ -203,6 +211,10 @@ func main() { //nolint:funlen // Why: We can't dwindle this down anymore without
 		snapshot.NewCmdSnapshot(log),
 		expose.NewCmdExpose(log),
 		cmdcontext.NewCmdContext(log),
+		cmdconfig.NewCmdConfig(log),
+		cmdcache.NewCmdCache(log),
+		share.NewCmdShare(log),
+		share.NewCmdUnshare(log),
 		///EndBlock(commands)
 	}
 
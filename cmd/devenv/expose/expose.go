@@ -13,7 +13,6 @@ import (
 	"github.com/getoutreach/devenv/pkg/config"
 	"github.com/getoutreach/devenv/pkg/devenvutil"
 	"github.com/getoutreach/devenv/pkg/kube"
-	"github.com/getoutreach/gobox/pkg/box"
 	"github.com/manifoldco/promptui"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -259,7 +258,7 @@ func (o *Options) CreateNgrokInstance(ctx context.Context, conf *NgrokConfig) er
 }
 
 func (o *Options) Run(ctx context.Context) error {
-	b, err := box.LoadBox()
+	b, err := config.LoadBox()
 	if err != nil {
 		return err
 	}
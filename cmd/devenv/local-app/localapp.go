@@ -13,7 +13,6 @@ import (
 	"github.com/getoutreach/devenv/pkg/devenvutil"
 	"github.com/getoutreach/devenv/pkg/embed"
 	"github.com/getoutreach/devenv/pkg/kubernetestunnelruntime"
-	"github.com/getoutreach/gobox/pkg/box"
 	"github.com/getoutreach/localizer/pkg/localizer"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -184,7 +183,7 @@ func (o *Options) Run(ctx context.Context) error { //nolint:funlen
 		return err
 	}
 
-	b, err := box.LoadBox()
+	b, err := config.LoadBox()
 	if err != nil {
 		return err
 	}
@@ -14,7 +14,6 @@ import (
 	"github.com/getoutreach/devenv/pkg/cmdutil"
 	"github.com/getoutreach/devenv/pkg/devenvutil"
 	"github.com/getoutreach/devenv/pkg/embed"
-	"github.com/getoutreach/devenv/pkg/kubernetesruntime"
 	"github.com/getoutreach/gobox/pkg/async"
 	"github.com/pkg/errors"
 )
@@ -79,11 +78,11 @@ func (o *Options) deployStage(ctx context.Context, stage string) error { //nolin
 		return errors.Wrap(err, "failed to wait for pods to be ready w")
 	}
 
-	// Deploy resourcer if we're a local runtime, we can only run things on a single node
+	// Deploy resourcer if we're a single node runtime, we can only run things on a single node
 	// so we should mutate all pods to have zero resources.
 	// Special exeception is when we're generating snapshots.
-	if o.KubernetesRuntime.GetConfig().Type == kubernetesruntime.RuntimeTypeLocal && os.Getenv("DEVENV_SNAPSHOT_GENERATION") == "" {
-		err := app.Deploy(ctx, o.log, o.k, o.r, "resourcer", o.KubernetesRuntime.GetConfig())
+	if o.KubernetesRuntime.GetConfig().Capabilities.SingleNode && os.Getenv("DEVENV_SNAPSHOT_GENERATION") == "" {
+		err := app.Deploy(ctx, o.log, o.k, o.r, "resourcer", o.KubernetesRuntime)
 		if err != nil {
 			return errors.Wrap(err, "failed to deploy resourcer")
 		}
@@ -72,6 +72,24 @@ var (
 
 		# Restore a snapshot
 		devenv provision --snapshot <name>
+
+		# Create a new development environment using k3d
+		devenv provision --kubernetes-runtime k3d
+
+		# Create an additional development environment named feature-x
+		devenv provision --name feature-x
+
+		# Create a new development environment with two tainted worker nodes
+		devenv provision --workers 2 --node-label pool=batch --node-taint dedicated=batch:NoSchedule
+
+		# Create a new development environment running Kubernetes 1.21
+		devenv provision --kubernetes-version 1.21
+
+		# Create a loft vcluster from a custom template that sleeps after 4 hours of inactivity
+		devenv provision --kubernetes-runtime loft --loft-template devenv-large --loft-sleep-after 4h
+
+		# Use an already existing cluster as a development environment
+		devenv provision --kubernetes-runtime existing --kube-context docker-desktop
 	`
 
 	imagePullSecretPath = filepath.Join(".outreach", ".config", "dev-environment", "image-pull-secret")
@@ -92,8 +110,19 @@ type Options struct {
 	b       *box.Config
 	k       kubernetes.Interface
 	r       *rest.Config
+
+	// newKubeClient creates a client for the cluster once it's been
+	// created
+	newKubeClient KubeClientFunc
+
+	// deployBase deploys the base manifests to the cluster once it's
+	// been created, defaults to deployBaseManifests
+	deployBase func(context.Context) error
 }
 
+// KubeClientFunc creates a Kubernetes client for the current devenv
+type KubeClientFunc func() (kubernetes.Interface, *rest.Config, error)
+
 func NewOptions(log logrus.FieldLogger) (*Options, error) {
 	d, err := dockerclient.NewClientWithOpts(dockerclient.FromEnv)
 	if err != nil {
@@ -105,23 +134,34 @@ func NewOptions(log logrus.FieldLogger) (*Options, error) {
 		return nil, err
 	}
 
-	b, err := box.LoadBox()
+	b, err := config.LoadBox()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to load box configuration")
 	}
 
-	return &Options{
-		log:        log,
-		d:          d,
-		b:          b,
-		DeployApps: make([]string, 0),
-		homeDir:    homeDir,
-	}, nil
+	return NewOptionsWithClients(log, d, b, homeDir, kube.GetKubeClientWithConfig), nil
+}
+
+// NewOptionsWithClients creates options using the provided clients
+// instead of creating them from the environment. Files, e.g. the
+// kubeconfig, are written relative to homeDir.
+func NewOptionsWithClients(log logrus.FieldLogger, d dockerclient.APIClient, b *box.Config, homeDir string,
+	newKubeClient KubeClientFunc) *Options {
+	o := &Options{
+		log:           log,
+		d:             d,
+		b:             b,
+		DeployApps:    make([]string, 0),
+		homeDir:       homeDir,
+		newKubeClient: newKubeClient,
+	}
+	o.deployBase = o.deployBaseManifests
+	return o
 }
 
 func NewCmdProvision(log logrus.FieldLogger) *cli.Command { //nolint:funlen
 	defaultSnapshot := "unknown"
-	b, err := box.LoadBox()
+	b, err := config.LoadBox()
 	if err == nil && b != nil {
 		defaultSnapshot = b.DeveloperEnvironmentConfig.SnapshotConfig.DefaultName
 	}
@@ -151,9 +191,63 @@ func NewCmdProvision(log logrus.FieldLogger) *cli.Command { //nolint:funlen
 			},
 			&cli.StringFlag{
 				Name:  "kubernetes-runtime",
-				Usage: "Specify which kubernetes runtime to use (options: kind, loft)",
+				Usage: "Specify which kubernetes runtime to use (options: kind, k3d, loft, existing)",
 				Value: "kind",
 			},
+			&cli.StringFlag{
+				Name:  "name",
+				Usage: "Name of the cluster to create, allows running multiple clusters (kind runtime only)",
+			},
+			&cli.StringFlag{
+				Name: "kubernetes-version",
+				Usage: fmt.Sprintf("Version of Kubernetes to create the cluster with (kind and k3d runtimes only, options: %s)",
+					strings.Join(kubernetesruntime.GetSupportedKubernetesVersions(), ", ")),
+			},
+			&cli.IntFlag{
+				Name:  "workers",
+				Usage: "Number of worker nodes to create in addition to the control-plane node (kind runtime only)",
+			},
+			&cli.StringSliceFlag{
+				Name:  "node-label",
+				Usage: "Label to set on all worker nodes in the format key=value, can be repeated (kind runtime only)",
+			},
+			&cli.StringSliceFlag{
+				Name:  "node-taint",
+				Usage: "Taint to set on all worker nodes in the format key[=value]:Effect, can be repeated (kind runtime only)",
+			},
+			&cli.BoolFlag{
+				Name:  "local-registry",
+				Usage: "Push locally built images to a local registry instead of loading them into the cluster (kind runtime only)",
+			},
+			&cli.IntFlag{
+				Name:  "local-registry-port",
+				Usage: "Port of the host to expose the local registry on, if it doesn't exist yet (kind runtime only)",
+				Value: containerruntime.DefaultRegistryPort,
+			},
+			&cli.StringFlag{
+				Name:  "kubeconfig",
+				Usage: "Path to the kubeconfig containing the context to adopt (existing runtime only)",
+			},
+			&cli.StringFlag{
+				Name:  "kube-context",
+				Usage: "Kubeconfig context of the cluster to adopt (existing runtime only)",
+			},
+			&cli.StringFlag{
+				Name:  "loft-template",
+				Usage: "vcluster template to create the vcluster from (loft runtime only)",
+			},
+			&cli.DurationFlag{
+				Name:  "loft-sleep-after",
+				Usage: "Duration of inactivity after which the vcluster is put to sleep (loft runtime only)",
+			},
+			&cli.StringFlag{
+				Name:  "loft-cluster",
+				Usage: "Loft cluster to create the vcluster in (loft runtime only)",
+			},
+			&cli.StringFlag{
+				Name:  "loft-space",
+				Usage: "Space to create the vcluster in (loft runtime only)",
+			},
 		},
 		Action: func(c *cli.Context) error {
 			o, err := NewOptions(log)
@@ -172,8 +266,99 @@ func NewCmdProvision(log logrus.FieldLogger) *cli.Command { //nolint:funlen
 			if err != nil {
 				return errors.Wrap(err, "failed to load kubernetes runtime")
 			}
+
+			if er, ok := k8sRuntime.(*kubernetesruntime.ExistingRuntime); ok {
+				if c.String("kube-context") == "" {
+					return fmt.Errorf("--kube-context is required when using the existing runtime")
+				}
+				er.SetTarget(c.String("kubeconfig"), c.String("kube-context"))
+			}
+
+			if name := c.String("name"); name != "" {
+				kr, ok := k8sRuntime.(*kubernetesruntime.KindRuntime)
+				if !ok {
+					return fmt.Errorf("--name is only supported by the kind runtime")
+				}
+				kr.SetClusterName(name)
+			}
+
+			if version := c.String("kubernetes-version"); version != "" {
+				switch kr := k8sRuntime.(type) {
+				case *kubernetesruntime.KindRuntime:
+					err = kr.SetKubernetesVersion(version)
+				case *kubernetesruntime.K3dRuntime:
+					err = kr.SetKubernetesVersion(version)
+				default:
+					err = fmt.Errorf("--kubernetes-version is only supported by the kind and k3d runtimes")
+				}
+				if err != nil {
+					return err
+				}
+			}
+
+			if c.Bool("local-registry") {
+				kr, ok := k8sRuntime.(*kubernetesruntime.KindRuntime)
+				if !ok {
+					return fmt.Errorf("--local-registry is only supported by the kind runtime")
+				}
+				kr.EnableLocalRegistry(c.Int("local-registry-port"))
+			}
+
+			if c.IsSet("workers") || c.IsSet("node-label") || c.IsSet("node-taint") {
+				kr, ok := k8sRuntime.(*kubernetesruntime.KindRuntime)
+				if !ok {
+					return fmt.Errorf("--workers, --node-label and --node-taint are only supported by the kind runtime")
+				}
+
+				labels, err := kubernetesruntime.ParseNodeLabels(c.StringSlice("node-label")) //nolint:govet // Why: We're OK shadowing err
+				if err != nil {
+					return err
+				}
+
+				taints, err := kubernetesruntime.ParseNodeTaints(c.StringSlice("node-taint")) //nolint:govet // Why: We're OK shadowing err
+				if err != nil {
+					return err
+				}
+
+				if err := kr.SetTopology(kubernetesruntime.KindTopology{ //nolint:govet // Why: We're OK shadowing err
+					Workers:    c.Int("workers"),
+					NodeLabels: labels,
+					NodeTaints: taints,
+				}); err != nil {
+					return err
+				}
+			}
+
+			if c.IsSet("loft-template") || c.IsSet("loft-sleep-after") || c.IsSet("loft-cluster") || c.IsSet("loft-space") {
+				lr, ok := k8sRuntime.(*kubernetesruntime.LoftRuntime)
+				if !ok {
+					return fmt.Errorf("--loft-template, --loft-sleep-after, --loft-cluster and --loft-space are only supported by the loft runtime")
+				}
+
+				if c.Duration("loft-sleep-after") < 0 {
+					return fmt.Errorf("--loft-sleep-after must not be negative")
+				}
+
+				lr.SetOptions(kubernetesruntime.LoftOptions{
+					Template:   c.String("loft-template"),
+					SleepAfter: c.Duration("loft-sleep-after"),
+					Cluster:    c.String("loft-cluster"),
+					Space:      c.String("loft-space"),
+				})
+			}
 			o.KubernetesRuntime = k8sRuntime
 
+			// Default to the snapshot this context was last provisioned from
+			if conf, err := config.LoadConfig(c.Context); err == nil { //nolint:govet // Why: We're OK shadowing err
+				cc := conf.GetContextConfig(config.ContextName(k8sRuntime.GetConfig().Name, k8sRuntime.GetConfig().ClusterName))
+				if cc != nil && cc.Snapshot.Target != "" && !c.IsSet("snapshot-target") {
+					o.SnapshotTarget = cc.Snapshot.Target
+				}
+				if cc != nil && cc.Snapshot.Channel != "" && !c.IsSet("snapshot-channel") {
+					o.SnapshotChannel = box.SnapshotLockChannel(cc.Snapshot.Channel)
+				}
+			}
+
 			return o.Run(c.Context)
 		},
 	}
@@ -425,14 +610,16 @@ func (o *Options) deployBaseManifests(ctx context.Context) error {
 }
 
 func (o *Options) removeServiceImages(ctx context.Context) error {
-	// Only run this on local clusters
-	if o.KubernetesRuntime.GetConfig().Type != kubernetesruntime.RuntimeTypeLocal {
+	// Only run this on runtimes that persist their image cache between clusters
+	if !o.KubernetesRuntime.GetConfig().Capabilities.PersistsImageCache {
 		return nil
 	}
 
+	clusterName := o.KubernetesRuntime.GetConfig().ClusterName
+
 	//nolint:gosec // Why: We're passing a constant
 	cmd := exec.CommandContext(ctx, "docker", "exec",
-		kubernetesruntime.KindClusterName+"-control-plane", "ctr", "--namespace", "k8s.io", "images", "ls")
+		containerruntime.GetContainerName(clusterName), "ctr", "--namespace", "k8s.io", "images", "ls")
 	b, err := cmd.CombinedOutput()
 	if err != nil {
 		return errors.Wrapf(err, "failed to list docker images: %s", string(b))
@@ -462,7 +649,7 @@ func (o *Options) removeServiceImages(ctx context.Context) error {
 
 	for img := range images {
 		o.log.WithField("image", img).Infoln("Removing docker image")
-		if err2 := containerruntime.RemoveImage(ctx, img); err2 != nil {
+		if err2 := containerruntime.RemoveImage(ctx, clusterName, img); err2 != nil {
 			o.log.WithField("image", img).Warn("Failed to remove docker image")
 		}
 	}
@@ -494,7 +681,7 @@ func (o *Options) generateDockerConfig() error {
 }
 
 func (o *Options) Run(ctx context.Context) error { //nolint:funlen,gocyclo
-	if o.KubernetesRuntime.GetConfig().Type == kubernetesruntime.RuntimeTypeLocal {
+	if o.KubernetesRuntime.GetConfig().Capabilities.RunsOnDocker {
 		if runtime.GOOS == "darwin" {
 			if err := o.configureDockerForMac(ctx); err != nil {
 				return err
@@ -502,6 +689,11 @@ func (o *Options) Run(ctx context.Context) error { //nolint:funlen,gocyclo
 		}
 	}
 
+	if !o.Base && !o.KubernetesRuntime.GetConfig().Capabilities.SupportsSnapshots {
+		o.log.Warn("Kubernetes runtime doesn't support snapshots, provisioning from base manifests")
+		o.Base = true
+	}
+
 	if err := o.checkPrereqs(ctx); err != nil { //nolint:govet // Why: OK w/ err shadow
 		return errors.Wrap(err, "pre-req check failed")
 	}
@@ -534,16 +726,18 @@ func (o *Options) Run(ctx context.Context) error { //nolint:funlen,gocyclo
 		return errors.Wrap(err, "failed to create kind cluster")
 	}
 
-	conf, err := config.LoadConfig(ctx)
-	if err != nil {
-		conf = &config.Config{}
-	}
-
-	// HACK: If we ever add support for running multiple clusters (which makes sense because of context support)
-	// we will need to update this
-	conf.CurrentContext = o.KubernetesRuntime.GetConfig().Name + ":" + o.KubernetesRuntime.GetConfig().ClusterName
+	// Switch to the newly created cluster, and remember which snapshot
+	// it was provisioned from
+	err = config.UpdateConfig(ctx, func(conf *config.Config) error {
+		conf.CurrentContext = config.ContextName(o.KubernetesRuntime.GetConfig().Name, o.KubernetesRuntime.GetConfig().ClusterName)
 
-	err = config.SaveConfig(ctx, conf)
+		cc := conf.EnsureContextConfig(conf.CurrentContext)
+		cc.DeployedApps = nil
+		if !o.Base {
+			cc.Snapshot = config.SnapshotConfig{Target: o.SnapshotTarget, Channel: string(o.SnapshotChannel)}
+		}
+		return nil
+	})
 	if err != nil {
 		return errors.Wrap(err, "failed to save devenv config")
 	}
@@ -558,7 +752,7 @@ func (o *Options) Run(ctx context.Context) error { //nolint:funlen,gocyclo
 		return errors.Wrap(err, "failed to write kubeconfig")
 	}
 
-	k8sClient, k8sRestConf, err := kube.GetKubeClientWithConfig()
+	k8sClient, k8sRestConf, err := o.newKubeClient()
 	if err != nil {
 		return err
 	}
@@ -597,22 +791,21 @@ func (o *Options) Run(ctx context.Context) error { //nolint:funlen,gocyclo
 		o.log.Info("Deploying base manifests")
 		// Deploy the base manifests
 		//nolint:govet // Why: We're OK shadowing err
-		err := o.deployBaseManifests(ctx)
+		err := o.deployBase(ctx)
 		if err != nil {
 			return err
 		}
 	}
 
-	dopts, err := deployapp.NewOptions(o.log)
-	if err != nil {
-		return err
-	}
+	if len(o.DeployApps) != 0 {
+		dopts, err := deployapp.NewOptions(o.log) //nolint:govet // Why: We're OK shadowing err
+		if err != nil {
+			return err
+		}
 
-	for _, app := range o.DeployApps {
-		dopts.App = app
-		err2 := dopts.Run(ctx)
-		if err2 != nil {
-			o.log.WithError(err2).WithField("app.name", app).Warn("failed to deploy application")
+		dopts.Apps = o.DeployApps
+		if err := dopts.Run(ctx); err != nil { //nolint:govet // Why: We're OK shadowing err
+			o.log.WithError(err).Warn("failed to deploy applications")
 		}
 	}
 
@@ -0,0 +1,135 @@
+package provision
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/getoutreach/devenv/pkg/config"
+	"github.com/getoutreach/devenv/pkg/kubernetesruntime"
+	"github.com/getoutreach/devenv/pkg/kubernetesruntime/fake"
+	"github.com/getoutreach/gobox/pkg/box"
+	"github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func TestOptions_Run(t *testing.T) {
+	tests := []struct {
+		name         string
+		runtime      func() *fake.Runtime
+		wantErr      bool
+		wantCreated  bool
+		wantDeployed bool
+	}{
+		{
+			name:         "should create, configure and deploy a new devenv",
+			runtime:      func() *fake.Runtime { return fake.NewRuntime("kind") },
+			wantCreated:  true,
+			wantDeployed: true,
+		},
+		{
+			name:        "should not provision over an existing devenv",
+			runtime:     func() *fake.Runtime { return fake.NewProvisionedRuntime("kind") },
+			wantErr:     true,
+			wantCreated: false,
+		},
+		{
+			name: "should not create a cluster if the runtime can't be setup",
+			runtime: func() *fake.Runtime {
+				r := fake.NewRuntime("kind")
+				r.Errors["PreCreate"] = errors.New("failed to authenticate")
+				return r
+			},
+			wantErr:     true,
+			wantCreated: false,
+		},
+		{
+			name: "should fail if the cluster can't be created",
+			runtime: func() *fake.Runtime {
+				r := fake.NewRuntime("kind")
+				r.Errors["Create"] = errors.New("out of disk space")
+				return r
+			},
+			wantErr:     true,
+			wantCreated: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			homeDir := t.TempDir()
+			t.Setenv("HOME", homeDir)
+
+			secretPath := filepath.Join(homeDir, imagePullSecretPath)
+			if err := os.MkdirAll(filepath.Dir(secretPath), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(secretPath, []byte("{}"), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			log := logrus.New()
+			log.Out = ioutil.Discard
+
+			r := tt.runtime()
+
+			newKubeClient := func() (kubernetes.Interface, *rest.Config, error) {
+				return r.Clientset, &rest.Config{}, nil
+			}
+
+			b := box.NewConfig()
+			b.DeveloperEnvironmentConfig.VaultConfig = &box.VaultConfig{}
+			b.DeveloperEnvironmentConfig.RuntimeConfig = &box.DeveloperEnvironmentRuntimeConfig{}
+
+			o := NewOptionsWithClients(log, fake.NewDockerClient(), b, homeDir, newKubeClient)
+			o.KubernetesRuntime = r
+
+			var deployed bool
+			o.deployBase = func(context.Context) error {
+				if o.k != r.Clientset {
+					t.Error("Run() deployed base manifests without a client for the created cluster")
+				}
+				deployed = true
+				return nil
+			}
+
+			if err := o.Run(context.Background()); (err != nil) != tt.wantErr {
+				t.Errorf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got := r.Called("Create") != 0; got != tt.wantCreated {
+				t.Errorf("Run() created cluster = %v, want %v", got, tt.wantCreated)
+			}
+
+			if deployed != tt.wantDeployed {
+				t.Errorf("Run() deployed base manifests = %v, want %v", deployed, tt.wantDeployed)
+			}
+
+			if !tt.wantDeployed {
+				return
+			}
+
+			if r.Calls[0] != "Configure" {
+				t.Errorf("Run() called %v, want the runtime to be configured first", r.Calls)
+			}
+
+			if _, err := os.Stat(filepath.Join(homeDir, ".outreach", "kubeconfig.yaml")); err != nil {
+				t.Errorf("Run() didn't write kubeconfig: %v", err)
+			}
+
+			conf, err := config.LoadConfig(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			wantContext := config.ContextName("kind", kubernetesruntime.KindClusterName)
+			if conf.CurrentContext != wantContext {
+				t.Errorf("Run() switched to context %q, want %q", conf.CurrentContext, wantContext)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package rollbackapp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/getoutreach/devenv/internal/vault"
+	"github.com/getoutreach/devenv/pkg/app"
+	"github.com/getoutreach/devenv/pkg/appregistry"
+	"github.com/getoutreach/devenv/pkg/cmdutil"
+	"github.com/getoutreach/devenv/pkg/config"
+	"github.com/getoutreach/devenv/pkg/devenvutil"
+	"github.com/getoutreach/devenv/pkg/kube"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+//nolint:gochecknoglobals
+var (
+	rollbackAppLongDesc = `
+		rollback-app deploys a previous revision of an application into your developer environment. Every deploy-app is recorded as a revision, by default the revision before the last deploy is deployed.
+		Images built for that revision are reused if they still exist, otherwise they're built again.
+	`
+	rollbackAppExample = `
+		# Rollback an application to the revision before the last deploy
+		devenv rollback-app <appName>
+
+		# List the revisions of an application
+		devenv rollback-app --history <appName>
+
+		# Rollback an application to a specific revision
+		devenv rollback-app --to 3 <appName>
+	`
+)
+
+type Options struct {
+	log  logrus.FieldLogger
+	k    kubernetes.Interface
+	conf *rest.Config
+	out  io.Writer
+
+	App string
+
+	// Revision is the revision to rollback to, if 0 the revision before
+	// the last deploy is used
+	Revision int
+
+	// History denotes that the revisions should be printed instead of
+	// rolling back
+	History bool
+}
+
+func NewOptions(log logrus.FieldLogger) (*Options, error) {
+	k, conf, err := kube.GetKubeClientWithConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create kubernetes client")
+	}
+
+	return &Options{
+		k:    k,
+		conf: conf,
+		log:  log,
+		out:  os.Stdout,
+	}, nil
+}
+
+func NewCmdRollbackApp(log logrus.FieldLogger) *cli.Command {
+	return &cli.Command{
+		Name:        "rollback-app",
+		Usage:       "Deploy a previous revision of an application in the developer environment",
+		Description: cmdutil.NewDescription(rollbackAppLongDesc, rollbackAppExample),
+		Flags: []cli.Flag{
+			&cli.IntFlag{
+				Name:  "to",
+				Usage: "Revision to rollback to, defaults to the revision before the last deploy",
+			},
+			&cli.BoolFlag{
+				Name:  "history",
+				Usage: "List the revisions of the application instead of rolling back",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.Args().Len() == 0 {
+				return fmt.Errorf("missing application")
+			}
+			o, err := NewOptions(log)
+			if err != nil {
+				return err
+			}
+
+			o.App = c.Args().First()
+			o.Revision = c.Int("to")
+			o.History = c.Bool("history")
+			return o.Run(c.Context)
+		},
+	}
+}
+
+func (o *Options) Run(ctx context.Context) error {
+	if o.History {
+		return o.printHistory(ctx)
+	}
+
+	b, err := config.LoadBox()
+	if err != nil {
+		return errors.Wrap(err, "failed to load box configuration")
+	}
+
+	conf, err := config.LoadConfig(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to load config")
+	}
+
+	kr, err := devenvutil.EnsureDevenvRunning(ctx, conf, b)
+	if err != nil {
+		return err
+	}
+	kr.Configure(o.log, b)
+
+	if b.DeveloperEnvironmentConfig.VaultConfig.Enabled {
+		if err := vault.EnsureLoggedIn(ctx, o.log, b, o.k); err != nil {
+			return errors.Wrap(err, "failed to refresh vault authentication")
+		}
+	}
+
+	return app.Rollback(ctx, o.log, o.k, o.conf, o.App, o.Revision, kr)
+}
+
+// printHistory prints the revisions of the application, newest first
+func (o *Options) printHistory(ctx context.Context) error {
+	history, err := appregistry.New(o.k).History(ctx, o.App)
+	if err != nil {
+		return err
+	}
+
+	if len(history) == 0 {
+		return fmt.Errorf("no deploy history found for %s", o.App)
+	}
+
+	w := tabwriter.NewWriter(o.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REVISION\tVERSION\tCOMMIT\tSOURCE\tDEPLOYED\tCACHED IMAGES")
+
+	for i := len(history) - 1; i >= 0; i-- {
+		e := &history[i]
+
+		source := e.Reference
+		if e.Local {
+			source = "local: " + source
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%d\n", e.Revision, e.Version, e.Commit, source,
+			e.DeployedAt.Local().Format(time.RFC822), len(e.ImageIDs))
+	}
+
+	return w.Flush()
+}
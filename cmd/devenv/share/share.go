@@ -0,0 +1,131 @@
+// Package share implements the share and unshare commands
+package share
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/getoutreach/devenv/pkg/cmdutil"
+	"github.com/getoutreach/devenv/pkg/config"
+	"github.com/getoutreach/devenv/pkg/kubernetesruntime"
+	"github.com/getoutreach/gobox/pkg/box"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+//nolint:gochecknoglobals
+var (
+	shareLongDesc = `
+		Share grants another user access to your remote developer environment, e.g. when pairing or debugging.
+		Only developer environments created by the loft runtime can be shared.
+	`
+	shareExample = `
+		# Give jane.doe access to your developer environment
+		devenv share jane.doe
+	`
+
+	unshareLongDesc = `
+		Unshare revokes access to your remote developer environment from a user it was shared with.
+	`
+	unshareExample = `
+		# Revoke jane.doe's access to your developer environment
+		devenv unshare jane.doe
+	`
+)
+
+type Options struct {
+	log logrus.FieldLogger
+	b   *box.Config
+
+	// User is the loft user to share the developer environment with
+	User string
+
+	// Revoke denotes that access should be revoked instead of granted
+	Revoke bool
+}
+
+func NewOptions(log logrus.FieldLogger) (*Options, error) {
+	b, err := config.LoadBox()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load box configuration")
+	}
+
+	return &Options{
+		log: log,
+		b:   b,
+	}, nil
+}
+
+func NewCmdShare(log logrus.FieldLogger) *cli.Command {
+	return newCmd(log, "share", "Share your remote developer environment with another user", shareLongDesc, shareExample, false)
+}
+
+func NewCmdUnshare(log logrus.FieldLogger) *cli.Command {
+	return newCmd(log, "unshare", "Revoke access to your remote developer environment from another user",
+		unshareLongDesc, unshareExample, true)
+}
+
+// newCmd creates a share or unshare command
+func newCmd(log logrus.FieldLogger, name, usage, longDesc, example string, revoke bool) *cli.Command {
+	return &cli.Command{
+		Name:        name,
+		Usage:       usage,
+		ArgsUsage:   "<user>",
+		Description: cmdutil.NewDescription(longDesc, example),
+		Action: func(c *cli.Context) error {
+			o, err := NewOptions(log)
+			if err != nil {
+				return err
+			}
+
+			o.User = c.Args().First()
+			if o.User == "" {
+				return fmt.Errorf("missing user")
+			}
+			o.Revoke = revoke
+
+			return o.Run(c.Context)
+		},
+	}
+}
+
+func (o *Options) Run(ctx context.Context) error {
+	conf, err := config.LoadConfig(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to load devenv config")
+	}
+
+	r, err := kubernetesruntime.GetRuntimeFromContext(conf, o.b)
+	if err != nil {
+		return errors.Wrap(err, "failed to get runtime from context")
+	}
+
+	lr, ok := r.(*kubernetesruntime.LoftRuntime)
+	if !ok {
+		return fmt.Errorf("kubernetes runtime '%s' doesn't support sharing, only loft developer environments can be shared",
+			r.GetConfig().Name)
+	}
+	lr.Configure(o.log, o.b)
+
+	if err := lr.PreCreate(ctx); err != nil { //nolint:govet // Why: We're OK shadowing err
+		return errors.Wrap(err, "failed to setup runtime")
+	}
+
+	if o.Revoke {
+		if err := lr.Unshare(ctx, o.User); err != nil { //nolint:govet // Why: We're OK shadowing err
+			return err
+		}
+
+		o.log.WithField("user", o.User).Info("Revoked access to developer environment")
+		return nil
+	}
+
+	if err := lr.Share(ctx, o.User); err != nil {
+		return err
+	}
+
+	o.log.WithField("user", o.User).Infof("Shared developer environment, they can now use it by running 'devenv context %s:%s'",
+		lr.GetConfig().Name, lr.GetConfig().ClusterName)
+	return nil
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/getoutreach/devenv/cmd/devenv/destroy"
 	devenvaws "github.com/getoutreach/devenv/pkg/aws"
 	"github.com/getoutreach/devenv/pkg/cmdutil"
+	devenvconfig "github.com/getoutreach/devenv/pkg/config"
 	"github.com/getoutreach/devenv/pkg/devenvutil"
 	"github.com/getoutreach/devenv/pkg/kube"
 	"github.com/getoutreach/devenv/pkg/snapshoter"
@@ -30,7 +31,7 @@ import (
 )
 
 func (o *Options) Generate(ctx context.Context, s *box.SnapshotGenerateConfig, skipUpload bool, channel box.SnapshotLockChannel) error { //nolint:funlen
-	b, err := box.LoadBox()
+	b, err := devenvconfig.LoadBox()
 	if err != nil {
 		return errors.Wrap(err, "failed to load box configuration")
 	}
@@ -4,14 +4,13 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/docker/docker/api/types"
-	dockerclient "github.com/docker/docker/client"
 	"github.com/getoutreach/devenv/cmd/devenv/status"
 	"github.com/getoutreach/devenv/internal/vault"
 	"github.com/getoutreach/devenv/pkg/cmdutil"
-	"github.com/getoutreach/devenv/pkg/containerruntime"
+	"github.com/getoutreach/devenv/pkg/config"
 	"github.com/getoutreach/devenv/pkg/devenvutil"
 	"github.com/getoutreach/devenv/pkg/kube"
+	"github.com/getoutreach/devenv/pkg/kubernetesruntime"
 	"github.com/getoutreach/gobox/pkg/box"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -22,7 +21,7 @@ import (
 //nolint:gochecknoglobals
 var (
 	startLongDesc = `
-		Start restarts your Kubernetes leader node, which kicks off launching your developer environment.
+		Start restarts your Kubernetes cluster, which kicks off launching your developer environment.
 	`
 	startExample = `
 		# Start your already provisioned developer environment
@@ -32,25 +31,25 @@ var (
 
 type Options struct {
 	log logrus.FieldLogger
-	d   dockerclient.APIClient
 	k   kubernetes.Interface
+	b   *box.Config
 }
 
 func NewOptions(log logrus.FieldLogger) (*Options, error) {
-	d, err := dockerclient.NewClientWithOpts(dockerclient.FromEnv)
+	k, err := kube.GetKubeClient()
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to create docker client")
+		return nil, errors.Wrap(err, "failed to create kubernetes client")
 	}
 
-	k, err := kube.GetKubeClient()
+	b, err := config.LoadBox()
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to create kubernetes client")
+		return nil, errors.Wrap(err, "failed to load box configuration")
 	}
 
 	return &Options{
 		log: log,
-		d:   d,
 		k:   k,
+		b:   b,
 	}, nil
 }
 
@@ -75,35 +74,39 @@ func NewCmdStart(log logrus.FieldLogger) *cli.Command {
 // rewrite the rest of this. Then it makes more sense to split this
 // out into functions.
 func (o *Options) Run(ctx context.Context) error { //nolint:funlen
-	b, err := box.LoadBox()
+	conf, err := config.LoadConfig(ctx)
 	if err != nil {
-		return errors.Wrap(err, "failed to load box configuration")
+		return errors.Wrap(err, "failed to load devenv config")
 	}
 
-	cont, err := o.d.ContainerInspect(ctx, containerruntime.ContainerName)
-	if dockerclient.IsErrNotFound(err) {
-		if _, err = o.d.ContainerInspect(ctx, "k3s"); err == nil {
-			o.log.Info("Please destroy and reprovision your cluster. This will greatly increase the stability.")
-			return fmt.Errorf("found older kubernetes runtime environment (k3s)")
-		}
+	r, err := kubernetesruntime.GetRuntimeFromContext(conf, o.b)
+	if err != nil {
+		return errors.Wrap(err, "failed to get runtime from context")
+	}
+	r.Configure(o.log, o.b)
 
-		o.log.Info("Hint: Try running 'devenv provision'")
-		return fmt.Errorf("developer environment not found")
-	} else if err != nil {
-		return err
+	if !r.GetConfig().Capabilities.SupportsSleep {
+		return fmt.Errorf("kubernetes runtime '%s' doesn't support being started", r.GetConfig().Name)
+	}
+
+	if err := r.PreCreate(ctx); err != nil { //nolint:govet // Why: We're OK shadowing err
+		return errors.Wrap(err, "failed to setup runtime")
 	}
 
-	if cont.State.Running {
+	rs := r.Status(ctx)
+	if rs.Status.Status == status.Running {
 		return fmt.Errorf("developer environment is already started")
 	}
 
-	o.log.Info("Starting Developer Environment")
-	err = o.d.ContainerStart(ctx, cont.ID, types.ContainerStartOptions{})
-	if err != nil {
+	o.log.WithField("runtime", r.GetConfig().Name).Info("Starting Developer Environment")
+	if err := r.Start(ctx); err != nil { //nolint:govet // Why: We're OK shadowing err
+		if rs.Status.Status == status.Unprovisioned {
+			o.log.Info("Hint: Try running 'devenv provision'")
+		}
 		return errors.Wrap(err, "failed to start developer environment")
 	}
 
-	sopt, err := status.NewOptions(o.log)
+	sopt, err := status.NewOptions(o.log, kubernetesruntime.GetContextStatus)
 	if err != nil {
 		return err
 	}
@@ -114,8 +117,8 @@ func (o *Options) Run(ctx context.Context) error { //nolint:funlen
 		return err
 	}
 
-	if b.DeveloperEnvironmentConfig.VaultConfig.Enabled {
-		if err := vault.EnsureLoggedIn(ctx, o.log, b, o.k); err != nil {
+	if o.b.DeveloperEnvironmentConfig.VaultConfig.Enabled {
+		if err := vault.EnsureLoggedIn(ctx, o.log, o.b, o.k); err != nil {
 			return errors.Wrap(err, "failed to refresh vault authentication")
 		}
 	}
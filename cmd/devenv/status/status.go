@@ -12,8 +12,9 @@ import (
 	"time"
 
 	dockerclient "github.com/docker/docker/client"
+	"github.com/getoutreach/devenv/pkg/appregistry"
 	"github.com/getoutreach/devenv/pkg/cmdutil"
-	"github.com/getoutreach/devenv/pkg/containerruntime"
+	"github.com/getoutreach/devenv/pkg/config"
 	"github.com/getoutreach/devenv/pkg/kube"
 	"github.com/getoutreach/gobox/pkg/app"
 	"github.com/getoutreach/gobox/pkg/trace"
@@ -49,6 +50,12 @@ var (
 	`
 )
 
+// RuntimeStatusFunc returns the status of the current devenv as reported
+// by its kubernetes runtime, or nil if it couldn't be determined. This is
+// kubernetesruntime.GetContextStatus, which can't be imported here as that
+// package imports this one.
+type RuntimeStatusFunc func(context.Context, logrus.FieldLogger) *Status
+
 type Options struct {
 	log logrus.FieldLogger
 	k   kubernetes.Interface
@@ -68,27 +75,45 @@ type Options struct {
 	// IncludeKubeSystem is a flag that denotes whether or not to
 	// include kube-system in the output of the status command.
 	IncludeKubeSystem bool
+
+	// RuntimeStatus returns the status of the current devenv as reported
+	// by its kubernetes runtime, if nil only the cluster is checked
+	RuntimeStatus RuntimeStatusFunc
 }
 
-func NewOptions(log logrus.FieldLogger) (*Options, error) {
+func NewOptions(log logrus.FieldLogger, runtimeStatus RuntimeStatusFunc) (*Options, error) {
 	k, err := kube.GetKubeClient()
 	if err != nil {
 		log.WithError(err).Warn("failed to create a kubernetes client")
 	}
 
-	d, err := dockerclient.NewClientWithOpts(dockerclient.FromEnv)
+	// Ensure that d is a nil interface, not a nil *Client, if the
+	// client couldn't be created.
+	var d dockerclient.APIClient
+	dc, err := dockerclient.NewClientWithOpts(dockerclient.FromEnv)
 	if err != nil {
 		log.WithError(err).Warn("failed to create a docker client")
+	} else {
+		d = dc
 	}
 
+	return NewOptionsWithClients(log, k, d, runtimeStatus), nil
+}
+
+// NewOptionsWithClients creates options using the provided clients
+// instead of creating them from the environment. A nil client is
+// treated as a client that couldn't be created.
+func NewOptionsWithClients(log logrus.FieldLogger, k kubernetes.Interface, d dockerclient.APIClient,
+	runtimeStatus RuntimeStatusFunc) *Options {
 	return &Options{
-		d:   d,
-		k:   k,
-		log: log,
-	}, nil
+		d:             d,
+		k:             k,
+		log:           log,
+		RuntimeStatus: runtimeStatus,
+	}
 }
 
-func NewCmdStatus(log logrus.FieldLogger) *cli.Command {
+func NewCmdStatus(log logrus.FieldLogger, runtimeStatus RuntimeStatusFunc) *cli.Command {
 	return &cli.Command{
 		Name:        "status",
 		Usage:       "View the status of the developer environment",
@@ -115,7 +140,7 @@ func NewCmdStatus(log logrus.FieldLogger) *cli.Command {
 			},
 		},
 		Action: func(c *cli.Context) error {
-			o, err := NewOptions(log)
+			o, err := NewOptions(log, runtimeStatus)
 			if err != nil {
 				return err
 			}
@@ -161,6 +186,19 @@ func (o *Options) GetStatus(ctx context.Context) (*Status, error) {
 		return status, nil
 	}
 
+	if o.RuntimeStatus != nil {
+		if rs := o.RuntimeStatus(ctx, o.log); rs != nil {
+			status.Version = rs.Version
+
+			// Only a running cluster is worth checking further, anything
+			// else is reported as is.
+			switch rs.Status {
+			case Stopped, Unprovisioned, Degraded:
+				return rs, nil
+			}
+		}
+	}
+
 	if o.k == nil {
 		status.Status = Unprovisioned
 		return status, nil
@@ -196,6 +234,19 @@ func (o *Options) GetStatus(ctx context.Context) (*Status, error) {
 	return status, nil
 }
 
+// minorVersion returns the major and minor version of a Kubernetes
+// version, e.g. v1.20.7+k3s1 -> v1.20
+func minorVersion(version string) string {
+	version = "v" + strings.TrimPrefix(version, "v")
+
+	spl := strings.SplitN(version, ".", 3)
+	if len(spl) < 2 {
+		return version
+	}
+
+	return spl[0] + "." + spl[1]
+}
+
 func (o *Options) CheckLocalDNSResolution(ctx context.Context) error { //nolint:funlen
 	ctx = trace.StartCall(ctx, "status.CheckLocalDNSResolution")
 	defer trace.EndCall(ctx)
@@ -243,14 +294,10 @@ func (o *Options) kubernetesInfo(ctx context.Context, w io.Writer) error { //nol
 	}
 
 	for i := range nodes.Items {
-		if nodes.Items[i].Name != containerruntime.ContainerName {
-			continue
-		}
-
 		capacity := &nodes.Items[i].Status.Capacity
 		allocatable := &nodes.Items[i].Status.Allocatable
 
-		fmt.Fprintf(w, "\nNode \"%s\" Information:\n---\n", containerruntime.ContainerName)
+		fmt.Fprintf(w, "\nNode \"%s\" Information:\n---\n", nodes.Items[i].Name)
 
 		fmt.Fprintln(w, "Resources (capacity/allocatable):")
 		fmt.Fprintf(w, "\tCPU: %s/%s\n", capacity.Cpu(), allocatable.Cpu())
@@ -263,8 +310,14 @@ func (o *Options) kubernetesInfo(ctx context.Context, w io.Writer) error { //nol
 			fmt.Fprintf(w, "\t%s: %s (%s)\n", nodes.Items[i].Status.Conditions[j].Type, nodes.Items[i].Status.Conditions[j].Status, nodes.Items[i].Status.Conditions[j].Message)
 		}
 
+		if len(nodes.Items[i].Spec.Taints) != 0 {
+			fmt.Fprintln(w, "Taints:")
+			for j := range nodes.Items[i].Spec.Taints {
+				fmt.Fprintf(w, "\t%s\n", nodes.Items[i].Spec.Taints[j].ToString())
+			}
+		}
+
 		fmt.Fprintf(w, "Images Deployed: %d\n", len(nodes.Items[i].Status.Images))
-		break
 	}
 
 	for i := range namespaces.Items {
@@ -336,6 +389,26 @@ func (o *Options) kubernetesInfo(ctx context.Context, w io.Writer) error { //nol
 	return nil
 }
 
+// deployedApps prints the applications deployed with deploy-app, see
+// appregistry.Registry
+func (o *Options) deployedApps(ctx context.Context, w io.Writer) {
+	entries, err := appregistry.New(o.k).List(ctx)
+	if err != nil {
+		o.log.WithError(err).Warn("failed to list deployed applications")
+		return
+	}
+
+	fmt.Fprintln(w, "\nDeployed Applications:\n---")
+	if len(entries) == 0 {
+		fmt.Fprintln(w, "None, deploy applications with devenv deploy-app")
+		return
+	}
+
+	if err := appregistry.PrintEntries(w, entries); err != nil {
+		o.log.WithError(err).Warn("failed to print deployed applications")
+	}
+}
+
 func (o *Options) Run(ctx context.Context) error { //nolint:funlen,gocyclo
 	target := io.Writer(os.Stdout)
 	if o.Quiet {
@@ -361,6 +434,14 @@ func (o *Options) Run(ctx context.Context) error { //nolint:funlen,gocyclo
 	}
 	if status.KubernetesVersion != "" {
 		fmt.Fprintf(w, "Kubernetes Version: %s\n", status.KubernetesVersion)
+
+		if b, err := config.LoadBoxConfig(); err == nil { //nolint:govet // Why: We're OK shadowing err
+			recommended := b.DeveloperEnvironmentConfig.RuntimeConfig.KubernetesVersion
+			if recommended != "" && minorVersion(recommended) != minorVersion(status.KubernetesVersion) {
+				o.log.Warnf("Kubernetes version %s differs from the recommended version %s, "+
+					"consider reprovisioning your developer environment", status.KubernetesVersion, recommended)
+			}
+		}
 	}
 	// Only show Kubernetes info if we were able to make a client
 	if o.k != nil {
@@ -375,6 +456,8 @@ func (o *Options) Run(ctx context.Context) error { //nolint:funlen,gocyclo
 		if err != nil {
 			return err
 		}
+
+		o.deployedApps(ctx, w)
 	}
 
 	if err := w.Flush(); err != nil { //nolint:govet // We're. OK. Shadowing. Error.
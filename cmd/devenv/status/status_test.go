@@ -0,0 +1,86 @@
+package status_test
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/getoutreach/devenv/cmd/devenv/status"
+	"github.com/getoutreach/devenv/pkg/kubernetesruntime/fake"
+	"github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+)
+
+func TestOptions_GetStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		runtime     func() *fake.Runtime
+		noDocker    bool
+		want        string
+		wantVersion string
+	}{
+		{
+			name:        "should report a running cluster",
+			runtime:     func() *fake.Runtime { return fake.NewProvisionedRuntime("kind") },
+			want:        status.Running,
+			wantVersion: fake.DefaultKubernetesVersion,
+		},
+		{
+			name: "should report a stopped cluster",
+			runtime: func() *fake.Runtime {
+				r := fake.NewProvisionedRuntime("kind")
+				r.Stop(context.Background()) //nolint:errcheck
+				return r
+			},
+			want: status.Stopped,
+		},
+		{
+			name:    "should report an unprovisioned cluster",
+			runtime: func() *fake.Runtime { return fake.NewRuntime("kind") },
+			want:    status.Unprovisioned,
+		},
+		{
+			name:     "should report an unknown status without docker",
+			runtime:  func() *fake.Runtime { return fake.NewProvisionedRuntime("kind") },
+			noDocker: true,
+			want:     status.Unknown,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			log := logrus.New()
+			log.Out = ioutil.Discard
+
+			r := tt.runtime()
+
+			var k kubernetes.Interface
+			if r.Clientset != nil {
+				k = r.Clientset
+			}
+
+			runtimeStatus := func(ctx context.Context, _ logrus.FieldLogger) *status.Status {
+				rs := r.Status(ctx)
+				return &rs.Status
+			}
+
+			o := status.NewOptionsWithClients(log, k, fake.NewDockerClient(), runtimeStatus)
+			if tt.noDocker {
+				o = status.NewOptionsWithClients(log, k, nil, runtimeStatus)
+			}
+
+			got, err := o.GetStatus(context.Background())
+			if err != nil {
+				t.Fatalf("GetStatus() error = %v", err)
+			}
+
+			if got.Status != tt.want {
+				t.Errorf("GetStatus() status = %v, want %v (reason: %s)", got.Status, tt.want, got.Reason)
+			}
+
+			if got.KubernetesVersion != tt.wantVersion {
+				t.Errorf("GetStatus() version = %v, want %v", got.KubernetesVersion, tt.wantVersion)
+			}
+		})
+	}
+}
@@ -2,14 +2,12 @@ package stop
 
 import (
 	"context"
-	"time"
+	"fmt"
 
-	dockerclient "github.com/docker/docker/client"
 	"github.com/getoutreach/devenv/pkg/cmdutil"
-	"github.com/getoutreach/devenv/pkg/containerruntime"
-	"github.com/getoutreach/devenv/pkg/worker"
-	olog "github.com/getoutreach/gobox/pkg/log"
-	"github.com/getoutreach/gobox/pkg/trace"
+	"github.com/getoutreach/devenv/pkg/config"
+	"github.com/getoutreach/devenv/pkg/kubernetesruntime"
+	"github.com/getoutreach/gobox/pkg/box"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
@@ -18,7 +16,7 @@ import (
 //nolint:gochecknoglobals
 var (
 	stopLongDesc = `
-		Stop stops your developer environment. This includes your Kubernetes leader node, and the containers it created.
+		Stop stops your developer environment. This includes your Kubernetes cluster, and the containers it created.
 	`
 	stopExample = `
 		# Stop your running developer environment
@@ -28,19 +26,18 @@ var (
 
 type Options struct {
 	log logrus.FieldLogger
-
-	d dockerclient.APIClient
+	b   *box.Config
 }
 
 func NewOptions(log logrus.FieldLogger) (*Options, error) {
-	d, err := dockerclient.NewClientWithOpts(dockerclient.FromEnv)
+	b, err := config.LoadBox()
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to create docker client")
+		return nil, errors.Wrap(err, "failed to load box configuration")
 	}
 
 	return &Options{
 		log: log,
-		d:   d,
+		b:   b,
 	}, nil
 }
 
@@ -61,51 +58,29 @@ func NewCmdStop(log logrus.FieldLogger) *cli.Command {
 	}
 }
 
-func (o *Options) StopContainers(ctx context.Context, containers []string) error {
-	ctx = trace.StartCall(ctx, "stop.RemoveContainers")
-	defer trace.EndCall(ctx)
-
-	containersInf := make([]interface{}, len(containers))
-	for i, cont := range containers {
-		containersInf[i] = cont
+func (o *Options) Run(ctx context.Context) error {
+	conf, err := config.LoadConfig(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to load devenv config")
 	}
 
-	timeout := time.Duration(0)
-	_, err := worker.ProcessArray(ctx, containersInf, func(ctx context.Context, data interface{}) (interface{}, error) {
-		cont := data.(string)
-		ctx = trace.StartCall(ctx, "docker.ContainerStop", olog.F{"container": cont})
-		defer trace.EndCall(ctx)
-
-		err := o.d.ContainerStop(ctx, cont, &timeout)
-		if err != nil && !dockerclient.IsErrNotFound(err) {
-			err = trace.SetCallStatus(ctx, err)
-			return nil, err
-		}
-
-		return nil, nil
-	})
-
-	return err
-}
+	r, err := kubernetesruntime.GetRuntimeFromContext(conf, o.b)
+	if err != nil {
+		return errors.Wrap(err, "failed to get runtime from context")
+	}
+	r.Configure(o.log, o.b)
 
-func (o *Options) Run(ctx context.Context) error {
-	o.log.Info("Stopping Developer Environment ...")
-	err := o.StopContainers(ctx, []string{
-		"k3s",
-		containerruntime.ContainerName,
+	if !r.GetConfig().Capabilities.SupportsSleep {
+		return fmt.Errorf("kubernetes runtime '%s' doesn't support being stopped", r.GetConfig().Name)
+	}
 
-		// older containers
-		"proxy",
-		"proxy-http",
-		"proxy-https",
+	if err := r.PreCreate(ctx); err != nil { //nolint:govet // Why: We're OK shadowing err
+		return errors.Wrap(err, "failed to setup runtime")
+	}
 
-		// new proxy containers
-		"proxy-6443",
-		"proxy-443",
-		"proxy-80",
-	})
-	if err != nil {
-		return err
+	o.log.WithField("runtime", r.GetConfig().Name).Info("Stopping Developer Environment ...")
+	if err := r.Stop(ctx); err != nil { //nolint:govet // Why: We're OK shadowing err
+		return errors.Wrap(err, "failed to stop developer environment")
 	}
 
 	o.log.Info("Developer Environment stopped successfully")
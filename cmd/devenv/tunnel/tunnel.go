@@ -10,7 +10,6 @@ import (
 	"github.com/getoutreach/devenv/pkg/devenvutil"
 	"github.com/getoutreach/devenv/pkg/kubernetestunnelruntime"
 	"github.com/getoutreach/gobox/pkg/async"
-	"github.com/getoutreach/gobox/pkg/box"
 	localizerapi "github.com/getoutreach/localizer/api"
 	"github.com/getoutreach/localizer/pkg/localizer"
 	"github.com/pkg/errors"
@@ -71,7 +70,7 @@ func (o *Options) Run(ctx context.Context) error { //nolint:funlen
 		return err
 	}
 
-	b, err := box.LoadBox()
+	b, err := config.LoadBox()
 	if err != nil {
 		return err
 	}
@@ -85,6 +84,22 @@ func (o *Options) Run(ctx context.Context) error { //nolint:funlen
 		return err2
 	}
 
+	// Remember which apps were ran through local-app, so they don't
+	// have to be provided every time
+	if len(o.LocalApps) == 0 {
+		if cc := conf.GetContextConfig(conf.CurrentContext); cc != nil {
+			o.LocalApps = cc.Tunnel.LocalApps
+		}
+	} else {
+		err = config.UpdateConfig(ctx, func(newConf *config.Config) error {
+			newConf.EnsureContextConfig(conf.CurrentContext).Tunnel.LocalApps = o.LocalApps
+			return nil
+		})
+		if err != nil {
+			o.log.WithError(err).Warn("failed to save tunnel preferences")
+		}
+	}
+
 	// Preemptively ask for sudo to prevent input mangaling with o.LocalApps
 	o.log.Info("You may get a sudo prompt, this is so localizer can create tunnels")
 	err = cmdutil.RunKubernetesCommand(ctx, "", true, "sudo", "echo", "Hello, world!")
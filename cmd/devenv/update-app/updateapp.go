@@ -3,9 +3,11 @@ package updateapp
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 
 	deployapp "github.com/getoutreach/devenv/cmd/devenv/deploy-app"
+	"github.com/getoutreach/devenv/pkg/appregistry"
 	"github.com/getoutreach/devenv/pkg/cmdutil"
 	"github.com/getoutreach/devenv/pkg/config"
 	"github.com/getoutreach/devenv/pkg/containerruntime"
@@ -30,15 +32,17 @@ import (
 //nolint:gochecknoglobals
 var (
 	updateAppLongDesc = `
-		update-app(s) updates your applications running in your developer environment. This is done by finding all pods that have your Docker repository, and do not have a tag.
+		update-app(s) updates your applications running in your developer environment to their latest version. Applications deployed from local disk or at a specific version with deploy-app are skipped. Applications that weren't deployed with deploy-app, e.g. restored from a snapshot, are found by finding all pods that have your Docker repository, and do not have a tag.
 	`
 	updateAppExample = `
 		# Update all your applications
 		devenv update-apps
 
+		# Update a specific application
+		devenv update-app authz
+
 		# Update a specific application (based on namespace)
-		devenv update-app authz--bento1a (bootstrap)
-		devenv update-app flagship (non-standard)
+		devenv update-app authz--bento1a
 	`
 	notUpdatableViaDeployApp = map[string]bool{
 		"bento1a": true,
@@ -54,11 +58,14 @@ type Options struct {
 	d   dockerclient.APIClient
 	b   *box.Config
 
+	// clusterName is the name of the cluster of the current context
+	clusterName string
+
 	AppName string
 }
 
 func NewOptions(log logrus.FieldLogger) *Options {
-	b, err := box.LoadBox()
+	b, err := config.LoadBox()
 	if err != nil {
 		panic(err)
 	}
@@ -98,7 +105,12 @@ func NewCmdUpdateApp(log logrus.FieldLogger) *cli.Command {
 }
 
 type service struct {
-	Name   string
+	Name string
+
+	// Reference is what the service was deployed from, see
+	// appregistry.Entry
+	Reference string
+
 	Images []string
 	Pods   []*metav1.PartialObjectMetadata
 }
@@ -117,14 +129,85 @@ func (s *service) MarshalLog(addField func(field string, value interface{})) {
 	addField("service.images", s.Images)
 }
 
-//nolint:funlen
-func (o *Options) getUpdatableServices(ctx context.Context, namespace string) ([]*service, error) {
+// getUpdatableServices returns the services that can be updated. These
+// are the services deployed with deploy-app, see appregistry.Registry,
+// and the services found in namespaces that no application in the
+// registry is deployed into, see discoverServices. AppName can be the
+// name of an application or a namespace.
+func (o *Options) getUpdatableServices(ctx context.Context) ([]*service, error) {
+	ctx = trace.StartCall(ctx, "updateapp.getUpdatableServices")
+	defer trace.EndCall(ctx)
+
+	o.log.Info("Fetching list of updatable services")
+	entries, err := appregistry.New(o.k).List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	services := make([]*service, 0)
+
+	// registered are the namespaces of the applications in the registry,
+	// which are never discovered
+	registered := make(map[string]bool)
+	matched := false
+	for i := range entries {
+		e := &entries[i]
+		for _, ns := range e.Namespaces {
+			registered[ns] = true
+		}
+
+		if o.AppName != "" && e.Name != o.AppName && !contains(e.Namespaces, o.AppName) {
+			continue
+		}
+		matched = true
+
+		if e.Local || e.Version != "" {
+			o.log.WithField("service", e.Name).Info("Skipping service deployed from local disk or at a specific version")
+			continue
+		}
+
+		svc := &service{
+			Name:      e.Name,
+			Reference: e.Reference,
+		}
+		if err := o.findPods(ctx, e, svc); err != nil { //nolint:govet // Why: We're OK shadowing err
+			return nil, err
+		}
+
+		services = append(services, svc)
+	}
+
+	if o.AppName == "" || !matched {
+		namespace := metav1.NamespaceAll
+		if o.AppName != "" {
+			namespace = o.AppName
+		}
+
+		discovered, err := o.discoverServices(ctx, namespace, registered) //nolint:govet // Why: We're OK shadowing err
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, discovered...)
+	}
+
+	if o.AppName != "" && len(services) == 0 {
+		return nil, fmt.Errorf("application %s wasn't found, or can't be updated", o.AppName)
+	}
+
+	return services, nil
+}
+
+// discoverServices finds the services in a namespace, or all namespaces,
+// that weren't deployed with deploy-app, e.g. ones restored from a
+// snapshot. These are the pods using an image from our registry with the
+// latest tag, the service is named after their namespace. Namespaces of
+// applications in the registry are skipped.
+func (o *Options) discoverServices(ctx context.Context, namespace string, registered map[string]bool) ([]*service, error) {
 	ctx = trace.StartCall(ctx, "kubernetes.GetPods")
 	defer trace.EndCall(ctx)
 
 	services := make(map[string]*service)
 
-	o.log.Info("Fetching list of updatable services")
 	cursor := ""
 	for {
 		items, err := o.k.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
@@ -136,6 +219,9 @@ func (o *Options) getUpdatableServices(ctx context.Context, namespace string) ([
 
 		for i := range items.Items {
 			p := &items.Items[i]
+			if registered[p.Namespace] {
+				continue
+			}
 
 			serviceName := strings.Replace(p.Namespace, "--bento1a", "", 1)
 			if altServiceName, ok := serviceNameMap[serviceName]; ok {
@@ -145,38 +231,35 @@ func (o *Options) getUpdatableServices(ctx context.Context, namespace string) ([
 			svc, ok := services[serviceName]
 			if !ok {
 				svc = &service{
-					Name: serviceName,
+					Name:      serviceName,
+					Reference: serviceName,
 				}
 			}
 
-			for i := range p.Spec.Containers {
-				ref, err := dockerparser.Parse(p.Spec.Containers[i].Image)
+			found := false
+			for ii := range p.Spec.Containers {
+				ref, err := dockerparser.Parse(p.Spec.Containers[ii].Image)
 				if err != nil {
 					o.log.WithError(err).WithField("pod", p.Name).Warn("failed to determine if we can update service")
 					continue
 				}
 
-				// check if the container is in our registry
-				if !strings.Contains(ref.Remote(), o.b.DeveloperEnvironmentConfig.ImageRegistry) {
+				// Only images from our registry using the latest tag are updated
+				if !strings.Contains(ref.Remote(), o.b.DeveloperEnvironmentConfig.ImageRegistry) || ref.Tag() != "latest" {
 					continue
 				}
 
-				// skip containers w/ non-latest tags, we don't update those
-				if ref.Tag() != "latest" {
-					continue
+				found = true
+				if !contains(svc.Images, ref.Repository()) {
+					svc.Images = append(svc.Images, ref.Repository())
 				}
-
-				svc.Images = append(svc.Images, ref.Repository())
 			}
 
-			// if we found an image matching our needs, then we care about this pod
-			if len(svc.Images) != 0 {
+			if found {
 				svc.Pods = append(svc.Pods, &metav1.PartialObjectMetadata{
 					TypeMeta:   p.TypeMeta,
 					ObjectMeta: p.ObjectMeta,
 				})
-
-				// append the
 				services[svc.Name] = svc
 			}
 		}
@@ -187,16 +270,94 @@ func (o *Options) getUpdatableServices(ctx context.Context, namespace string) ([
 		}
 	}
 
-	servicesArray := make([]*service, len(services))
-	i := 0
-	for _, svc := range services {
-		servicesArray[i] = svc
-		i++
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	servicesArray := make([]*service, len(names))
+	for i, name := range names {
+		servicesArray[i] = services[name]
 	}
 
 	return servicesArray, nil
 }
 
+// findPods finds the pods of a service, and the images they use that
+// should be updated. These are the images recorded for the service.
+func (o *Options) findPods(ctx context.Context, e *appregistry.Entry, svc *service) error {
+	ctx = trace.StartCall(ctx, "kubernetes.GetPods")
+	defer trace.EndCall(ctx)
+
+	for _, namespace := range e.Namespaces {
+		pods, err := o.k.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+		if trace.SetCallStatus(ctx, err) != nil {
+			return errors.Wrap(err, "failed to get pods")
+		}
+
+		for i := range pods.Items {
+			p := &pods.Items[i]
+
+			found := false
+			for ii := range p.Spec.Containers {
+				ref, err := dockerparser.Parse(p.Spec.Containers[ii].Image)
+				if err != nil {
+					o.log.WithError(err).WithField("pod", p.Name).Warn("failed to determine if we can update service")
+					continue
+				}
+
+				if !o.isServiceImage(e, ref) {
+					continue
+				}
+
+				found = true
+				if !contains(svc.Images, ref.Repository()) {
+					svc.Images = append(svc.Images, ref.Repository())
+				}
+			}
+
+			if found {
+				svc.Pods = append(svc.Pods, &metav1.PartialObjectMetadata{
+					TypeMeta:   p.TypeMeta,
+					ObjectMeta: p.ObjectMeta,
+				})
+			}
+		}
+	}
+
+	return nil
+}
+
+// isServiceImage returns true if an image is one of the images of a
+// service, regardless of the registry it was pulled from
+func (o *Options) isServiceImage(e *appregistry.Entry, ref *dockerparser.Reference) bool {
+	for _, image := range e.Images {
+		imageRef, err := dockerparser.Parse(image)
+		if err != nil {
+			o.log.WithError(err).WithField("image", image).Warn("failed to parse image of service")
+			continue
+		}
+
+		if imageRef.ShortName() == ref.ShortName() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// contains returns true if list contains s
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (o *Options) removeImage(ctx context.Context, image string) error {
 	ctx = trace.StartCall(ctx, "updateapp.removeImage", olog.F{"image": image})
 	defer trace.EndCall(ctx)
@@ -213,7 +374,7 @@ func (o *Options) removeImage(ctx context.Context, image string) error {
 		"/bin/bash",
 		"-c",
 		// TODO: Replace this with a containerd call
-		fmt.Sprintf("docker exec %s crictl rmi %s >/dev/null 2>&1", containerruntime.ContainerName, image),
+		fmt.Sprintf("docker exec %s crictl rmi %s >/dev/null 2>&1", containerruntime.GetContainerName(o.clusterName), image),
 	)
 	return trace.SetCallStatus(ctx, err)
 }
@@ -275,7 +436,9 @@ func (o *Options) deployApp(ctx context.Context, svc *service) error {
 		return err
 	}
 
-	opt.App = svc.Name
+	// Only the service itself is being updated
+	opt.Apps = []string{svc.Reference}
+	opt.NoDeps = true
 	return opt.Run(ctx)
 }
 
@@ -315,7 +478,7 @@ func (o *Options) updateServices(ctx context.Context, services []*service) error
 }
 
 func (o *Options) Run(ctx context.Context) error {
-	b, err := box.LoadBox()
+	b, err := config.LoadBox()
 	if err != nil {
 		return err
 	}
@@ -329,13 +492,9 @@ func (o *Options) Run(ctx context.Context) error {
 	if _, err := devenvutil.EnsureDevenvRunning(ctx, conf, b); err != nil {
 		return err
 	}
+	_, o.clusterName = conf.ParseContext()
 
-	namespace := metav1.NamespaceAll
-	if o.AppName != "" {
-		namespace = o.AppName
-	}
-
-	services, err := o.getUpdatableServices(ctx, namespace)
+	services, err := o.getUpdatableServices(ctx)
 	if err != nil {
 		return err
 	}
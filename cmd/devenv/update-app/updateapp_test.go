@@ -0,0 +1,143 @@
+package updateapp
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/getoutreach/devenv/pkg/appregistry"
+	"github.com/getoutreach/gobox/pkg/box"
+	dockerparser "github.com/novln/docker-parser"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestOptions_getUpdatableServices(t *testing.T) {
+	pod := func(namespace, image string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: namespace},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Image: image}}},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		appName string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "should update deployed and discovered applications",
+			want: []string{"authz", "outreach-accounts"},
+		},
+		{
+			name:    "should update an application by name",
+			appName: "authz",
+			want:    []string{"authz"},
+		},
+		{
+			name:    "should update an application by namespace",
+			appName: "authz--bento1a",
+			want:    []string{"authz"},
+		},
+		{
+			name:    "should update an application that isn't in the registry by namespace",
+			appName: "outreach-accounts--bento1a",
+			want:    []string{"outreach-accounts"},
+		},
+		{
+			name:    "should fail for applications deployed from local disk",
+			appName: "flagship",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			k := fake.NewSimpleClientset(
+				pod("authz--bento1a", "gcr.io/outreach-docker/authz:latest"),
+				pod("flagship--bento1a", "gcr.io/outreach-docker/flagship:latest"),
+				pod("outreach-accounts--bento1a", "gcr.io/outreach-docker/outreach-accounts:latest"),
+				pod("kube-system", "k8s.gcr.io/coredns:1.8.0"),
+			)
+
+			r := appregistry.New(k)
+			for _, e := range []*appregistry.Entry{
+				{Name: "authz", Reference: "authz", Namespaces: []string{"authz--bento1a"}, Images: []string{"gcr.io/outreach-docker/authz"}},
+				{Name: "flagship", Reference: "/src/flagship", Local: true, Namespaces: []string{"flagship--bento1a"}},
+			} {
+				if err := r.Put(ctx, e); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			o := &Options{
+				log: logrus.New(),
+				k:   k,
+				b: &box.Config{DeveloperEnvironmentConfig: &box.DeveloperEnvironmentConfig{
+					ImageRegistry: "gcr.io/outreach-docker",
+				}},
+				AppName: tt.appName,
+			}
+
+			services, err := o.getUpdatableServices(ctx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getUpdatableServices() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var got []string
+			for _, svc := range services {
+				got = append(got, svc.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getUpdatableServices() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOptions_isServiceImage(t *testing.T) {
+	e := &appregistry.Entry{Name: "authz", Images: []string{"gcr.io/outreach-docker/authz"}}
+
+	tests := []struct {
+		name  string
+		image string
+		want  bool
+	}{
+		{
+			name:  "should match the image of the service",
+			image: "gcr.io/outreach-docker/authz:latest",
+			want:  true,
+		},
+		{
+			name:  "should match the image of the service from another registry",
+			image: "localhost:5001/outreach-docker/authz:v1.2.3",
+			want:  true,
+		},
+		{
+			name:  "should not match images whose path contains the image of the service",
+			image: "gcr.io/outreach-docker/authz-worker:latest",
+		},
+		{
+			name:  "should not match other images from our registry",
+			image: "gcr.io/outreach-docker/flagship:latest",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ref, err := dockerparser.Parse(tt.image)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			o := &Options{log: logrus.New()}
+			if got := o.isServiceImage(e, ref); got != tt.want {
+				t.Errorf("isServiceImage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -11,7 +11,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/getoutreach/devenv/pkg/appregistry"
+	"github.com/getoutreach/devenv/pkg/config"
 	"github.com/getoutreach/devenv/pkg/kubernetesruntime"
+	"github.com/getoutreach/devenv/pkg/repocache"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v2"
@@ -21,14 +24,16 @@ import (
 
 var validRepoReg = regexp.MustCompile(`^([A-Za-z_\-.])+$`)
 
-var repoCachePath = filepath.Join(".outreach", ".cache", "dev-environment", "deploy-app-v2")
-
 type Type string
 
 const (
 	TypeBootstrap Type = "bootstrap"
 	TypeLegacy    Type = "legacy"
 
+	// TypeScript is an application deployed by the commands in its
+	// manifest, see DeployConfig
+	TypeScript Type = "script"
+
 	DeleteJobAnnotation = "outreach.io/db-migration-delete"
 )
 
@@ -36,6 +41,7 @@ type App struct {
 	log  logrus.FieldLogger
 	k    kubernetes.Interface
 	conf *rest.Config
+	r    kubernetesruntime.Runtime
 	kr   *kubernetesruntime.RuntimeConfig
 
 	// Type is the type of application this is
@@ -51,39 +57,95 @@ type App struct {
 	// RepositoryName is the repository name for this application
 	RepositoryName string
 
+	// RepositoryURL is the URL the repository of this application is
+	// cloned from, unset for local applications
+	RepositoryURL string
+
+	// repositoryKey uniquely identifies the repository of this application,
+	// e.g. github.com/getoutreach/authz
+	repositoryKey string
+
+	// reference is what this application was referenced by, without the
+	// version. Local applications are referenced by their absolute path.
+	reference string
+
 	// Version is the version of this application that should be deployed.
 	// This is only used if RepositoryName is set and being used. This has no
 	// effect when Path is set.
 	Version string
-}
 
-func NewApp(log logrus.FieldLogger, k kubernetes.Interface, conf *rest.Config, appNameOrPath string, kr *kubernetesruntime.RuntimeConfig) (*App, error) {
-	version := ""
-	versionSplit := strings.SplitN(appNameOrPath, "@", 2)
+	// Manifest is the .devenv.yaml of this application, if it has one
+	Manifest *Manifest
 
-	if len(versionSplit) == 2 {
-		appNameOrPath = versionSplit[0]
-		version = versionSplit[1]
-	}
+	// WaitTimeout is how long to wait for the application to become ready
+	// after deploying it, defaults to DefaultHealthCheckTimeout
+	WaitTimeout time.Duration
+
+	// Commit is the git commit of this application, if it's in a git
+	// repository
+	Commit string
+
+	// cachedImages are the IDs of previously built images, by name, that
+	// are used instead of building them again, e.g. when rolling back
+	cachedImages map[string]string
+
+	// imageIDs are the IDs of the images built for this application, by
+	// name
+	imageIDs map[string]string
+
+	// dryRun denotes that this application is only inspected, its
+	// repository is fetched without pruning the repository cache
+	dryRun bool
+}
+
+func NewApp(log logrus.FieldLogger, k kubernetes.Interface, conf *rest.Config, appNameOrPath string, r kubernetesruntime.Runtime) (*App, error) {
+	appNameOrPath, version := splitVersion(appNameOrPath)
 
-	// if not a valid Github repository name or is a current directory or lower directory reference, then
-	// run as local
 	app := App{
 		k:              k,
 		conf:           conf,
-		kr:             kr,
+		r:              r,
 		Version:        version,
 		RepositoryName: appNameOrPath,
+		reference:      appNameOrPath,
+	}
+
+	if r != nil {
+		kr := r.GetConfig()
+		app.kr = &kr
 	}
 
-	if !validRepoReg.MatchString(appNameOrPath) || appNameOrPath == "." || appNameOrPath == ".." {
+	// if not a repository name, reference or URL, then run as local
+	if isLocalPath(appNameOrPath) {
 		app.Path = appNameOrPath
 		app.Local = true
-		app.RepositoryName = filepath.Base(appNameOrPath)
 
 		if version != "" {
 			return nil, fmt.Errorf("when deploying a local-app a version must not be set")
 		}
+
+		name, err := localAppName(appNameOrPath)
+		if err != nil {
+			return nil, err
+		}
+		app.RepositoryName = name
+
+		if absPath, err := filepath.Abs(appNameOrPath); err == nil { //nolint:govet // Why: We're OK shadowing err
+			app.reference = absPath
+		}
+	} else {
+		b, err := config.LoadBoxConfig()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load box config")
+		}
+
+		repo, err := parseRepository(appNameOrPath, &b.DeveloperEnvironmentConfig.Repositories)
+		if err != nil {
+			return nil, err
+		}
+		app.RepositoryName = repo.Name
+		app.RepositoryURL = repo.URL
+		app.repositoryKey = repo.Key
 	}
 
 	fields := logrus.Fields{
@@ -97,39 +159,37 @@ func NewApp(log logrus.FieldLogger, k kubernetes.Interface, conf *rest.Config, a
 	return &app, nil
 }
 
-func (a *App) downloadRepository(ctx context.Context, repo string) (cleanup func(), err error) {
-	homeDir, err := os.UserHomeDir()
+// downloadRepository checks out the repository of the application from
+// the repository cache, see repocache.Cache.Checkout
+func (a *App) downloadRepository(ctx context.Context) (cleanup func(), err error) {
+	cache, err := repocache.NewDefault()
 	if err != nil {
 		return func() {}, err
 	}
 
-	// on macOS we seem to lose contents of temp directories, so now we need to do this
-	tempDir := filepath.Join(homeDir, repoCachePath, repo, time.Now().Format(time.RFC3339Nano))
-	cleanup = func() {
-		os.RemoveAll(tempDir)
-	}
+	a.log.WithField("app.repository", a.RepositoryURL).Info("Fetching Application")
 
-	if err := os.MkdirAll(tempDir, 0755); err != nil { //nolint:govet // Why: We're okay with shadowing the error.
+	// Repositories with the same name can exist on different hosts, or in
+	// different orgs
+	cacheName := strings.ReplaceAll(a.repositoryKey, "/", "_")
+	path, cleanup, err := cache.Checkout(ctx, a.log, a.RepositoryURL, cacheName, a.Version)
+	if err != nil {
 		return cleanup, err
 	}
 
-	args := []string{"clone", "git@github.com:getoutreach/" + a.RepositoryName, tempDir}
-	if a.Version != "" {
-		args = append(args, "--branch", a.Version, "--depth", "1")
-	}
-
-	a.log.Info("Fetching Application")
-
-	cmd := exec.CommandContext(ctx, "git", args...) //nolint:gosec // Why: We're using git here because of it's ability to better handle mixed input
-	b, err := cmd.CombinedOutput()
-	if err != nil {
-		fmt.Println(string(b))
-		return cleanup, err
+	// Keep the cache from growing unbounded, repositories being deployed
+	// are never pruned. Dry runs only add to the cache.
+	if a.dryRun {
+		a.log.WithField("cache", cache.Dir()).Info("Fetched application into the repository cache")
+	} else if removed, err := cache.PruneIfDue(repocache.DefaultPruneInterval, repocache.DefaultMaxAge, repocache.DefaultMaxSize); err != nil { //nolint:govet // Why: We're OK shadowing err
+		a.log.WithError(err).Warn("failed to prune repository cache")
+	} else if len(removed) != 0 {
+		a.log.WithField("repositories", len(removed)).Info("Pruned repository cache")
 	}
 
-	cmd = exec.CommandContext(ctx, "git", "describe", "--tags")
-	cmd.Dir = tempDir
-	b, err = cmd.Output()
+	cmd := exec.CommandContext(ctx, "git", "describe", "--tags")
+	cmd.Dir = path
+	b, err := cmd.Output()
 	if err == nil {
 		ver := strings.TrimSpace(string(b))
 		if ver != a.Version {
@@ -137,13 +197,56 @@ func (a *App) downloadRepository(ctx context.Context, repo string) (cleanup func
 		}
 	}
 
-	// Set the path of the app to the downloaded repository in the temporary directory.
-	a.Path = tempDir
+	// Set the path of the app to the checked out repository.
+	a.Path = path
+
+	return cleanup, nil
+}
+
+// prepare downloads the repository of the application, if it isn't
+// already on disk, and determines its type and name. The returned function
+// removes the downloaded repository and must always be called.
+func (a *App) prepare(ctx context.Context) (cleanup func(), err error) {
+	cleanup = func() {}
+
+	// Download the repository if it doesn't already exist on disk.
+	if a.Path == "" {
+		cleanup, err = a.downloadRepository(ctx)
+		if err != nil {
+			return cleanup, err
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, "git", "rev-parse", "HEAD")
+	cmd.Dir = a.Path
+	if b, err := cmd.Output(); err == nil { //nolint:govet // Why: We're OK shadowing err
+		a.Commit = strings.TrimSpace(string(b))
+	}
+
+	if err := a.determineType(); err != nil { //nolint:govet // Why: We're OK shadowing err
+		return cleanup, errors.Wrap(err, "determine repository type")
+	}
+
+	if err := a.determineRepositoryName(); err != nil { //nolint:govet // Why: We're OK shadowing err
+		return cleanup, errors.Wrap(err, "determine repository name")
+	}
+	a.log = a.log.WithField("app.name", a.RepositoryName)
 
 	return cleanup, nil
 }
 
 func (a *App) determineType() error {
+	m, err := LoadManifest(a.log, a.Path)
+	if err != nil {
+		return err
+	}
+	a.Manifest = m
+
+	if a.Manifest != nil && a.Manifest.Deploy.Method != "" {
+		a.Type = a.Manifest.Deploy.Method
+		return nil
+	}
+
 	serviceYamlPath := filepath.Join(a.Path, "service.yaml")
 	deployScriptPath := filepath.Join(a.Path, "scripts", "deploy-to-dev.sh")
 
@@ -152,20 +255,35 @@ func (a *App) determineType() error {
 	} else if _, err := os.Stat(deployScriptPath); err == nil {
 		a.Type = TypeLegacy
 	} else {
-		return fmt.Errorf("failed to determine application type, no %s or %s", serviceYamlPath, deployScriptPath)
+		return fmt.Errorf("failed to determine application type, no %s, %s or %s",
+			filepath.Join(a.Path, ManifestFile), serviceYamlPath, deployScriptPath)
 	}
 
 	return nil
 }
 
 func (a *App) determineRepositoryName() error {
+	if a.Manifest != nil && a.Manifest.Name != "" {
+		a.RepositoryName = a.Manifest.Name
+		return nil
+	}
+
 	if a.Type != TypeBootstrap {
-		if a.Path != "" && a.Path != "." && a.Path != ".." && a.Path != "../" {
-			a.RepositoryName = filepath.Base(a.Path)
+		// Downloaded repositories are already named after their repository
+		if !a.Local && a.RepositoryName != "" {
 			return nil
 		}
 
-		return errors.New("could not determine repository name")
+		if a.Path == "" {
+			return errors.New("could not determine repository name")
+		}
+
+		name, err := localAppName(a.Path)
+		if err != nil {
+			return err
+		}
+		a.RepositoryName = name
+		return nil
 	}
 
 	b, err := ioutil.ReadFile(filepath.Join(a.Path, "service.yaml"))
@@ -186,3 +304,87 @@ func (a *App) determineRepositoryName() error {
 	a.RepositoryName = conf.Name
 	return nil
 }
+
+// namespaces returns the namespaces this application is deployed into
+func (a *App) namespaces() []string {
+	if a.Manifest != nil && len(a.Manifest.Namespaces) != 0 {
+		return a.Manifest.Namespaces
+	}
+
+	return defaultNamespaces(a.RepositoryName)
+}
+
+// defaultNamespaces returns the namespaces an application is deployed
+// into when its manifest doesn't declare any
+func defaultNamespaces(name string) []string {
+	return []string{name, name + "--bento1a"}
+}
+
+// images returns the docker images built for this application, bootstrap
+// applications without a manifest build a single image from the
+// Dockerfile in deployments/<name>, or with make if they don't have one
+func (a *App) images() []ImageConfig {
+	if a.Manifest != nil && len(a.Manifest.Images) != 0 {
+		return a.Manifest.Images
+	}
+
+	if a.Type != TypeBootstrap {
+		return nil
+	}
+
+	img := ImageConfig{
+		Name: "gcr.io/outreach-docker/" + a.RepositoryName,
+	}
+
+	dockerfile := filepath.Join("deployments", a.RepositoryName, "Dockerfile")
+	if _, err := os.Stat(filepath.Join(a.Path, dockerfile)); err == nil {
+		img.Dockerfile = dockerfile
+		if a.Version != "" {
+			img.BuildArgs = map[string]string{"VERSION": a.Version}
+		}
+	} else {
+		img.Command = []string{"make", "docker-build"}
+	}
+
+	return []ImageConfig{img}
+}
+
+// recordDeployed records whether this application is deployed, in the
+// registry of the cluster and the devenv config of its runtime's context
+func (a *App) recordDeployed(ctx context.Context, deployed bool) error {
+	registry := appregistry.New(a.k)
+	if deployed {
+		images := a.images()
+		e := &appregistry.Entry{
+			Name:       a.RepositoryName,
+			Reference:  a.reference,
+			Version:    a.Version,
+			Commit:     a.Commit,
+			Local:      a.Local,
+			DeployedAt: time.Now().UTC(),
+			Namespaces: a.namespaces(),
+			Images:     make([]string, len(images)),
+			ImageIDs:   a.imageIDs,
+		}
+		for i := range images {
+			e.Images[i] = images[i].Name
+		}
+
+		if err := registry.Put(ctx, e); err != nil {
+			return err
+		}
+	} else if err := registry.Remove(ctx, a.RepositoryName); err != nil {
+		return err
+	}
+
+	err := config.UpdateConfig(ctx, func(conf *config.Config) error {
+		cc := conf.EnsureContextConfig(config.ContextName(a.kr.Name, a.kr.ClusterName))
+		if deployed {
+			cc.AddDeployedApp(a.RepositoryName)
+		} else {
+			cc.RemoveDeployedApp(a.RepositoryName)
+		}
+		return nil
+	})
+	return errors.Wrap(err, "failed to save deployed applications to devenv config")
+}
@@ -3,8 +3,6 @@ package app
 import (
 	"context"
 	"fmt"
-	"os"
-	"path/filepath"
 
 	"github.com/getoutreach/devenv/pkg/cmdutil"
 	"github.com/getoutreach/devenv/pkg/kubernetesruntime"
@@ -14,68 +12,48 @@ import (
 	"k8s.io/client-go/rest"
 )
 
-func Delete(ctx context.Context, log logrus.FieldLogger, k kubernetes.Interface, conf *rest.Config, appNameOrPath string, kr kubernetesruntime.RuntimeConfig) error {
-	app, err := NewApp(log, k, conf, appNameOrPath, &kr)
+func Delete(ctx context.Context, log logrus.FieldLogger, k kubernetes.Interface, conf *rest.Config, appNameOrPath string, kr kubernetesruntime.Runtime) error {
+	app, err := NewApp(log, k, conf, appNameOrPath, kr)
 	if err != nil {
 		return errors.Wrap(err, "parse app")
 	}
 
-	return app.Delete(ctx)
-}
-
-// deleteLegacy attempts to delete an application by running the file at
-// ./scripts/deploy-to-dev.sh, relative to the repository root.
-func (a *App) deleteLegacy(ctx context.Context) error {
-	a.log.Info("Deleting application from devenv...")
-	return errors.Wrap(cmdutil.RunKubernetesCommand(ctx, a.Path, true, "./scripts/deploy-to-dev.sh", "delete"), "failed to delete application")
-}
-
-func (a *App) deleteBootstrap(ctx context.Context) error {
-	if err := a.determineRepositoryName(); err != nil {
-		return errors.Wrap(err, "determine repository name")
+	if err := app.Delete(ctx); err != nil { //nolint:govet // Why: We're OK shadowing err
+		return err
 	}
-	a.log = a.log.WithField("app.name", a.RepositoryName)
 
-	a.log.Info("Deleting application from devenv...")
-
-	deployScript := "./scripts/deploy-to-dev.sh"
-	deployScriptArgs := []string{"delete"}
-
-	// Cheap way of detecting bootstrap v6 w/o importing bootstrap.lock
-	if _, err := os.Stat(filepath.Join(a.Path, "scripts", "shell-wrapper.sh")); err == nil {
-		deployScript = "./scripts/shell-wrapper.sh"
-		deployScriptArgs = append([]string{"deploy-to-dev.sh"}, deployScriptArgs...)
-	}
+	return app.recordDeployed(ctx, false)
+}
 
-	if err := cmdutil.RunKubernetesCommand(ctx, a.Path, true, deployScript, deployScriptArgs...); err != nil {
-		return errors.Wrap(err, "failed to delete application")
+// deleteCommand returns the command, ran from the root of the repository,
+// that deletes the application
+func (a *App) deleteCommand() ([]string, error) {
+	switch a.Type {
+	case TypeBootstrap, TypeLegacy:
+		return a.scriptCommand("delete"), nil
+	case TypeScript:
+		if len(a.Manifest.Deploy.DeleteCommand) == 0 {
+			return nil, fmt.Errorf("deploy.deleteCommand isn't set in %s", ManifestFile)
+		}
+		return a.Manifest.Deploy.DeleteCommand, nil
 	}
 
-	return nil
+	// If this ever fires, there is an issue with *App.determineType.
+	return nil, fmt.Errorf("unknown application type %s", a.Type)
 }
 
 func (a *App) Delete(ctx context.Context) error {
-	// Download the repository if it doesn't already exist on disk.
-	if a.Path == "" {
-		cleanup, err := a.downloadRepository(ctx, a.RepositoryName)
-		defer cleanup()
-
-		if err != nil {
-			return err
-		}
-	}
-
-	if err := a.determineType(); err != nil {
-		return errors.Wrap(err, "determine repository type")
+	cleanup, err := a.prepare(ctx)
+	defer cleanup()
+	if err != nil {
+		return err
 	}
 
-	switch a.Type {
-	case TypeBootstrap:
-		return a.deleteBootstrap(ctx)
-	case TypeLegacy:
-		return a.deleteLegacy(ctx)
+	cmd, err := a.deleteCommand()
+	if err != nil {
+		return err
 	}
 
-	// If this ever fires, there is an issue with *App.determineType.
-	return fmt.Errorf("unknown application type %s", a.Type)
+	a.log.Info("Deleting application from devenv...")
+	return errors.Wrap(cmdutil.RunKubernetesCommand(ctx, a.Path, true, cmd[0], cmd[1:]...), "failed to delete application")
 }
@@ -7,6 +7,8 @@ import (
 	"path/filepath"
 	"strings"
 
+	dockerclient "github.com/docker/docker/client"
+	"github.com/getoutreach/devenv/pkg/appregistry"
 	"github.com/getoutreach/devenv/pkg/cmdutil"
 	"github.com/getoutreach/devenv/pkg/devenvutil"
 	"github.com/getoutreach/devenv/pkg/kubernetesruntime"
@@ -17,6 +19,7 @@ import (
 	"github.com/sirupsen/logrus"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -25,106 +28,149 @@ import (
 )
 
 // Deploy deploys an application by name, to the devenv.
-func Deploy(ctx context.Context, log logrus.FieldLogger, k kubernetes.Interface, conf *rest.Config, appNameOrPath string, kr kubernetesruntime.RuntimeConfig) error {
-	app, err := NewApp(log, k, conf, appNameOrPath, &kr)
+func Deploy(ctx context.Context, log logrus.FieldLogger, k kubernetes.Interface, conf *rest.Config, appNameOrPath string, kr kubernetesruntime.Runtime) error {
+	app, err := NewApp(log, k, conf, appNameOrPath, kr)
 	if err != nil {
 		return errors.Wrap(err, "parse app")
 	}
 
-	return app.Deploy(ctx)
-}
+	if err := app.Deploy(ctx); err != nil { //nolint:govet // Why: We're OK shadowing err
+		return err
+	}
 
-// deployLegacy attempts to deploy an application by running the file at
-// ./scripts/deploy-to-dev.sh, relative to the repository root.
-func (a *App) deployLegacy(ctx context.Context) error {
-	a.log.Info("Deploying application into devenv...")
-	return errors.Wrap(cmdutil.RunKubernetesCommand(ctx, a.Path, true, "./scripts/deploy-to-dev.sh", "update"), "failed to deploy changes")
+	return app.recordDeployed(ctx, true)
 }
 
-func (a *App) deployBootstrap(ctx context.Context) error { //nolint:funlen
-	if err := a.determineRepositoryName(); err != nil {
-		return errors.Wrap(err, "determine repository name")
-	}
-	a.log = a.log.WithField("app.name", a.RepositoryName)
-
-	// Only build a docker image if we're not using the latest version
-	// or if we're in local mode
-	builtDockerImage := false
-	if a.Version != "" || a.Local {
-		if a.kr.Type == kubernetesruntime.RuntimeTypeLocal {
-			if err := a.buildDockerImage(ctx); err != nil {
-				return errors.Wrap(err, "failed to build image")
-			}
-			builtDockerImage = true
-		} else {
-			a.log.Warn("Skipping docker image build, not supported with remote clusters")
+// scriptCommand returns the command that runs the deploy-to-dev.sh
+// script of a bootstrap or legacy application with an action, e.g. update
+// or delete
+func (a *App) scriptCommand(action string) []string {
+	// Cheap way of detecting bootstrap v6 w/o importing bootstrap.lock
+	if a.Type == TypeBootstrap {
+		if _, err := os.Stat(filepath.Join(a.Path, "scripts", "shell-wrapper.sh")); err == nil {
+			return []string{"./scripts/shell-wrapper.sh", "deploy-to-dev.sh", action}
 		}
 	}
 
-	a.log.Info("Deploying application into devenv...")
-
-	deployScript := "./scripts/deploy-to-dev.sh"
-	deployScriptArgs := []string{"update"}
+	return []string{"./scripts/deploy-to-dev.sh", action}
+}
 
-	// Cheap way of detecting bootstrap v6 w/o importing bootstrap.lock
-	if _, err := os.Stat(filepath.Join(a.Path, "scripts", "shell-wrapper.sh")); err == nil {
-		deployScript = "./scripts/shell-wrapper.sh"
-		deployScriptArgs = append([]string{"deploy-to-dev.sh"}, deployScriptArgs...)
+// deployCommand returns the command, ran from the root of the repository,
+// that deploys the application
+func (a *App) deployCommand() ([]string, error) {
+	switch a.Type {
+	case TypeBootstrap, TypeLegacy:
+		return a.scriptCommand("update"), nil
+	case TypeScript:
+		return a.Manifest.Deploy.Command, nil
 	}
 
-	if err := cmdutil.RunKubernetesCommand(ctx, a.Path, true, deployScript, deployScriptArgs...); err != nil {
-		return errors.Wrap(err, "failed to deploy changes")
+	// If this ever fires, there is an issue with *App.determineType.
+	return nil, fmt.Errorf("unknown application type %s", a.Type)
+}
+
+// imagePath returns the path of an image without the registry, e.g.
+// outreach-docker/flagship for gcr.io/outreach-docker/flagship
+func imagePath(image string) string {
+	spl := strings.SplitN(image, "/", 2)
+	if len(spl) != 2 {
+		return image
 	}
 
-	if builtDockerImage {
-		// Delete pods to ensure they are using the latest docker image we pushed
-		return devenvutil.DeleteObjects(ctx, a.log, a.k, a.conf, devenvutil.DeleteObjectsObjects{
-			Namespaces: []string{a.RepositoryName + "--bento1a"},
-			// TODO: We have to be able to get this information elsewhere.
-			Type: &corev1.Pod{
-				TypeMeta: v1.TypeMeta{
-					Kind:       "Pod",
-					APIVersion: corev1.SchemeGroupVersion.Identifier(),
-				},
+	return spl[1]
+}
+
+// imagePods selects the pods of this application that use one of the
+// given images
+func (a *App) imagePods(images []ImageConfig) devenvutil.DeleteObjectsObjects {
+	return devenvutil.DeleteObjectsObjects{
+		Namespaces: a.namespaces(),
+		Type: &corev1.Pod{
+			TypeMeta: v1.TypeMeta{
+				Kind:       "Pod",
+				APIVersion: corev1.SchemeGroupVersion.Identifier(),
 			},
-			Validator: func(obj *unstructured.Unstructured) bool {
-				var pod *corev1.Pod
-				err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &pod)
+		},
+		Validator: func(obj *unstructured.Unstructured) bool {
+			var pod *corev1.Pod
+			err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &pod)
+			if err != nil {
+				return true
+			}
+
+			for i := range pod.Spec.Containers {
+				cont := &pod.Spec.Containers[i]
+
+				ref, err := dockerparser.Parse(cont.Image)
 				if err != nil {
-					return true
+					continue
 				}
 
-				for i := range pod.Spec.Containers {
-					cont := &pod.Spec.Containers[i]
-
-					ref, err := dockerparser.Parse(cont.Image)
-					if err != nil {
-						continue
+				// check if it matched one of our application's images, regardless
+				// of the registry it was pulled from
+				for ii := range images {
+					if strings.Contains(ref.Name(), imagePath(images[ii].Name)) {
+						// return false here to not filter out the pod
+						// because we found a container we wanted
+						return false
 					}
+				}
+			}
 
-					// check if it matched our applications image name.
-					// eventually we should do a better job at checking this (not building it ourself)
-					if !strings.Contains(ref.Name(), fmt.Sprintf("outreach-docker/%s", a.RepositoryName)) {
-						continue
-					}
+			return true
+		},
+	}
+}
 
-					// return false here to not filter out the pod
-					// because we found a container we wanted
-					return false
-				}
+// restartPods deletes the pods of this application that use one of the
+// given images, to ensure they are using the latest docker image we pushed
+func (a *App) restartPods(ctx context.Context, images []ImageConfig) error {
+	return devenvutil.DeleteObjects(ctx, a.log, a.k, a.conf, a.imagePods(images))
+}
 
-				return true
+// migrationJobs selects the jobs of this application with a db-migration
+// annotation, these are deleted before deploying so they run again
+func (a *App) migrationJobs() devenvutil.DeleteObjectsObjects {
+	return devenvutil.DeleteObjectsObjects{
+		Namespaces: a.namespaces(),
+		// TODO: We have to be able to get this information elsewhere.
+		Type: &batchv1.Job{
+			TypeMeta: v1.TypeMeta{
+				Kind:       "Job",
+				APIVersion: batchv1.SchemeGroupVersion.Identifier(),
 			},
-		})
+		},
+		Validator: func(obj *unstructured.Unstructured) bool {
+			var job *batchv1.Job
+			err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &job)
+			if err != nil {
+				return true
+			}
+
+			// filter jobs without our annotation
+			return job.Annotations[DeleteJobAnnotation] != "true"
+		},
 	}
+}
 
-	return nil
+// existingNamespaces returns which namespaces of this application exist
+func (a *App) existingNamespaces(ctx context.Context) map[string]bool {
+	existing := make(map[string]bool)
+	for _, ns := range a.namespaces() {
+		_, err := a.k.CoreV1().Namespaces().Get(ctx, ns, v1.GetOptions{})
+		if err == nil || !kerrors.IsNotFound(err) {
+			// When in doubt, assume it existed so it's never removed
+			existing[ns] = true
+		}
+	}
+
+	return existing
 }
 
-// buildDockerImage builds a docker image from a bootstrap repo
-// and deploys it into the developer environment cache
-func (a *App) buildDockerImage(ctx context.Context) error {
-	ctx = trace.StartCall(ctx, "deployapp.buildDockerImage")
+// buildDockerImages builds the docker images of an application
+// and deploys them into the developer environment cache
+func (a *App) buildDockerImages(ctx context.Context, images []ImageConfig) error {
+	ctx = trace.StartCall(ctx, "deployapp.buildDockerImages")
 	defer trace.EndCall(ctx)
 
 	a.log.Info("Configuring ssh-agent for Docker")
@@ -136,87 +182,180 @@ func (a *App) buildDockerImage(ctx context.Context) error {
 		return errors.Wrap(err, "failed to load Github SSH key into in-memory keyring")
 	}
 
-	a.log.Info("Building Docker image (this may take awhile)")
-	err = cmdutil.RunKubernetesCommand(ctx, a.Path, true, "make", "docker-build")
+	for i := range images {
+		if err := a.buildDockerImage(ctx, &images[i]); err != nil {
+			return errors.Wrapf(err, "failed to build image %s", images[i].Name)
+		}
+	}
+
+	return nil
+}
+
+// buildDockerImage builds a docker image and deploys it into the
+// developer environment cache. If the image was built before, see
+// App.cachedImages, and still exists it's used instead.
+func (a *App) buildDockerImage(ctx context.Context, img *ImageConfig) error { //nolint:funlen
+	log := a.log.WithField("image", img.Name)
+
+	d, err := dockerclient.NewClientWithOpts(dockerclient.FromEnv, dockerclient.WithAPIVersionNegotiation())
+	if err != nil {
+		return errors.Wrap(err, "failed to create docker client")
+	}
+	defer d.Close()
+
+	if id, ok := a.cachedImages[img.Name]; ok && tagImage(ctx, d, id, img.Name) == nil {
+		log.WithField("image.id", id).Info("Using previously built Docker image")
+	} else {
+		log.Info("Building Docker image (this may take awhile)")
+		if len(img.Command) != 0 {
+			err = cmdutil.RunKubernetesCommand(ctx, a.Path, true, img.Command[0], img.Command[1:]...)
+		} else {
+			err = a.dockerBuild(ctx, d, log, img)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// Remember the image so it can be reused when rolling back
+	if id, err := imageID(ctx, d, img.Name); err == nil { //nolint:govet // Why: We're OK shadowing err
+		if a.imageIDs == nil {
+			a.imageIDs = make(map[string]string)
+		}
+		a.imageIDs[img.Name] = id
+	}
+
+	log.Info("Pushing built Docker Image into Kubernetes")
+	image := img.Name
+
+	if a.kr.LocalRegistry != "" {
+		return a.pushToLocalRegistry(ctx, d, image)
+	}
+
+	err = a.r.LoadImage(ctx, image)
+	return errors.Wrap(err, "failed to push docker image to Kubernetes")
+}
+
+// pushToLocalRegistry pushes an image to the local registry of the
+// cluster. The cluster prefers images from the local registry over the
+// image registry, so only the path of the image is kept. Only changed
+// layers are pushed.
+func (a *App) pushToLocalRegistry(ctx context.Context, d dockerclient.APIClient, image string) error {
+	localImage := a.kr.LocalRegistry + "/" + imagePath(image)
+
+	a.log.WithField("registry", a.kr.LocalRegistry).Info("Pushing Docker image to local registry")
+	if err := tagImage(ctx, d, image, localImage); err != nil {
+		return errors.Wrap(err, "failed to tag docker image for local registry")
+	}
+
+	return errors.Wrap(pushImage(ctx, d, localImage), "failed to push docker image to local registry")
+}
+
+func (a *App) Deploy(ctx context.Context) error {
+	cleanup, err := a.prepare(ctx)
+	defer cleanup()
 	if err != nil {
 		return err
 	}
 
-	a.log.Info("Pushing built Docker Image into Kubernetes")
-	//nolint:staticcheck // Why: we're aware of the deprecation
-	kindPath, err := kubernetesruntime.EnsureKind(a.log)
+	return a.deploy(ctx)
+}
+
+// deploy deploys an already prepared application, see App.prepare
+func (a *App) deploy(ctx context.Context) error {
+	built, err := a.buildImages(ctx)
 	if err != nil {
-		return errors.Wrap(err, "failed to find/download Kind")
+		return err
 	}
 
-	err = cmdutil.RunKubernetesCommand(
-		ctx,
-		a.Path,
-		true,
-		kindPath,
-		"load",
-		"docker-image",
-		fmt.Sprintf("gcr.io/outreach-docker/%s", a.RepositoryName),
-		"--name",
-		kubernetesruntime.KindClusterName,
-	)
+	if err := a.runDeploy(ctx, built); err != nil { //nolint:govet // Why: We're OK shadowing err
+		return err
+	}
 
-	return errors.Wrap(err, "failed to push docker image to Kubernetes")
+	return a.waitUntilReady(ctx)
 }
 
-func (a *App) Deploy(ctx context.Context) error { //nolint:funlen
-	// Download the repository if it doesn't already exist on disk.
-	if a.Path == "" {
-		cleanup, err := a.downloadRepository(ctx, a.RepositoryName)
-		defer cleanup()
+// imagesUnsupported is the reason images aren't built when the kubernetes
+// runtime can't load images
+const imagesUnsupported = "not supported by this kubernetes runtime"
 
-		if err != nil {
-			return err
-		}
+// imagesToBuild returns the docker images of the application that need
+// to be built, if none are the reason is returned
+func (a *App) imagesToBuild() (images []ImageConfig, reason string) {
+	// Only build docker images if we're not using the latest version
+	// or if we're in local mode
+	images = a.images()
+	if len(images) == 0 {
+		return nil, "application has no images"
 	}
 
-	if err := a.determineType(); err != nil {
-		return errors.Wrap(err, "determine repository type")
+	if a.Version == "" && !a.Local {
+		return nil, "latest images are pulled from the image registry"
 	}
 
-	// Delete all jobs with a db-migration annotation.
+	if !a.kr.Capabilities.CanLoadImages {
+		return nil, imagesUnsupported
+	}
 
-	err := devenvutil.DeleteObjects(ctx, a.log, a.k, a.conf, devenvutil.DeleteObjectsObjects{
-		Namespaces: []string{a.RepositoryName, fmt.Sprintf("%s--bento1a", a.RepositoryName)},
-		// TODO: We have to be able to get this information elsewhere.
-		Type: &batchv1.Job{
-			TypeMeta: v1.TypeMeta{
-				Kind:       "Job",
-				APIVersion: batchv1.SchemeGroupVersion.Identifier(),
-			},
-		},
-		Validator: func(obj *unstructured.Unstructured) bool {
-			var job *batchv1.Job
-			err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &job)
-			if err != nil {
-				return true
-			}
+	return images, ""
+}
 
-			// filter jobs without our annotation
-			return job.Annotations[DeleteJobAnnotation] != "true"
-		},
-	})
+// buildImages builds the docker images of the application, if needed,
+// and returns the images that were built
+func (a *App) buildImages(ctx context.Context) ([]ImageConfig, error) {
+	images, reason := a.imagesToBuild()
+	if len(images) == 0 {
+		if reason == imagesUnsupported {
+			a.log.Warn("Skipping docker image build, not supported by this kubernetes runtime")
+		}
+		return nil, nil
+	}
+
+	if err := a.buildDockerImages(ctx, images); err != nil {
+		return nil, errors.Wrap(err, "failed to build images")
+	}
 
+	return images, nil
+}
+
+// runDeploy deploys the application into the devenv, pods using one of
+// the built images are restarted afterwards
+func (a *App) runDeploy(ctx context.Context, built []ImageConfig) error {
+	// Delete all jobs with a db-migration annotation.
+	err := devenvutil.DeleteObjects(ctx, a.log, a.k, a.conf, a.migrationJobs())
 	if err != nil {
 		a.log.WithError(err).Error("failed to delete jobs")
 	}
 
-	switch a.Type {
-	case TypeBootstrap:
-		err = a.deployBootstrap(ctx)
-	case TypeLegacy:
-		err = a.deployLegacy(ctx)
-	default:
-		err = fmt.Errorf("unknown application type %s", a.Type)
-	}
+	cmd, err := a.deployCommand()
 	if err != nil {
 		return err
 	}
 
-	return devenvutil.WaitForAllPodsToBeReady(ctx, a.k, a.log)
+	existing := a.existingNamespaces(ctx)
+
+	a.log.Info("Deploying application into devenv...")
+	if err := cmdutil.RunKubernetesCommand(ctx, a.Path, true, cmd[0], cmd[1:]...); err != nil { //nolint:govet // Why: We're OK shadowing err
+		return errors.Wrap(err, "failed to deploy changes")
+	}
+
+	// Namespaces created by the deploy are owned by devenv, so they can be
+	// removed from clusters devenv doesn't own
+	created := make([]string, 0)
+	for _, ns := range a.namespaces() {
+		if !existing[ns] {
+			created = append(created, ns)
+		}
+	}
+	if err := appregistry.MarkNamespacesManaged(ctx, a.k, created); err != nil { //nolint:govet // Why: We're OK shadowing err
+		a.log.WithError(err).Warn("failed to label created namespaces")
+	}
+
+	if len(built) != 0 {
+		if err := a.restartPods(ctx, built); err != nil { //nolint:govet // Why: We're OK shadowing err
+			return errors.Wrap(err, "failed to restart pods")
+		}
+	}
+
+	return nil
 }
@@ -0,0 +1,313 @@
+package app
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	dockerclient "github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/jsonmessage"
+	controlapi "github.com/moby/buildkit/api/services/control"
+	"github.com/moby/buildkit/frontend/dockerfile/dockerignore"
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/session/secrets/secretsprovider"
+	"github.com/moby/buildkit/session/sshforward/sshprovider"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// buildkitTraceID is the ID of the JSON messages that contain BuildKit
+// build progress
+const buildkitTraceID = "moby.buildkit.trace"
+
+// dockerBuildOptions returns the build context, relative to the root of
+// the repository, and the options to build an image with BuildKit
+func dockerBuildOptions(img *ImageConfig) (string, types.ImageBuildOptions, error) {
+	buildContext := img.Context
+	if buildContext == "" {
+		buildContext = "."
+	}
+
+	dockerfile := img.Dockerfile
+	if dockerfile == "" {
+		dockerfile = filepath.Join(buildContext, "Dockerfile")
+	}
+
+	// The Dockerfile is sent to the daemon as part of the build context
+	dockerfile, err := filepath.Rel(buildContext, dockerfile)
+	if err != nil || dockerfile == ".." || strings.HasPrefix(dockerfile, ".."+string(filepath.Separator)) {
+		return "", types.ImageBuildOptions{}, errors.Errorf("dockerfile %s isn't in the build context %s", img.Dockerfile, buildContext)
+	}
+
+	buildArgs := make(map[string]*string, len(img.BuildArgs))
+	for k := range img.BuildArgs {
+		v := img.BuildArgs[k]
+		buildArgs[k] = &v
+	}
+
+	return buildContext, types.ImageBuildOptions{
+		Version:    types.BuilderBuildKit,
+		Tags:       []string{img.Name},
+		Dockerfile: filepath.ToSlash(dockerfile),
+		Target:     img.Target,
+		BuildArgs:  buildArgs,
+		Remove:     true,
+	}, nil
+}
+
+// secretSources returns the sources of the secrets of an image, paths
+// are relative to the root of the repository, or the user's home
+// directory if they start with ~/
+func secretSources(repoPath, homeDir string, secrets map[string]string) []secretsprovider.Source {
+	// Sorted to keep builds reproducible
+	ids := make([]string, 0, len(secrets))
+	for id := range secrets {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	sources := make([]secretsprovider.Source, 0, len(ids))
+	for _, id := range ids {
+		path := secrets[id]
+		switch {
+		case strings.HasPrefix(path, "~/"):
+			path = filepath.Join(homeDir, path[2:])
+		case !filepath.IsAbs(path):
+			path = filepath.Join(repoPath, path)
+		}
+
+		sources = append(sources, secretsprovider.Source{ID: id, FilePath: path})
+	}
+
+	return sources
+}
+
+// newBuildSession creates the BuildKit session of a build, it forwards
+// the ssh-agent, if there is one, so private dependencies can be
+// fetched and provides the secrets of the image
+func (a *App) newBuildSession(ctx context.Context, img *ImageConfig) (*session.Session, error) {
+	s, err := session.NewSession(ctx, "devenv", "")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create build session")
+	}
+
+	if _, ok := os.LookupEnv("SSH_AUTH_SOCK"); ok {
+		sp, err := sshprovider.NewSSHAgentProvider([]sshprovider.AgentConfig{{}}) //nolint:govet // Why: We're OK shadowing err
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to forward ssh-agent")
+		}
+		s.Allow(sp)
+	}
+
+	if len(img.Secrets) != 0 {
+		homeDir, err := os.UserHomeDir() //nolint:govet // Why: We're OK shadowing err
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read user's home dir")
+		}
+
+		store, err := secretsprovider.NewStore(secretSources(a.Path, homeDir, img.Secrets))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read build secrets")
+		}
+		s.Allow(secretsprovider.NewSecretProvider(store))
+	}
+
+	return s, nil
+}
+
+// buildContextTar returns the build context as a tar stream, skipping
+// the files excluded by its .dockerignore
+func buildContextTar(contextDir string) (io.ReadCloser, error) {
+	var excludes []string
+	if f, err := os.Open(filepath.Join(contextDir, ".dockerignore")); err == nil {
+		excludes, err = dockerignore.ReadAll(f)
+		f.Close() //nolint:errcheck // Why: Only read from
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read .dockerignore")
+		}
+	}
+
+	r, err := archive.TarWithOptions(contextDir, &archive.TarOptions{ExcludePatterns: excludes})
+	return r, errors.Wrap(err, "failed to archive build context")
+}
+
+// dockerBuild builds an image with BuildKit through the Docker API. See
+// newBuildSession for the ssh-agent and secrets that are made available
+// to the build. Build progress is streamed into the logger.
+func (a *App) dockerBuild(ctx context.Context, d dockerclient.APIClient, log logrus.FieldLogger, img *ImageConfig) error {
+	buildContext, opts, err := dockerBuildOptions(img)
+	if err != nil {
+		return err
+	}
+
+	s, err := a.newBuildSession(ctx, img)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	go s.Run(ctx, func(ctx context.Context, proto string, meta map[string][]string) (net.Conn, error) { //nolint:errcheck // Why: The build fails when the session does
+		return d.DialHijack(ctx, "/session", proto, meta)
+	})
+	opts.SessionID = s.ID()
+
+	tar, err := buildContextTar(filepath.Join(a.Path, buildContext))
+	if err != nil {
+		return err
+	}
+	defer tar.Close()
+
+	resp, err := d.ImageBuild(ctx, tar, opts)
+	if err != nil {
+		return errors.Wrap(err, "failed to build docker image")
+	}
+	defer resp.Body.Close()
+
+	return errors.Wrap(newBuildProgress(log).stream(resp.Body), "failed to build docker image")
+}
+
+// buildProgress logs the progress of a BuildKit build from the JSON
+// messages streamed by the Docker API
+type buildProgress struct {
+	log logrus.FieldLogger
+
+	// steps are the names of the build steps, by digest
+	steps map[digest.Digest]string
+
+	// started and done are the steps that were logged as started and
+	// done, BuildKit sends the state of a step more than once
+	started map[digest.Digest]bool
+	done    map[digest.Digest]bool
+}
+
+// newBuildProgress returns a buildProgress logging into log
+func newBuildProgress(log logrus.FieldLogger) *buildProgress {
+	return &buildProgress{
+		log:     log,
+		steps:   make(map[digest.Digest]string),
+		started: make(map[digest.Digest]bool),
+		done:    make(map[digest.Digest]bool),
+	}
+}
+
+// stream logs the messages read from r until it's closed, returning the
+// error of the build if it failed
+func (p *buildProgress) stream(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := dec.Decode(&msg); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return errors.Wrap(err, "failed to read build progress")
+		}
+
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if msg.ErrorMessage != "" {
+			return errors.New(msg.ErrorMessage)
+		}
+
+		if msg.ID == buildkitTraceID && msg.Aux != nil {
+			if err := p.trace(*msg.Aux); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if line := strings.TrimSpace(msg.Stream); line != "" {
+			p.log.Info(line)
+		}
+	}
+}
+
+// trace logs the steps and their output from a BuildKit status update,
+// which is sent as a base64 encoded protobuf message
+func (p *buildProgress) trace(aux json.RawMessage) error {
+	var b []byte
+	if err := json.Unmarshal(aux, &b); err != nil {
+		return errors.Wrap(err, "failed to decode build progress")
+	}
+
+	var status controlapi.StatusResponse
+	if err := status.Unmarshal(b); err != nil {
+		return errors.Wrap(err, "failed to decode build progress")
+	}
+
+	for _, v := range status.Vertexes {
+		p.steps[v.Digest] = v.Name
+		log := p.log.WithField("step", v.Name)
+
+		switch {
+		case v.Error != "":
+			log.Error(v.Error)
+		case v.Completed != nil && !p.done[v.Digest]:
+			p.done[v.Digest] = true
+			if v.Cached {
+				log.Info("Cached")
+			} else {
+				log.Info("Done")
+			}
+		case v.Started != nil && !p.started[v.Digest]:
+			p.started[v.Digest] = true
+			log.Info("Started")
+		}
+	}
+
+	for _, l := range status.Logs {
+		log := p.log.WithField("step", p.steps[l.Vertex])
+		for _, line := range strings.Split(strings.TrimRight(string(l.Msg), "\n"), "\n") {
+			log.Info(line)
+		}
+	}
+
+	return nil
+}
+
+// imageID returns the ID of a local docker image
+func imageID(ctx context.Context, d dockerclient.APIClient, image string) (string, error) {
+	inspect, _, err := d.ImageInspectWithRaw(ctx, image)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to inspect docker image %s", image)
+	}
+
+	return inspect.ID, nil
+}
+
+// tagImage tags a local docker image, by ID, with the given name
+func tagImage(ctx context.Context, d dockerclient.APIClient, id, image string) error {
+	err := d.ImageTag(ctx, id, image)
+	return errors.Wrapf(err, "failed to tag docker image %s", id)
+}
+
+// pushImage pushes a local docker image to a registry that doesn't
+// require authentication
+func pushImage(ctx context.Context, d dockerclient.APIClient, image string) error {
+	// The daemon rejects pushes without credentials, even empty ones
+	auth, err := json.Marshal(types.AuthConfig{})
+	if err != nil {
+		return errors.Wrap(err, "failed to encode registry credentials")
+	}
+
+	r, err := d.ImagePush(ctx, image, types.ImagePushOptions{RegistryAuth: base64.URLEncoding.EncodeToString(auth)})
+	if err != nil {
+		return errors.Wrapf(err, "failed to push docker image %s", image)
+	}
+	defer r.Close()
+
+	// Errors are only reported in the progress of the push
+	err = jsonmessage.DisplayJSONMessagesStream(r, ioutil.Discard, 0, false, nil)
+	return errors.Wrapf(err, "failed to push docker image %s", image)
+}
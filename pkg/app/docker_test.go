@@ -0,0 +1,175 @@
+package app
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/jsonmessage"
+	controlapi "github.com/moby/buildkit/api/services/control"
+	"github.com/moby/buildkit/session/secrets/secretsprovider"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestDockerBuildOptions(t *testing.T) {
+	version := "v1.2.3"
+	goVersion := "1.17"
+
+	tests := []struct {
+		name        string
+		img         ImageConfig
+		wantContext string
+		want        types.ImageBuildOptions
+		wantErr     bool
+	}{
+		{
+			name:        "should build the Dockerfile at the root of the repository",
+			img:         ImageConfig{Name: "gcr.io/outreach-docker/flagship"},
+			wantContext: ".",
+			want: types.ImageBuildOptions{
+				Version:    types.BuilderBuildKit,
+				Tags:       []string{"gcr.io/outreach-docker/flagship"},
+				Dockerfile: "Dockerfile",
+				BuildArgs:  map[string]*string{},
+				Remove:     true,
+			},
+		},
+		{
+			name:        "should build the Dockerfile in the build context",
+			img:         ImageConfig{Name: "flagship", Context: "web"},
+			wantContext: "web",
+			want: types.ImageBuildOptions{
+				Version:    types.BuilderBuildKit,
+				Tags:       []string{"flagship"},
+				Dockerfile: "Dockerfile",
+				BuildArgs:  map[string]*string{},
+				Remove:     true,
+			},
+		},
+		{
+			name: "should pass the target and build args",
+			img: ImageConfig{
+				Name:       "flagship",
+				Dockerfile: "deployments/flagship/Dockerfile",
+				Target:     "production",
+				BuildArgs:  map[string]string{"VERSION": "v1.2.3", "GO_VERSION": "1.17"},
+			},
+			wantContext: ".",
+			want: types.ImageBuildOptions{
+				Version:    types.BuilderBuildKit,
+				Tags:       []string{"flagship"},
+				Dockerfile: "deployments/flagship/Dockerfile",
+				Target:     "production",
+				BuildArgs:  map[string]*string{"VERSION": &version, "GO_VERSION": &goVersion},
+				Remove:     true,
+			},
+		},
+		{
+			name:    "should fail when the Dockerfile isn't in the build context",
+			img:     ImageConfig{Name: "flagship", Context: "web", Dockerfile: "deployments/flagship/Dockerfile"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			gotContext, got, err := dockerBuildOptions(&tt.img)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("dockerBuildOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if gotContext != tt.wantContext || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("dockerBuildOptions() = %v, %+v, want %v, %+v", gotContext, got, tt.wantContext, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecretSources(t *testing.T) {
+	got := secretSources("/src/flagship", "/home/jane", map[string]string{
+		"npmrc":  "~/.npmrc",
+		"github": "/etc/github-token",
+		"env":    ".env",
+	})
+
+	want := []secretsprovider.Source{
+		{ID: "env", FilePath: "/src/flagship/.env"},
+		{ID: "github", FilePath: "/etc/github-token"},
+		{ID: "npmrc", FilePath: "/home/jane/.npmrc"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("secretSources() = %+v, want %+v", got, want)
+	}
+}
+
+// traceMessage returns the JSON message the Docker API streams for a
+// BuildKit status update
+func traceMessage(t *testing.T, status *controlapi.StatusResponse) string {
+	b, err := status.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aux, err := json.Marshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw := json.RawMessage(aux)
+	msg, err := json.Marshal(jsonmessage.JSONMessage{ID: buildkitTraceID, Aux: &raw})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(msg)
+}
+
+func TestBuildProgress_stream(t *testing.T) {
+	now := time.Now()
+	step := &controlapi.Vertex{Digest: "sha256:1", Name: "[1/2] RUN go build ./..."}
+	started := &controlapi.Vertex{Digest: step.Digest, Name: step.Name, Started: &now}
+	completed := &controlapi.Vertex{Digest: step.Digest, Name: step.Name, Started: &now, Completed: &now}
+
+	stream := strings.Join([]string{
+		traceMessage(t, &controlapi.StatusResponse{Vertexes: []*controlapi.Vertex{step, started}}),
+		traceMessage(t, &controlapi.StatusResponse{
+			Vertexes: []*controlapi.Vertex{started},
+			Logs:     []*controlapi.VertexLog{{Vertex: step.Digest, Msg: []byte("go: downloading\ngo: building\n")}},
+		}),
+		traceMessage(t, &controlapi.StatusResponse{Vertexes: []*controlapi.Vertex{completed}}),
+		`{"stream":"Successfully tagged flagship:latest\n"}`,
+	}, "\n")
+
+	log, hook := test.NewNullLogger()
+	if err := newBuildProgress(log).stream(strings.NewReader(stream)); err != nil {
+		t.Fatalf("buildProgress.stream() error = %v", err)
+	}
+
+	got := make([]string, 0, len(hook.AllEntries()))
+	for _, e := range hook.AllEntries() {
+		if name, ok := e.Data["step"]; ok {
+			got = append(got, name.(string)+": "+e.Message)
+		} else {
+			got = append(got, e.Message)
+		}
+	}
+
+	want := []string{
+		step.Name + ": Started",
+		step.Name + ": go: downloading",
+		step.Name + ": go: building",
+		step.Name + ": Done",
+		"Successfully tagged flagship:latest",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("buildProgress.stream() logged %q, want %q", got, want)
+	}
+
+	err := newBuildProgress(logrus.New()).stream(strings.NewReader(`{"errorDetail":{"message":"failed to solve"}}`))
+	if err == nil || err.Error() != "failed to solve" {
+		t.Errorf("buildProgress.stream() error = %v, want failed to solve", err)
+	}
+}
@@ -0,0 +1,249 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/getoutreach/devenv/pkg/cmdutil"
+	"github.com/getoutreach/devenv/pkg/devenvutil"
+	"github.com/getoutreach/devenv/pkg/kubernetesruntime"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// DryRunResult is what deploying, or deleting, an application would do
+type DryRunResult struct {
+	// App is the name of the application
+	App string
+
+	// Type is the type of the application
+	Type Type
+
+	// Version is the version that would be deployed, empty for the
+	// latest version
+	Version string
+
+	// Commit is the git commit that would be deployed, if known
+	Commit string
+
+	// Local denotes if the application is deployed from local disk
+	Local bool
+
+	// Repository is the URL of the repository that was fetched into the
+	// repository cache, empty for local applications
+	Repository string
+
+	// Command is the command that would be ran to deploy, or delete, the
+	// application
+	Command []string
+
+	// Images are the docker images that would be built
+	Images []string
+
+	// ImagesSkipped is why no images would be built
+	ImagesSkipped string
+
+	// Jobs are the jobs, as namespace/name, that would be deleted before
+	// deploying
+	Jobs []string
+
+	// Pods are the pods, as namespace/name, that would be restarted after
+	// deploying
+	Pods []string
+
+	// Diff is the diff between the rendered manifests of the application
+	// and the live objects, see DeployConfig.RenderCommand. When deleting,
+	// it's the live objects that would be deleted.
+	Diff string
+
+	// DiffSkipped is why no diff was rendered
+	DiffSkipped string
+}
+
+// DryRunDelete returns what deleting an application would do, without
+// changing anything
+func DryRunDelete(ctx context.Context, log logrus.FieldLogger, k kubernetes.Interface, conf *rest.Config,
+	appNameOrPath string, kr kubernetesruntime.Runtime) (*DryRunResult, error) {
+	app, err := NewApp(log, k, conf, appNameOrPath, kr)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse app")
+	}
+	app.dryRun = true
+
+	cleanup, err := app.prepare(ctx)
+	defer cleanup()
+	if err != nil {
+		return nil, err
+	}
+
+	return app.dryRunDelete(ctx)
+}
+
+// newDryRunResult returns the dry run result of an already prepared
+// application, see App.prepare
+func (a *App) newDryRunResult() *DryRunResult {
+	return &DryRunResult{
+		App:        a.RepositoryName,
+		Type:       a.Type,
+		Version:    a.Version,
+		Commit:     a.Commit,
+		Local:      a.Local,
+		Repository: a.RepositoryURL,
+	}
+}
+
+// dryRunDeploy returns what deploying an already prepared application
+// would do
+func (a *App) dryRunDeploy(ctx context.Context) (*DryRunResult, error) {
+	r := a.newDryRunResult()
+
+	cmd, err := a.deployCommand()
+	if err != nil {
+		return nil, err
+	}
+	r.Command = cmd
+
+	images, reason := a.imagesToBuild()
+	r.ImagesSkipped = reason
+	for i := range images {
+		r.Images = append(r.Images, images[i].Name)
+	}
+
+	if r.Jobs, err = devenvutil.FindObjects(ctx, a.k, a.conf, a.migrationJobs()); err != nil {
+		return nil, errors.Wrap(err, "failed to find jobs")
+	}
+
+	if len(images) != 0 {
+		if r.Pods, err = devenvutil.FindObjects(ctx, a.k, a.conf, a.imagePods(images)); err != nil {
+			return nil, errors.Wrap(err, "failed to find pods")
+		}
+	}
+
+	r.Diff, r.DiffSkipped, err = a.renderDiff(ctx, "diff")
+	return r, err
+}
+
+// dryRunDelete returns what deleting an already prepared application
+// would do
+func (a *App) dryRunDelete(ctx context.Context) (*DryRunResult, error) {
+	r := a.newDryRunResult()
+
+	cmd, err := a.deleteCommand()
+	if err != nil {
+		return nil, err
+	}
+	r.Command = cmd
+
+	r.Diff, r.DiffSkipped, err = a.renderDiff(ctx, "get", "--ignore-not-found", "-o", "name")
+	return r, err
+}
+
+// renderDiff renders the manifests of the application, see
+// DeployConfig.RenderCommand, and passes them to kubectl with the given
+// arguments, e.g. diff. If the manifests can't be rendered, the reason is
+// returned instead.
+func (a *App) renderDiff(ctx context.Context, kubectlArgs ...string) (diff, reason string, err error) {
+	if a.Manifest == nil || len(a.Manifest.Deploy.RenderCommand) == 0 {
+		return "", fmt.Sprintf("deploy.renderCommand isn't set in %s", ManifestFile), nil
+	}
+
+	render := a.Manifest.Deploy.RenderCommand
+	cmd, err := cmdutil.NewKubernetesCommand(ctx, a.Path, render[0], render[1:]...)
+	if err != nil {
+		return "", "", err
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	manifests, err := cmd.Output()
+	if err != nil {
+		return "", "", errors.Wrapf(err, "failed to render manifests: %s", strings.TrimSpace(stderr.String()))
+	}
+
+	kubectl, err := cmdutil.NewKubernetesCommand(ctx, a.Path, "kubectl", append(kubectlArgs, "-f", "-")...)
+	if err != nil {
+		return "", "", err
+	}
+
+	stderr.Reset()
+	kubectl.Stdin = bytes.NewReader(manifests)
+	kubectl.Stderr = &stderr
+	out, err := kubectl.Output()
+
+	// kubectl diff exits with 1 when there are differences
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 && kubectlArgs[0] == "diff" {
+		err = nil
+	}
+	if err != nil {
+		return "", "", errors.Wrapf(err, "failed to run kubectl %s: %s", kubectlArgs[0], strings.TrimSpace(stderr.String()))
+	}
+
+	return string(out), "", nil
+}
+
+// Print prints a dry run result in a human readable format
+func (r *DryRunResult) Print(w io.Writer, deleting bool) {
+	version := r.Version
+	if version == "" {
+		version = "latest"
+	}
+	if r.Local {
+		version = "local"
+	}
+	if r.Commit != "" {
+		version += " (" + r.Commit + ")"
+	}
+
+	fmt.Fprintf(w, "Application: %s\n", r.App)
+	fmt.Fprintf(w, "Type: %s\n", r.Type)
+	fmt.Fprintf(w, "Version: %s\n", version)
+	if r.Repository != "" {
+		fmt.Fprintf(w, "Repository: %s (fetched into the repository cache)\n", r.Repository)
+	}
+	fmt.Fprintf(w, "Command: %s\n", strings.Join(r.Command, " "))
+
+	if !deleting {
+		if len(r.Images) != 0 {
+			fmt.Fprintf(w, "Images to build: %s\n", strings.Join(r.Images, ", "))
+		} else {
+			fmt.Fprintf(w, "Images to build: none, %s\n", r.ImagesSkipped)
+		}
+
+		printList(w, "Jobs to delete", r.Jobs)
+		printList(w, "Pods to restart", r.Pods)
+	}
+
+	title := "Diff"
+	if deleting {
+		title = "Objects to delete"
+	}
+
+	switch {
+	case r.DiffSkipped != "":
+		fmt.Fprintf(w, "%s: unavailable, %s\n", title, r.DiffSkipped)
+	case strings.TrimSpace(r.Diff) == "":
+		fmt.Fprintf(w, "%s: none\n", title)
+	default:
+		fmt.Fprintf(w, "%s:\n%s\n", title, strings.TrimRight(r.Diff, "\n"))
+	}
+}
+
+// printList prints a titled list, one item per line
+func printList(w io.Writer, title string, items []string) {
+	if len(items) == 0 {
+		fmt.Fprintf(w, "%s: none\n", title)
+		return
+	}
+
+	fmt.Fprintf(w, "%s:\n", title)
+	for _, item := range items {
+		fmt.Fprintf(w, "  - %s\n", item)
+	}
+}
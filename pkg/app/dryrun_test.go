@@ -0,0 +1,93 @@
+package app
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDryRunResult_Print(t *testing.T) {
+	tests := []struct {
+		name     string
+		result   DryRunResult
+		deleting bool
+		want     string
+	}{
+		{
+			name: "should print a deploy",
+			result: DryRunResult{
+				App:        "authz",
+				Type:       TypeBootstrap,
+				Version:    "v1.2.3",
+				Commit:     "abc123",
+				Repository: "git@github.com:getoutreach/authz",
+				Command:    []string{"./scripts/deploy-to-dev.sh", "update"},
+				Images:     []string{"gcr.io/outreach-docker/authz"},
+				Jobs:       []string{"authz--bento1a/migrate"},
+				Pods:       []string{"authz--bento1a/authz-1", "authz--bento1a/authz-2"},
+				Diff:       "-replicas: 1\n+replicas: 2\n",
+			},
+			want: `Application: authz
+Type: bootstrap
+Version: v1.2.3 (abc123)
+Repository: git@github.com:getoutreach/authz (fetched into the repository cache)
+Command: ./scripts/deploy-to-dev.sh update
+Images to build: gcr.io/outreach-docker/authz
+Jobs to delete:
+  - authz--bento1a/migrate
+Pods to restart:
+  - authz--bento1a/authz-1
+  - authz--bento1a/authz-2
+Diff:
+-replicas: 1
++replicas: 2
+`,
+		},
+		{
+			name: "should print why nothing is built or diffed",
+			result: DryRunResult{
+				App:           "authz",
+				Type:          TypeLegacy,
+				Command:       []string{"./scripts/deploy-to-dev.sh", "update"},
+				ImagesSkipped: "latest images are pulled from the image registry",
+				DiffSkipped:   "deploy.renderCommand isn't set in .devenv.yaml",
+			},
+			want: `Application: authz
+Type: legacy
+Version: latest
+Command: ./scripts/deploy-to-dev.sh update
+Images to build: none, latest images are pulled from the image registry
+Jobs to delete: none
+Pods to restart: none
+Diff: unavailable, deploy.renderCommand isn't set in .devenv.yaml
+`,
+		},
+		{
+			name: "should print a delete",
+			result: DryRunResult{
+				App:     "flagship",
+				Type:    TypeScript,
+				Local:   true,
+				Command: []string{"make", "undeploy"},
+				Diff:    "deployment.apps/flagship\n",
+			},
+			deleting: true,
+			want: `Application: flagship
+Type: script
+Version: local
+Command: make undeploy
+Objects to delete:
+deployment.apps/flagship
+`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tt.result.Print(&buf, tt.deleting)
+			if got := buf.String(); got != tt.want {
+				t.Errorf("DryRunResult.Print() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package app
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ManifestFile is the name of the file, at the root of a repository, that
+// describes how devenv should deploy an application
+const ManifestFile = ".devenv.yaml"
+
+// DefaultHealthCheckTimeout is the time to wait for a health check to
+// pass, or the workloads of an application to roll out, when no timeout
+// was set
+const DefaultHealthCheckTimeout = 10 * time.Minute
+
+// Manifest describes how an application should be deployed into a
+// developer environment. When present, it's used over guessing based
+// on the layout of the repository.
+type Manifest struct {
+	// Name is the name of the application, if not set the name of the
+	// repository is used
+	Name string `yaml:"name"`
+
+	// Deploy is how the application is deployed and deleted
+	Deploy DeployConfig `yaml:"deploy"`
+
+	// Namespaces are the namespaces the application is deployed into,
+	// if not set <name> and <name>--bento1a are used
+	Namespaces []string `yaml:"namespaces"`
+
+	// Images are the docker images built for this application when
+	// deploying a local checkout or a specific version
+	Images []ImageConfig `yaml:"images"`
+
+	// HealthChecks are checked after deploying to determine if the
+	// application is ready, if not set the Deployments, StatefulSets and
+	// DaemonSets in its namespaces are waited on to roll out
+	HealthChecks []HealthCheck `yaml:"healthChecks"`
+
+	// Dependencies are the applications that must be deployed for this
+	// application to work, e.g. outreach or flagship@v1.2.3
+	Dependencies []string `yaml:"dependencies"`
+}
+
+// DeployConfig is how an application is deployed
+type DeployConfig struct {
+	// Method is how the application is deployed, if not set it's
+	// determined from the layout of the repository
+	Method Type `yaml:"method"`
+
+	// Command is the command ran, from the root of the repository, to
+	// deploy the application. Only used by the script method.
+	Command []string `yaml:"command"`
+
+	// DeleteCommand is the command ran, from the root of the repository,
+	// to delete the application. Only used by the script method.
+	DeleteCommand []string `yaml:"deleteCommand"`
+
+	// RenderCommand is the command ran, from the root of the repository,
+	// that prints the manifests the deploy command applies. Used by dry
+	// runs to diff them against the live objects.
+	RenderCommand []string `yaml:"renderCommand"`
+}
+
+// ImageConfig is a docker image built for an application
+type ImageConfig struct {
+	// Name is the name of the image, e.g. gcr.io/outreach-docker/flagship
+	Name string `yaml:"name"`
+
+	// Context is the build context, relative to the root of the
+	// repository. Defaults to the root of the repository.
+	Context string `yaml:"context"`
+
+	// Dockerfile is the path to the Dockerfile, relative to the root of
+	// the repository. Defaults to Dockerfile in the build context.
+	Dockerfile string `yaml:"dockerfile"`
+
+	// Target is the stage of the Dockerfile to build, defaults to the
+	// last stage
+	Target string `yaml:"target"`
+
+	// BuildArgs are the build-time variables passed to the build, e.g.
+	// VERSION: v1.2.3
+	BuildArgs map[string]string `yaml:"buildArgs"`
+
+	// Secrets are the files made available to the build, by secret ID,
+	// e.g. npmrc: ~/.npmrc. Paths are relative to the root of the
+	// repository.
+	Secrets map[string]string `yaml:"secrets"`
+
+	// Command is the command ran, from the root of the repository, to
+	// build the image instead of docker build, e.g. [make, docker-build]
+	Command []string `yaml:"command"`
+}
+
+// HealthCheck is a set of pods that must be ready for an application
+// to be considered deployed
+type HealthCheck struct {
+	// Namespace is the namespace the pods are in
+	Namespace string `yaml:"namespace"`
+
+	// Selector is the label selector of the pods, e.g. app=flagship
+	Selector string `yaml:"selector"`
+
+	// Timeout is how long to wait for the pods to be ready, defaults
+	// to the timeout of the deploy, see App.WaitTimeout
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// LoadManifest reads the manifest from the root of a repository, if
+// the repository has no manifest nil is returned. Unknown fields, e.g.
+// from manifests written for newer versions of devenv, are ignored with
+// a warning.
+func LoadManifest(log logrus.FieldLogger, repoPath string) (*Manifest, error) {
+	b, err := ioutil.ReadFile(filepath.Join(repoPath, ManifestFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "failed to read %s", ManifestFile)
+	}
+
+	var m Manifest
+	if err := yaml.Unmarshal(b, &m); err != nil { //nolint:govet // Why: We're OK shadowing err
+		return nil, errors.Wrapf(err, "failed to parse %s", ManifestFile)
+	}
+
+	// The manifest is valid YAML, so strict parsing only fails on unknown
+	// fields
+	if err := yaml.UnmarshalStrict(b, &Manifest{}); err != nil { //nolint:govet // Why: We're OK shadowing err
+		log.WithError(err).Warnf("Ignoring unknown fields in %s, is devenv up to date?", ManifestFile)
+	}
+
+	if err := m.Validate(); err != nil { //nolint:govet // Why: We're OK shadowing err
+		return nil, errors.Wrapf(err, "invalid %s", ManifestFile)
+	}
+
+	return &m, nil
+}
+
+// Validate ensures that a manifest is complete and only uses known
+// deploy methods
+func (m *Manifest) Validate() error {
+	switch m.Deploy.Method {
+	case "", TypeBootstrap, TypeLegacy:
+	case TypeScript:
+		if len(m.Deploy.Command) == 0 {
+			return fmt.Errorf("deploy.command must be set when using the %s deploy method", TypeScript)
+		}
+	default:
+		return fmt.Errorf("unknown deploy method '%s', expected one of %s, %s or %s",
+			m.Deploy.Method, TypeBootstrap, TypeLegacy, TypeScript)
+	}
+
+	for i := range m.Images {
+		if m.Images[i].Name == "" {
+			return fmt.Errorf("images[%d].name must be set", i)
+		}
+	}
+
+	for i := range m.HealthChecks {
+		hc := &m.HealthChecks[i]
+		if hc.Namespace == "" {
+			return fmt.Errorf("healthChecks[%d].namespace must be set", i)
+		}
+
+		if _, err := labels.Parse(hc.Selector); err != nil {
+			return errors.Wrapf(err, "healthChecks[%d].selector is invalid", i)
+		}
+	}
+
+	for i, dep := range m.Dependencies {
+		if dep == "" {
+			return fmt.Errorf("dependencies[%d] must not be empty", i)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package app
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestLoadManifest(t *testing.T) {
+	tests := []struct {
+		name        string
+		manifest    string
+		want        *Manifest
+		wantErr     bool
+		wantWarning bool
+	}{
+		{
+			name: "should return nil without a manifest",
+			want: nil,
+		},
+		{
+			name: "should parse a manifest",
+			manifest: `name: flagship
+deploy:
+  method: script
+  command: [make, deploy]
+namespaces: [flagship--bento1a]
+images:
+- name: gcr.io/outreach-docker/flagship
+  dockerfile: deployments/Dockerfile
+healthChecks:
+- namespace: flagship--bento1a
+  selector: app=flagship
+  timeout: 5m
+dependencies: [outreach-accounts]
+`,
+			want: &Manifest{
+				Name: "flagship",
+				Deploy: DeployConfig{
+					Method:  TypeScript,
+					Command: []string{"make", "deploy"},
+				},
+				Namespaces: []string{"flagship--bento1a"},
+				Images: []ImageConfig{
+					{Name: "gcr.io/outreach-docker/flagship", Dockerfile: "deployments/Dockerfile"},
+				},
+				HealthChecks: []HealthCheck{
+					{Namespace: "flagship--bento1a", Selector: "app=flagship", Timeout: 5 * time.Minute},
+				},
+				Dependencies: []string{"outreach-accounts"},
+			},
+		},
+		{
+			name:        "should warn about unknown keys",
+			manifest:    "name: flagship\ndeploymentMethod: script\n",
+			want:        &Manifest{Name: "flagship"},
+			wantWarning: true,
+		},
+		{
+			name:     "should reject unknown deploy methods",
+			manifest: "deploy:\n  method: helm\n",
+			wantErr:  true,
+		},
+		{
+			name:     "should require a command for the script deploy method",
+			manifest: "deploy:\n  method: script\n",
+			wantErr:  true,
+		},
+		{
+			name:     "should reject invalid health check selectors",
+			manifest: "healthChecks:\n- namespace: flagship\n  selector: 'app in ('\n",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			if tt.manifest != "" {
+				if err := ioutil.WriteFile(filepath.Join(dir, ManifestFile), []byte(tt.manifest), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			log, hook := test.NewNullLogger()
+
+			got, err := LoadManifest(log, dir)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadManifest() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadManifest() = %v, want %v", got, tt.want)
+			}
+
+			warned := hook.LastEntry() != nil && hook.LastEntry().Level == logrus.WarnLevel
+			if warned != tt.wantWarning {
+				t.Errorf("LoadManifest() warned = %v, want %v", warned, tt.wantWarning)
+			}
+		})
+	}
+}
@@ -0,0 +1,536 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getoutreach/devenv/pkg/appregistry"
+	"github.com/getoutreach/devenv/pkg/kubernetesruntime"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// DefaultConcurrency is the number of applications that are fetched,
+// built or waited on at the same time when no concurrency is set
+const DefaultConcurrency = 4
+
+// PlanOptions are the applications, and how they should be deployed,
+// that a Plan is created for
+type PlanOptions struct {
+	// Apps are the applications to deploy, by name or path. Dependencies
+	// that are already deployed in the cluster, see Plan.isDeployed, are
+	// skipped.
+	Apps []string
+
+	// NoDeps denotes that the dependencies of Apps shouldn't be deployed
+	NoDeps bool
+
+	// Concurrency is the maximum number of applications that are fetched,
+	// built or waited on at the same time, defaults to DefaultConcurrency.
+	// Deploy scripts are always ran one at a time.
+	Concurrency int
+
+	// Timeout is how long to wait for each application to become ready
+	// after deploying it, see App.WaitTimeout
+	Timeout time.Duration
+
+	// DryRun denotes that the plan won't be deployed, repositories are
+	// still fetched into the repository cache but it isn't pruned
+	DryRun bool
+}
+
+// DeployStatus is the outcome of deploying an application
+type DeployStatus string
+
+const (
+	// DeployStatusDeployed is an application that was deployed
+	DeployStatusDeployed DeployStatus = "deployed"
+
+	// DeployStatusFailed is an application that failed to deploy
+	DeployStatusFailed DeployStatus = "failed"
+
+	// DeployStatusSkipped is an application that wasn't deployed because
+	// one of its dependencies failed to deploy
+	DeployStatusSkipped DeployStatus = "skipped"
+)
+
+// Result is the outcome of deploying an application in a Plan
+type Result struct {
+	// App is the name of the application
+	App string
+
+	// Status is the outcome of deploying the application
+	Status DeployStatus
+
+	// Duration is how long deploying the application took, including
+	// waiting for its dependencies
+	Duration time.Duration
+
+	// Err is the error that occurred, if the application wasn't deployed
+	Err error
+}
+
+// planNode is an application in a Plan
+type planNode struct {
+	app *App
+
+	// deps are the names of the applications that have to be deployed
+	// before this application
+	deps []string
+
+	// step is the number of applications, in the longest chain of
+	// dependencies, that have to be deployed before this application
+	step int
+
+	// done is closed once this application has been deployed, or
+	// failed to deploy
+	done chan struct{}
+
+	// result is the outcome of deploying this application, only safe
+	// to read once done is closed
+	result Result
+}
+
+// Plan is a set of applications and their dependencies, declared in
+// their manifests, in the order they need to be deployed in, see NewPlan
+type Plan struct {
+	log  logrus.FieldLogger
+	k    kubernetes.Interface
+	conf *rest.Config
+	r    kubernetesruntime.Runtime
+
+	// sem limits the number of applications fetched, built or waited on
+	// at the same time
+	sem chan struct{}
+
+	// timeout is how long to wait for each application to become ready,
+	// see App.WaitTimeout
+	timeout time.Duration
+
+	// dryRun denotes that the plan won't be deployed, see
+	// PlanOptions.DryRun
+	dryRun bool
+
+	// deployMu ensures only one deploy script runs at a time, as they
+	// share the kubeconfig and tooling caches of the host
+	deployMu sync.Mutex
+
+	// nodes are all applications in this plan by name
+	nodes map[string]*planNode
+
+	// order is the names of all applications in this plan, dependencies
+	// always come before the applications depending on them
+	order []string
+
+	// alreadyDeployed are the dependencies that were already deployed
+	alreadyDeployed []string
+
+	// mu protects cleanups
+	mu sync.Mutex
+
+	// cleanups remove the downloaded repositories of the applications
+	cleanups []func()
+}
+
+// NewPlan fetches applications and resolves their dependencies into a
+// Plan. Applications are fetched in parallel. An error is returned if the
+// dependencies contain a cycle. Close must be called once the plan is no
+// longer needed.
+func NewPlan(ctx context.Context, log logrus.FieldLogger, k kubernetes.Interface, conf *rest.Config,
+	r kubernetesruntime.Runtime, opts PlanOptions) (*Plan, error) {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultConcurrency
+	}
+
+	p := &Plan{
+		log:     log,
+		k:       k,
+		conf:    conf,
+		r:       r,
+		sem:     make(chan struct{}, opts.Concurrency),
+		timeout: opts.Timeout,
+		dryRun:  opts.DryRun,
+		nodes:   make(map[string]*planNode),
+	}
+
+	if err := p.resolve(ctx, opts); err != nil {
+		p.Close()
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// prepareApps creates and prepares applications in parallel, see
+// App.prepare. The returned applications are in the same order.
+func (p *Plan) prepareApps(ctx context.Context, appNameOrPaths []string) ([]*App, error) {
+	apps := make([]*App, len(appNameOrPaths))
+	errs := make([]error, len(appNameOrPaths))
+
+	var wg sync.WaitGroup
+	for i := range appNameOrPaths {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			p.sem <- struct{}{}
+			defer func() { <-p.sem }()
+
+			a, err := NewApp(p.log, p.k, p.conf, appNameOrPaths[i], p.r)
+			if err != nil {
+				errs[i] = errors.Wrapf(err, "parse app %s", appNameOrPaths[i])
+				return
+			}
+			a.WaitTimeout = p.timeout
+			a.dryRun = p.dryRun
+
+			cleanup, err := a.prepare(ctx)
+			p.mu.Lock()
+			p.cleanups = append(p.cleanups, cleanup)
+			p.mu.Unlock()
+
+			apps[i], errs[i] = a, errors.Wrapf(err, "prepare app %s", appNameOrPaths[i])
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return apps, nil
+}
+
+// deployedApps returns the names of the applications in the registry of
+// the cluster
+func (p *Plan) deployedApps(ctx context.Context) (map[string]bool, error) {
+	entries, err := appregistry.New(p.k).List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deployed := make(map[string]bool)
+	for i := range entries {
+		deployed[entries[i].Name] = true
+	}
+	return deployed, nil
+}
+
+// isDeployed returns true if an application is deployed in the cluster,
+// i.e. it's in the registry or, for applications that were deployed
+// before it existed or restored from a snapshot, one of its default
+// namespaces exists, see App.namespaces
+func (p *Plan) isDeployed(ctx context.Context, registry map[string]bool, name string) (bool, error) {
+	if registry[name] {
+		return true, nil
+	}
+
+	for _, ns := range defaultNamespaces(name) {
+		_, err := p.k.CoreV1().Namespaces().Get(ctx, ns, metav1.GetOptions{})
+		if err == nil {
+			return true, nil
+		}
+		if !kerrors.IsNotFound(err) && !kerrors.IsInvalid(err) {
+			return false, errors.Wrapf(err, "failed to determine if %s is deployed", name)
+		}
+	}
+
+	return false, nil
+}
+
+// resolve fetches the applications, and their dependencies that aren't
+// deployed yet, one level of dependencies at a time and adds them to the
+// plan
+func (p *Plan) resolve(ctx context.Context, opts PlanOptions) error { //nolint:funlen,gocyclo
+	registry, err := p.deployedApps(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Applications are named after their repository, which is only known
+	// once they've been fetched, dependencies are always named.
+	roots, err := p.prepareApps(ctx, opts.Apps)
+	if err != nil {
+		return err
+	}
+
+	pending := make(map[string]*App)
+	for _, a := range roots {
+		pending[a.RepositoryName] = a
+	}
+
+	for len(pending) != 0 {
+		next := make([]string, 0)
+		for name, a := range pending {
+			n := &planNode{app: a, done: make(chan struct{})}
+			p.nodes[name] = n
+
+			if opts.NoDeps || a.Manifest == nil {
+				continue
+			}
+
+			for _, dep := range a.Manifest.Dependencies {
+				depName := referenceName(dep)
+
+				// Applications being deployed are never skipped, even if they
+				// were deployed already
+				_, inPlan := p.nodes[depName]
+				_, isPending := pending[depName]
+				if !inPlan && !isPending {
+					deployed, err := p.isDeployed(ctx, registry, depName) //nolint:govet // Why: We're OK shadowing err
+					if err != nil {
+						return err
+					}
+
+					if deployed {
+						if !contains(p.alreadyDeployed, depName) {
+							p.alreadyDeployed = append(p.alreadyDeployed, depName)
+						}
+						continue
+					}
+				}
+				n.deps = append(n.deps, depName)
+
+				if !inPlan && !isPending && !contains(next, dep) {
+					next = append(next, dep)
+				}
+			}
+		}
+
+		// Dependencies referenced with different versions are only
+		// fetched once
+		nextNames := make([]string, 0, len(next))
+		nextDeps := make([]string, 0, len(next))
+		for _, dep := range next {
+			depName := referenceName(dep)
+			if _, ok := p.nodes[depName]; ok || contains(nextNames, depName) {
+				continue
+			}
+			nextNames = append(nextNames, depName)
+			nextDeps = append(nextDeps, dep)
+		}
+
+		apps, err := p.prepareApps(ctx, nextDeps)
+		if err != nil {
+			return errors.Wrap(err, "failed to resolve dependencies")
+		}
+
+		pending = make(map[string]*App)
+		for i, a := range apps {
+			pending[nextNames[i]] = a
+		}
+	}
+
+	return p.sortNodes()
+}
+
+// sortNodes orders the applications in the plan so that dependencies
+// come first, and determines the step of each application. An error is
+// returned if the dependencies contain a cycle.
+func (p *Plan) sortNodes() error {
+	names := make([]string, 0, len(p.nodes))
+	for name := range p.nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	visited := make(map[string]bool)
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		for i := range path {
+			if path[i] == name {
+				return fmt.Errorf("dependency cycle detected: %s", strings.Join(append(path[i:], name), " -> "))
+			}
+		}
+
+		if visited[name] {
+			return nil
+		}
+
+		n := p.nodes[name]
+		path = append(append([]string{}, path...), name)
+		for _, dep := range n.deps {
+			if err := visit(dep, path); err != nil {
+				return err
+			}
+
+			if step := p.nodes[dep].step + 1; step > n.step {
+				n.step = step
+			}
+		}
+
+		visited[name] = true
+		p.order = append(p.order, name)
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// contains returns true if list contains s
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Steps returns the names of the applications in this plan grouped by
+// the order they're deployed in. Applications in the same step don't
+// depend on each other and are deployed in parallel.
+func (p *Plan) Steps() [][]string {
+	steps := make([][]string, 0)
+	for _, name := range p.order {
+		n := p.nodes[name]
+		for len(steps) <= n.step {
+			steps = append(steps, []string{})
+		}
+		steps[n.step] = append(steps[n.step], name)
+	}
+
+	for i := range steps {
+		sort.Strings(steps[i])
+	}
+
+	return steps
+}
+
+// AlreadyDeployed returns the dependencies that weren't added to the plan
+// because they're already deployed
+func (p *Plan) AlreadyDeployed() []string {
+	return p.alreadyDeployed
+}
+
+// Deploy deploys all applications in this plan. Images are built in
+// parallel, and each application is deployed as soon as all of its
+// dependencies have been deployed. Applications whose dependencies failed
+// to deploy are skipped. An error is returned if any application wasn't
+// deployed, see Results for the outcome of each application.
+func (p *Plan) Deploy(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for _, name := range p.order {
+		wg.Add(1)
+		go func(name string, n *planNode) {
+			defer wg.Done()
+			defer close(n.done)
+
+			started := time.Now()
+			n.result = p.deployNode(ctx, name, n)
+			n.result.Duration = time.Since(started)
+		}(name, p.nodes[name])
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, r := range p.Results() {
+		if r.Status != DeployStatusDeployed {
+			failed++
+		}
+	}
+
+	if failed != 0 {
+		return fmt.Errorf("%d of %d applications failed to deploy", failed, len(p.order))
+	}
+
+	return nil
+}
+
+// deployNode deploys an application in the plan once its dependencies
+// have been deployed
+func (p *Plan) deployNode(ctx context.Context, name string, n *planNode) Result {
+	failed := func(err error) Result {
+		n.app.log.WithError(err).Error("failed to deploy application")
+		return Result{App: name, Status: DeployStatusFailed, Err: err}
+	}
+
+	// Images don't depend on other applications being deployed, so
+	// they're built while waiting for dependencies
+	p.sem <- struct{}{}
+	built, err := n.app.buildImages(ctx)
+	<-p.sem
+	if err != nil {
+		return failed(err)
+	}
+
+	for _, dep := range n.deps {
+		depNode := p.nodes[dep]
+		<-depNode.done
+
+		if depNode.result.Status != DeployStatusDeployed {
+			return Result{App: name, Status: DeployStatusSkipped, Err: fmt.Errorf("dependency %s wasn't deployed", dep)}
+		}
+	}
+
+	p.deployMu.Lock()
+	err = n.app.runDeploy(ctx, built)
+	p.deployMu.Unlock()
+	if err != nil {
+		return failed(err)
+	}
+
+	p.sem <- struct{}{}
+	err = n.app.waitUntilReady(ctx)
+	<-p.sem
+	if err != nil {
+		return failed(err)
+	}
+
+	if err := n.app.recordDeployed(ctx, true); err != nil {
+		return failed(err)
+	}
+
+	return Result{App: name, Status: DeployStatusDeployed}
+}
+
+// DryRun returns what deploying each application in this plan would do,
+// dependencies first, without changing anything
+func (p *Plan) DryRun(ctx context.Context) ([]*DryRunResult, error) {
+	results := make([]*DryRunResult, 0, len(p.order))
+	for _, name := range p.order {
+		r, err := p.nodes[name].app.dryRunDeploy(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "dry run %s", name)
+		}
+		results = append(results, r)
+	}
+
+	return results, nil
+}
+
+// Results returns the outcome of deploying each application in this plan,
+// dependencies first. Only valid once Deploy has returned.
+func (p *Plan) Results() []Result {
+	results := make([]Result, len(p.order))
+	for i, name := range p.order {
+		results[i] = p.nodes[name].result
+	}
+
+	return results
+}
+
+// Close removes the downloaded repositories of all applications in this
+// plan
+func (p *Plan) Close() {
+	for _, cleanup := range p.cleanups {
+		cleanup()
+	}
+}
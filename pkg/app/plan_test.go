@@ -0,0 +1,201 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/getoutreach/devenv/pkg/appregistry"
+	fakeruntime "github.com/getoutreach/devenv/pkg/kubernetesruntime/fake"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// writeApps creates local applications, in dir, with manifests that
+// depend on the given applications. Applications are named after their
+// path, so dependencies can reference them.
+func writeApps(t *testing.T, dir string, apps map[string][]string) {
+	for name, deps := range apps {
+		depPaths := make([]string, len(deps))
+		for i, dep := range deps {
+			depPaths[i] = fmt.Sprintf("%q", filepath.Join(dir, dep))
+		}
+
+		manifest := fmt.Sprintf("name: %q\ndeploy:\n  method: script\n  command: [make, deploy]\ndependencies: [%s]\n",
+			filepath.Join(dir, name), strings.Join(depPaths, ", "))
+
+		if err := os.MkdirAll(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(filepath.Join(dir, name, ManifestFile), []byte(manifest), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestNewPlan(t *testing.T) {
+	tests := []struct {
+		name       string
+		apps       map[string][]string
+		deploy     []string
+		deployed   []string
+		namespaces []string
+		noDeps     bool
+		wantSteps  [][]string
+		wantErr    bool
+	}{
+		{
+			name:      "should deploy an application without dependencies",
+			apps:      map[string][]string{"authz": nil},
+			wantSteps: [][]string{{"authz"}},
+		},
+		{
+			name: "should deploy dependencies first",
+			apps: map[string][]string{
+				"authz":    {"accounts", "flagship"},
+				"flagship": {"accounts"},
+				"accounts": nil,
+			},
+			wantSteps: [][]string{{"accounts"}, {"flagship"}, {"authz"}},
+		},
+		{
+			name: "should deploy independent dependencies in the same step",
+			apps: map[string][]string{
+				"authz":    {"accounts", "flagship"},
+				"flagship": nil,
+				"accounts": nil,
+			},
+			wantSteps: [][]string{{"accounts", "flagship"}, {"authz"}},
+		},
+		{
+			name: "should skip deployed dependencies",
+			apps: map[string][]string{
+				"authz":    {"accounts", "flagship"},
+				"flagship": nil,
+				"accounts": nil,
+			},
+			deployed:  []string{"flagship"},
+			wantSteps: [][]string{{"accounts"}, {"authz"}},
+		},
+		{
+			name: "should skip dependencies whose namespace exists",
+			apps: map[string][]string{
+				"authz":    {"accounts", "flagship"},
+				"flagship": nil,
+				"accounts": nil,
+			},
+			namespaces: []string{"accounts"},
+			wantSteps:  [][]string{{"flagship"}, {"authz"}},
+		},
+		{
+			name: "should skip dependencies whose bento namespace exists",
+			apps: map[string][]string{
+				"authz":    {"accounts", "flagship"},
+				"flagship": nil,
+				"accounts": nil,
+			},
+			namespaces: []string{"accounts--bento1a"},
+			wantSteps:  [][]string{{"flagship"}, {"authz"}},
+		},
+		{
+			name: "should deploy applications that were deployed already",
+			apps: map[string][]string{
+				"authz":    {"flagship"},
+				"flagship": nil,
+			},
+			deploy:    []string{"authz", "flagship"},
+			deployed:  []string{"flagship"},
+			wantSteps: [][]string{{"flagship"}, {"authz"}},
+		},
+		{
+			name: "should deploy multiple applications",
+			apps: map[string][]string{
+				"authz":    {"accounts"},
+				"flagship": {"accounts"},
+				"accounts": nil,
+			},
+			deploy:    []string{"authz", "flagship"},
+			wantSteps: [][]string{{"accounts"}, {"authz", "flagship"}},
+		},
+		{
+			name: "should not deploy dependencies with noDeps",
+			apps: map[string][]string{
+				"authz":    {"accounts"},
+				"flagship": {"accounts"},
+				"accounts": nil,
+			},
+			deploy:    []string{"authz", "flagship"},
+			noDeps:    true,
+			wantSteps: [][]string{{"authz", "flagship"}},
+		},
+		{
+			name: "should detect cycles",
+			apps: map[string][]string{
+				"authz":    {"accounts"},
+				"accounts": {"flagship"},
+				"flagship": {"accounts"},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			dir := t.TempDir()
+			writeApps(t, dir, tt.apps)
+
+			k := fake.NewSimpleClientset()
+			for _, name := range tt.deployed {
+				if err := appregistry.New(k).Put(ctx, &appregistry.Entry{Name: filepath.Join(dir, name)}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, name := range tt.namespaces {
+				ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: filepath.Join(dir, name)}}
+				if _, err := k.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			opts := PlanOptions{NoDeps: tt.noDeps}
+
+			opts.Apps = []string{filepath.Join(dir, "authz")}
+			if len(tt.deploy) != 0 {
+				opts.Apps = nil
+				for _, name := range tt.deploy {
+					opts.Apps = append(opts.Apps, filepath.Join(dir, name))
+				}
+			}
+
+			p, err := NewPlan(ctx, logrus.New(), k, nil, fakeruntime.NewRuntime("kind"), opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewPlan() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer p.Close()
+
+			// Dependencies are referenced by path, only compare their names
+			got := p.Steps()
+			for i := range got {
+				for ii := range got[i] {
+					got[i][ii] = filepath.Base(got[i][ii])
+				}
+			}
+
+			if !reflect.DeepEqual(got, tt.wantSteps) {
+				t.Errorf("Plan.Steps() = %v, want %v", got, tt.wantSteps)
+			}
+		})
+	}
+}
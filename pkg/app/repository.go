@@ -0,0 +1,184 @@
+package app
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/getoutreach/devenv/pkg/config"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultRepositoryHost is the host repositories referenced by name
+	// are fetched from, unless configured in the box
+	DefaultRepositoryHost = "github.com"
+
+	// DefaultRepositoryOrg is the org repositories referenced by name are
+	// in, unless configured in the box
+	DefaultRepositoryOrg = "getoutreach"
+)
+
+// scpReg matches scp-like git URLs, e.g. git@github.com:getoutreach/authz
+var scpReg = regexp.MustCompile(`^[\w.-]+@([\w.-]+):(.+)$`)
+
+// repository is a remote repository an application is fetched from
+type repository struct {
+	// Name is the name of the repository, e.g. authz
+	Name string
+
+	// URL is the URL the repository is cloned from
+	URL string
+
+	// Key uniquely identifies the repository, e.g.
+	// github.com/getoutreach/authz
+	Key string
+}
+
+// splitVersion splits a reference into the repository and the version,
+// e.g. authz@v1.2.3. The user of scp-like URLs, e.g.
+// git@github.com:getoutreach/authz, isn't mistaken for a version.
+func splitVersion(ref string) (repo, version string) {
+	start := 0
+	if m := scpReg.FindStringSubmatchIndex(ref); m != nil {
+		start = m[4]
+	} else if i := strings.Index(ref, "://"); i != -1 {
+		start = i + len("://")
+		if slash := strings.Index(ref[start:], "/"); slash != -1 {
+			start += slash
+		}
+	}
+
+	i := strings.Index(ref[start:], "@")
+	if i == -1 {
+		return ref, ""
+	}
+
+	return ref[:start+i], ref[start+i+1:]
+}
+
+// localAppName returns the name of an application on disk, which is the
+// name of the directory it's in, e.g. "." in ~/src/authz is authz
+func localAppName(path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to resolve path %s", path)
+	}
+
+	name := filepath.Base(absPath)
+	if name == "." || name == ".." || name == string(filepath.Separator) {
+		return "", fmt.Errorf("could not determine application name from path %s", path)
+	}
+
+	return name, nil
+}
+
+// isLocalPath returns true if a reference, without a version, refers to
+// an application on disk
+func isLocalPath(ref string) bool {
+	if ref == "." || ref == ".." || strings.HasPrefix(ref, "./") || strings.HasPrefix(ref, "../") ||
+		strings.HasPrefix(ref, "/") || strings.HasPrefix(ref, "~") {
+		return true
+	}
+
+	if validRepoReg.MatchString(ref) || strings.Contains(ref, "://") || scpReg.MatchString(ref) {
+		return false
+	}
+
+	// host/org/repo, unless it exists on disk
+	spl := strings.Split(strings.Trim(ref, "/"), "/")
+	if len(spl) >= 3 && strings.Contains(spl[0], ".") {
+		if _, err := os.Stat(ref); os.IsNotExist(err) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// parseRepository parses a reference to a remote repository, without a
+// version. References can be a name, e.g. authz, which is in the default
+// org on the default host, a host and path, e.g. gitlab.example.com/group/repo,
+// or a git URL.
+func parseRepository(ref string, conf *config.BoxRepositoriesConfig) (*repository, error) {
+	var host, repoPath, cloneURL string
+	switch {
+	case strings.Contains(ref, "://"):
+		u, err := url.Parse(ref)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse repository URL '%s'", ref)
+		}
+		host, repoPath, cloneURL = u.Hostname(), u.Path, ref
+	case scpReg.MatchString(ref):
+		m := scpReg.FindStringSubmatch(ref)
+		host, repoPath, cloneURL = m[1], m[2], ref
+	case validRepoReg.MatchString(ref):
+		host = conf.DefaultHost
+		if host == "" {
+			host = DefaultRepositoryHost
+		}
+
+		org := conf.DefaultOrg
+		if org == "" {
+			org = DefaultRepositoryOrg
+		}
+		repoPath = org + "/" + ref
+	default:
+		spl := strings.SplitN(strings.Trim(ref, "/"), "/", 2)
+		if len(spl) != 2 {
+			return nil, fmt.Errorf("invalid repository '%s'", ref)
+		}
+		host, repoPath = spl[0], spl[1]
+	}
+
+	repoPath = strings.TrimSuffix(strings.Trim(repoPath, "/"), ".git")
+	if repoPath == "" {
+		return nil, fmt.Errorf("invalid repository '%s', missing repository path", ref)
+	}
+
+	if cloneURL == "" {
+		cloneURL = repositoryURL(host, repoPath, conf)
+	}
+
+	return &repository{
+		Name: path.Base(repoPath),
+		URL:  cloneURL,
+		Key:  host + "/" + repoPath,
+	}, nil
+}
+
+// repositoryURL returns the URL a repository on a host is cloned from
+func repositoryURL(host, repoPath string, conf *config.BoxRepositoriesConfig) string {
+	prefix, ok := conf.Hosts[host]
+	if !ok {
+		return "git@" + host + ":" + repoPath
+	}
+
+	if !strings.HasSuffix(prefix, "/") && !strings.HasSuffix(prefix, ":") {
+		prefix += "/"
+	}
+
+	return prefix + repoPath
+}
+
+// referenceName returns the name an application reference is known by
+// once deployed, the name of the repository for remote applications and
+// the path for local applications
+func referenceName(ref string) string {
+	ref, _ = splitVersion(ref)
+	if isLocalPath(ref) {
+		return ref
+	}
+
+	// The name doesn't depend on the host mapping
+	repo, err := parseRepository(ref, &config.BoxRepositoriesConfig{})
+	if err != nil {
+		return ref
+	}
+
+	return repo.Name
+}
@@ -0,0 +1,215 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/getoutreach/devenv/pkg/config"
+)
+
+func TestSplitVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		ref         string
+		wantRepo    string
+		wantVersion string
+	}{
+		{
+			name:     "should split nothing without a version",
+			ref:      "authz",
+			wantRepo: "authz",
+		},
+		{
+			name:        "should split a name",
+			ref:         "authz@v1.2.3",
+			wantRepo:    "authz",
+			wantVersion: "v1.2.3",
+		},
+		{
+			name:        "should split branches containing slashes",
+			ref:         "authz@feature/abc",
+			wantRepo:    "authz",
+			wantVersion: "feature/abc",
+		},
+		{
+			name:        "should split a host and path",
+			ref:         "github.com/org/repo@v1.2.3",
+			wantRepo:    "github.com/org/repo",
+			wantVersion: "v1.2.3",
+		},
+		{
+			name:     "should not split the user of scp-like URLs",
+			ref:      "git@github.com:org/repo",
+			wantRepo: "git@github.com:org/repo",
+		},
+		{
+			name:        "should split scp-like URLs",
+			ref:         "git@github.com:org/repo@v1.2.3",
+			wantRepo:    "git@github.com:org/repo",
+			wantVersion: "v1.2.3",
+		},
+		{
+			name:        "should split URLs with a user",
+			ref:         "https://user@gitlab.example.com/group/repo.git@main",
+			wantRepo:    "https://user@gitlab.example.com/group/repo.git",
+			wantVersion: "main",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			repo, version := splitVersion(tt.ref)
+			if repo != tt.wantRepo || version != tt.wantVersion {
+				t.Errorf("splitVersion() = %v, %v, want %v, %v", repo, version, tt.wantRepo, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestIsLocalPath(t *testing.T) {
+	tests := []struct {
+		name string
+		ref  string
+		want bool
+	}{
+		{name: "should treat names as remote", ref: "authz", want: false},
+		{name: "should treat hosts and paths as remote", ref: "gitlab.example.com/group/repo", want: false},
+		{name: "should treat URLs as remote", ref: "https://github.com/org/repo", want: false},
+		{name: "should treat scp-like URLs as remote", ref: "git@github.com:org/repo", want: false},
+		{name: "should treat the current directory as local", ref: ".", want: true},
+		{name: "should treat the parent directory as local", ref: "..", want: true},
+		{name: "should treat relative paths as local", ref: "./outreach-accounts", want: true},
+		{name: "should treat absolute paths as local", ref: "/src/outreach-accounts", want: true},
+		{name: "should treat paths without a host as local", ref: "src/outreach-accounts", want: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isLocalPath(tt.ref); got != tt.want {
+				t.Errorf("isLocalPath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalAppName(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outreach-accounts")
+	if err := os.MkdirAll(filepath.Join(dir, "deployments"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) }) //nolint:errcheck
+
+	if err := os.Chdir(filepath.Join(dir, "deployments")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{name: "should use the name of the current directory", path: ".", want: "deployments"},
+		{name: "should use the name of the parent directory", path: "..", want: "outreach-accounts"},
+		{name: "should use the name of relative paths", path: "../deployments/", want: "deployments"},
+		{name: "should use the name of absolute paths", path: "/src/authz", want: "authz"},
+		{name: "should fail for the root directory", path: "/", wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := localAppName(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("localAppName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("localAppName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRepository(t *testing.T) {
+	mirrored := &config.BoxRepositoriesConfig{
+		DefaultHost: "gitlab.example.com",
+		DefaultOrg:  "apps",
+		Hosts: map[string]string{
+			"gitlab.example.com": "https://gitlab-mirror.example.com",
+		},
+	}
+
+	tests := []struct {
+		name    string
+		ref     string
+		conf    *config.BoxRepositoriesConfig
+		want    *repository
+		wantErr bool
+	}{
+		{
+			name: "should fetch names from getoutreach by default",
+			ref:  "authz",
+			conf: &config.BoxRepositoriesConfig{},
+			want: &repository{Name: "authz", URL: "git@github.com:getoutreach/authz", Key: "github.com/getoutreach/authz"},
+		},
+		{
+			name: "should fetch names from the configured host and org",
+			ref:  "authz",
+			conf: mirrored,
+			want: &repository{Name: "authz", URL: "https://gitlab-mirror.example.com/apps/authz", Key: "gitlab.example.com/apps/authz"},
+		},
+		{
+			name: "should fetch hosts and paths over ssh",
+			ref:  "github.com/org/repo",
+			conf: mirrored,
+			want: &repository{Name: "repo", URL: "git@github.com:org/repo", Key: "github.com/org/repo"},
+		},
+		{
+			name: "should fetch hosts and paths from mapped hosts",
+			ref:  "gitlab.example.com/group/subgroup/repo",
+			conf: mirrored,
+			want: &repository{
+				Name: "repo",
+				URL:  "https://gitlab-mirror.example.com/group/subgroup/repo",
+				Key:  "gitlab.example.com/group/subgroup/repo",
+			},
+		},
+		{
+			name: "should fetch URLs as is",
+			ref:  "https://gitlab.example.com/group/repo.git",
+			conf: mirrored,
+			want: &repository{Name: "repo", URL: "https://gitlab.example.com/group/repo.git", Key: "gitlab.example.com/group/repo"},
+		},
+		{
+			name: "should fetch scp-like URLs as is",
+			ref:  "git@github.com:org/repo.git",
+			conf: &config.BoxRepositoriesConfig{},
+			want: &repository{Name: "repo", URL: "git@github.com:org/repo.git", Key: "github.com/org/repo"},
+		},
+		{
+			name:    "should fail for URLs without a path",
+			ref:     "https://github.com/",
+			conf:    &config.BoxRepositoriesConfig{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRepository(tt.ref, tt.conf)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseRepository() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseRepository() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/getoutreach/devenv/pkg/appregistry"
+	"github.com/getoutreach/devenv/pkg/kubernetesruntime"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// Rollback deploys a previous revision of an application from its deploy
+// history, see appregistry.Registry.History. If revision is 0, the revision
+// before the last deploy is deployed. Images built for that revision are
+// reused if they still exist.
+func Rollback(ctx context.Context, log logrus.FieldLogger, k kubernetes.Interface, conf *rest.Config,
+	name string, revision int, kr kubernetesruntime.Runtime) error {
+	history, err := appregistry.New(k).History(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	target, err := rollbackTarget(history, revision)
+	if err != nil {
+		return errors.Wrapf(err, "failed to rollback %s", name)
+	}
+
+	app, err := NewApp(log, k, conf, rollbackRef(target), kr)
+	if err != nil {
+		return errors.Wrap(err, "parse app")
+	}
+	app.cachedImages = target.ImageIDs
+
+	app.log.WithField("app.revision", target.Revision).Info("Rolling back application")
+	if target.Local && len(target.ImageIDs) == 0 {
+		app.log.Warn("Revision was deployed from local disk without building images, deploying the current state of its path")
+	}
+
+	if err := app.Deploy(ctx); err != nil { //nolint:govet // Why: We're OK shadowing err
+		return err
+	}
+
+	return app.recordDeployed(ctx, true)
+}
+
+// rollbackTarget returns the revision to rollback to from the deploy
+// history of an application. If revision is 0, the revision before the
+// last deploy is returned.
+func rollbackTarget(history []appregistry.Entry, revision int) (*appregistry.Entry, error) {
+	if len(history) == 0 {
+		return nil, fmt.Errorf("no deploy history found")
+	}
+
+	if revision == 0 {
+		if len(history) < 2 {
+			return nil, fmt.Errorf("no previous revision to rollback to, only revision %d was deployed", history[0].Revision)
+		}
+		return &history[len(history)-2], nil
+	}
+
+	revisions := make([]string, len(history))
+	for i := range history {
+		if history[i].Revision == revision {
+			return &history[i], nil
+		}
+		revisions[i] = strconv.Itoa(history[i].Revision)
+	}
+
+	return nil, fmt.Errorf("revision %d not found, available revisions: %s", revision, strings.Join(revisions, ", "))
+}
+
+// rollbackRef returns the reference to deploy a revision with. Revisions
+// deployed without a version are deployed at the commit that was deployed.
+func rollbackRef(e *appregistry.Entry) string {
+	if e.Local || e.Version != "" || e.Commit == "" {
+		return e.Ref()
+	}
+
+	return e.Reference + "@" + e.Commit
+}
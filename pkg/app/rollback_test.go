@@ -0,0 +1,73 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/getoutreach/devenv/pkg/appregistry"
+)
+
+func TestRollbackTarget(t *testing.T) {
+	history := []appregistry.Entry{
+		{Name: "authz", Revision: 3, Reference: "authz", Version: "v1.2.3"},
+		{Name: "authz", Revision: 4, Reference: "authz", Commit: "abc123"},
+		{Name: "authz", Revision: 5, Reference: "/src/authz", Local: true, Commit: "def456"},
+	}
+
+	tests := []struct {
+		name     string
+		history  []appregistry.Entry
+		revision int
+		wantRef  string
+		wantErr  bool
+	}{
+		{
+			name:     "should rollback to the previous revision at its commit",
+			history:  history,
+			wantRef:  "authz@abc123",
+			revision: 0,
+		},
+		{
+			name:     "should rollback to a revision at its version",
+			history:  history,
+			revision: 3,
+			wantRef:  "authz@v1.2.3",
+		},
+		{
+			name:     "should rollback to a local revision by path",
+			history:  history,
+			revision: 5,
+			wantRef:  "/src/authz",
+		},
+		{
+			name:     "should fail for revisions not in the history",
+			history:  history,
+			revision: 2,
+			wantErr:  true,
+		},
+		{
+			name:    "should fail without a previous revision",
+			history: history[:1],
+			wantErr: true,
+		},
+		{
+			name:    "should fail without a history",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rollbackTarget(tt.history, tt.revision)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("rollbackTarget() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if ref := rollbackRef(got); ref != tt.wantRef {
+				t.Errorf("rollbackRef() = %v, want %v", ref, tt.wantRef)
+			}
+		})
+	}
+}
@@ -0,0 +1,381 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/getoutreach/devenv/pkg/devenvutil"
+	"github.com/getoutreach/gobox/pkg/async"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/kubectl/pkg/polymorphichelpers"
+)
+
+const (
+	// waitInterval is how often the pods, or workloads, of an application
+	// are checked while waiting for it to become ready
+	waitInterval = 5 * time.Second
+
+	// diagnosticEvents is the number of most recent events shown for a
+	// pod that isn't ready
+	diagnosticEvents = 10
+
+	// diagnosticLogLines is the number of log lines shown for a container
+	// that isn't ready
+	diagnosticLogLines = 20
+)
+
+// workload is a Deployment, StatefulSet or DaemonSet of an application
+type workload struct {
+	// kind is the kind of the workload, e.g. deployment
+	kind string
+
+	namespace string
+	name      string
+
+	// selector is the label selector of the pods of the workload
+	selector *v1.LabelSelector
+
+	// obj is the workload itself
+	obj runtime.Object
+}
+
+// String returns the kind, namespace and name of the workload
+func (w *workload) String() string {
+	return fmt.Sprintf("%s %s/%s", w.kind, w.namespace, w.name)
+}
+
+// waitTimeout returns how long to wait for the application to become
+// ready after deploying it
+func (a *App) waitTimeout() time.Duration {
+	if a.WaitTimeout > 0 {
+		return a.WaitTimeout
+	}
+
+	return DefaultHealthCheckTimeout
+}
+
+// waitUntilReady waits for the application to become ready after
+// deploying it. If its manifest has health checks they're waited on,
+// otherwise the workloads in its namespaces are.
+func (a *App) waitUntilReady(ctx context.Context) error {
+	if a.Manifest == nil || len(a.Manifest.HealthChecks) == 0 {
+		return a.waitForWorkloads(ctx)
+	}
+
+	return a.waitForHealthChecks(ctx)
+}
+
+// waitForHealthChecks waits for the health checks from the manifest of
+// this application to pass
+func (a *App) waitForHealthChecks(ctx context.Context) error {
+	for i := range a.Manifest.HealthChecks {
+		hc := &a.Manifest.HealthChecks[i]
+
+		timeout := hc.Timeout
+		if timeout == 0 {
+			timeout = a.waitTimeout()
+		}
+
+		hctx, cancel := context.WithTimeout(ctx, timeout)
+		err := a.waitForHealthCheck(hctx, hc)
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil {
+				a.diagnosePods(ctx, hc.Namespace, hc.Selector)
+			}
+			return errors.Wrapf(err, "health check for pods '%s' in namespace %s didn't pass", hc.Selector, hc.Namespace)
+		}
+	}
+
+	return nil
+}
+
+// waitForHealthCheck waits until at least one pod matches a health check,
+// and all pods matching it are ready
+func (a *App) waitForHealthCheck(ctx context.Context, hc *HealthCheck) error {
+	log := a.log.WithField("namespace", hc.Namespace).WithField("selector", hc.Selector)
+
+	for ctx.Err() == nil {
+		pods, err := a.k.CoreV1().Pods(hc.Namespace).List(ctx, v1.ListOptions{LabelSelector: hc.Selector})
+		if err == nil {
+			unreadyPods := make([]string, 0)
+			for i := range pods.Items {
+				if !devenvutil.IsPodReady(&pods.Items[i]) {
+					unreadyPods = append(unreadyPods, pods.Items[i].Name)
+				}
+			}
+
+			if len(pods.Items) != 0 && len(unreadyPods) == 0 {
+				log.Info("Health check passed")
+				return nil
+			}
+
+			log.WithField("pods", unreadyPods).Info("Waiting for health check to pass")
+		} else if isPermanentError(err) {
+			return errors.Wrap(err, "failed to list pods")
+		} else {
+			log.WithError(err).Warn("failed to list pods")
+		}
+
+		async.Sleep(ctx, waitInterval)
+	}
+
+	return ctx.Err()
+}
+
+// isPermanentError returns true if an error from the Kubernetes API won't
+// go away by retrying, e.g. an invalid namespace or missing permissions
+func isPermanentError(err error) bool {
+	return kerrors.IsInvalid(err) || kerrors.IsForbidden(err) || kerrors.IsNotFound(err)
+}
+
+// waitForWorkloads waits for the Deployments, StatefulSets and DaemonSets
+// in the namespaces of this application to be rolled out, like kubectl
+// rollout status. Pods outside of them aren't waited on. If a workload
+// fails to roll out, the events and logs of its unready pods are logged.
+func (a *App) waitForWorkloads(ctx context.Context) error {
+	timeout := a.waitTimeout()
+	wctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var pending []workload
+	for wctx.Err() == nil {
+		workloads, err := a.listWorkloads(wctx)
+		if err != nil {
+			if isPermanentError(err) {
+				return err
+			}
+
+			a.log.WithError(err).Warn("failed to list workloads")
+			async.Sleep(wctx, waitInterval)
+			continue
+		}
+
+		if len(workloads) == 0 {
+			a.log.WithField("namespaces", a.namespaces()).Warn("No workloads found, not waiting for the application to be ready")
+			return nil
+		}
+
+		pending = make([]workload, 0)
+		for i := range workloads {
+			w := &workloads[i]
+
+			msg, done, err := rolloutStatus(w.obj) //nolint:govet // Why: We're OK shadowing err
+			if err != nil {
+				a.diagnoseWorkloads(ctx, workloads[i:i+1])
+				return errors.Wrapf(err, "%s failed to roll out", w)
+			}
+
+			if !done {
+				a.log.WithField("workload", w.String()).Info(msg)
+				pending = append(pending, *w)
+			}
+		}
+
+		if len(pending) == 0 {
+			a.log.Info("All workloads were rolled out")
+			return nil
+		}
+
+		async.Sleep(wctx, waitInterval)
+	}
+
+	// Interrupted, rather than timed out
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	a.diagnoseWorkloads(ctx, pending)
+
+	names := make([]string, len(pending))
+	for i := range pending {
+		names[i] = pending[i].String()
+	}
+	return fmt.Errorf("timed out after %s waiting for %s to roll out", timeout, strings.Join(names, ", "))
+}
+
+// listWorkloads returns the Deployments, StatefulSets and DaemonSets in
+// the namespaces of this application
+func (a *App) listWorkloads(ctx context.Context) ([]workload, error) {
+	workloads := make([]workload, 0)
+	for _, ns := range a.namespaces() {
+		deployments, err := a.k.AppsV1().Deployments(ns).List(ctx, v1.ListOptions{})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list deployments in namespace %s", ns)
+		}
+		for i := range deployments.Items {
+			d := &deployments.Items[i]
+			workloads = append(workloads, workload{"deployment", d.Namespace, d.Name, d.Spec.Selector, d})
+		}
+
+		statefulSets, err := a.k.AppsV1().StatefulSets(ns).List(ctx, v1.ListOptions{})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list statefulsets in namespace %s", ns)
+		}
+		for i := range statefulSets.Items {
+			s := &statefulSets.Items[i]
+			workloads = append(workloads, workload{"statefulset", s.Namespace, s.Name, s.Spec.Selector, s})
+		}
+
+		daemonSets, err := a.k.AppsV1().DaemonSets(ns).List(ctx, v1.ListOptions{})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list daemonsets in namespace %s", ns)
+		}
+		for i := range daemonSets.Items {
+			d := &daemonSets.Items[i]
+			workloads = append(workloads, workload{"daemonset", d.Namespace, d.Name, d.Spec.Selector, d})
+		}
+	}
+
+	return workloads, nil
+}
+
+// rolloutStatus returns the rollout status of a workload, using the same
+// semantics as kubectl rollout status. An error is returned if the
+// workload won't finish rolling out, e.g. it exceeded its progress
+// deadline. Workloads without a rolling update strategy are always done.
+func rolloutStatus(obj runtime.Object) (msg string, done bool, err error) {
+	var viewer polymorphichelpers.StatusViewer
+	switch obj.(type) {
+	case *appsv1.Deployment:
+		viewer = &polymorphichelpers.DeploymentStatusViewer{}
+	case *appsv1.StatefulSet:
+		viewer = &polymorphichelpers.StatefulSetStatusViewer{}
+	case *appsv1.DaemonSet:
+		viewer = &polymorphichelpers.DaemonSetStatusViewer{}
+	default:
+		return "", false, fmt.Errorf("rollout status isn't supported for %T", obj)
+	}
+
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return "", false, errors.Wrap(err, "failed to convert workload")
+	}
+
+	msg, done, err = viewer.Status(&unstructured.Unstructured{Object: u}, 0)
+	if err != nil && done {
+		// e.g. OnDelete, these are never rolled out by a deploy
+		return err.Error(), true, nil
+	}
+
+	return strings.TrimSpace(msg), done, err
+}
+
+// diagnoseWorkloads logs why the pods of workloads aren't ready, see
+// App.diagnosePods
+func (a *App) diagnoseWorkloads(ctx context.Context, workloads []workload) {
+	for i := range workloads {
+		w := &workloads[i]
+
+		selector, err := v1.LabelSelectorAsSelector(w.selector)
+		if err != nil {
+			a.log.WithError(err).WithField("workload", w.String()).Warn("failed to parse selector")
+			continue
+		}
+
+		a.diagnosePods(ctx, w.namespace, selector.String())
+	}
+}
+
+// diagnosePods logs the most recent events, and the last log lines of the
+// unready containers, of the unready pods matching a selector
+func (a *App) diagnosePods(ctx context.Context, namespace, selector string) {
+	pods, err := a.k.CoreV1().Pods(namespace).List(ctx, v1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		a.log.WithError(err).Warn("failed to list pods")
+		return
+	}
+
+	for i := range pods.Items {
+		po := &pods.Items[i]
+		if devenvutil.IsPodReady(po) {
+			continue
+		}
+
+		log := a.log.WithField("pod", po.Namespace+"/"+po.Name)
+		log.WithField("phase", po.Status.Phase).Warn("Pod isn't ready")
+
+		a.logPodEvents(ctx, log, po)
+
+		statuses := append(append([]corev1.ContainerStatus{}, po.Status.InitContainerStatuses...), po.Status.ContainerStatuses...)
+		for ii := range statuses {
+			if !statuses[ii].Ready {
+				a.logContainer(ctx, log, po, &statuses[ii])
+			}
+		}
+	}
+}
+
+// logPodEvents logs the most recent events of a pod
+func (a *App) logPodEvents(ctx context.Context, log logrus.FieldLogger, po *corev1.Pod) {
+	events, err := a.k.CoreV1().Events(po.Namespace).List(ctx, v1.ListOptions{
+		FieldSelector: fields.Set{"involvedObject.kind": "Pod", "involvedObject.name": po.Name}.AsSelector().String(),
+	})
+	if err != nil {
+		log.WithError(err).Warn("failed to list events")
+		return
+	}
+
+	items := events.Items
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].LastTimestamp.Before(&items[j].LastTimestamp)
+	})
+	if len(items) > diagnosticEvents {
+		items = items[len(items)-diagnosticEvents:]
+	}
+
+	for i := range items {
+		e := &items[i]
+		log.WithField("reason", e.Reason).WithField("count", e.Count).Warnf("Event: %s", e.Message)
+	}
+}
+
+// logContainer logs the state and the last log lines of a container. If
+// the container is waiting to restart, the logs of its previous run are
+// used as they're the ones that show why it failed.
+func (a *App) logContainer(ctx context.Context, log logrus.FieldLogger, po *corev1.Pod, cs *corev1.ContainerStatus) {
+	log = log.WithField("container", cs.Name).WithField("restarts", cs.RestartCount)
+
+	switch {
+	case cs.State.Waiting != nil:
+		log.WithField("reason", cs.State.Waiting.Reason).Warnf("Container is waiting: %s", cs.State.Waiting.Message)
+
+		// Containers that never started have no logs
+		if cs.RestartCount == 0 {
+			return
+		}
+	case cs.State.Terminated != nil:
+		log.WithField("reason", cs.State.Terminated.Reason).WithField("exitCode", cs.State.Terminated.ExitCode).
+			Warn("Container terminated")
+	default:
+		log.Warn("Container is running but not ready")
+	}
+
+	tail := int64(diagnosticLogLines)
+	b, err := a.k.CoreV1().Pods(po.Namespace).GetLogs(po.Name, &corev1.PodLogOptions{
+		Container: cs.Name,
+		TailLines: &tail,
+		Previous:  cs.State.Waiting != nil,
+	}).DoRaw(ctx)
+	if err != nil {
+		log.WithError(err).Warn("failed to get container logs")
+		return
+	}
+
+	for _, line := range strings.Split(strings.TrimRight(string(b), "\n"), "\n") {
+		if line != "" {
+			log.Warn(line)
+		}
+	}
+}
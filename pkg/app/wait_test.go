@@ -0,0 +1,192 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestRolloutStatus(t *testing.T) {
+	replicas := int32(2)
+	partition := int32(1)
+
+	tests := []struct {
+		name     string
+		obj      runtime.Object
+		wantDone bool
+		wantErr  bool
+	}{
+		{
+			name: "should be done when all replicas are updated and available",
+			obj: &appsv1.Deployment{
+				ObjectMeta: v1.ObjectMeta{Name: "authz", Generation: 2},
+				Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+				Status: appsv1.DeploymentStatus{
+					ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2,
+				},
+			},
+			wantDone: true,
+		},
+		{
+			name: "should wait for old replicas to terminate",
+			obj: &appsv1.Deployment{
+				ObjectMeta: v1.ObjectMeta{Name: "authz", Generation: 2},
+				Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+				Status: appsv1.DeploymentStatus{
+					ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 2, AvailableReplicas: 2,
+				},
+			},
+		},
+		{
+			name: "should wait for the spec update to be observed",
+			obj: &appsv1.Deployment{
+				ObjectMeta: v1.ObjectMeta{Name: "authz", Generation: 3},
+				Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+				Status: appsv1.DeploymentStatus{
+					ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2,
+				},
+			},
+		},
+		{
+			name: "should fail when the progress deadline is exceeded",
+			obj: &appsv1.Deployment{
+				ObjectMeta: v1.ObjectMeta{Name: "authz", Generation: 2},
+				Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+				Status: appsv1.DeploymentStatus{
+					ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 1, AvailableReplicas: 1,
+					Conditions: []appsv1.DeploymentCondition{{
+						Type:   appsv1.DeploymentProgressing,
+						Status: corev1.ConditionFalse,
+						Reason: "ProgressDeadlineExceeded",
+					}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "should wait for statefulset pods to be ready",
+			obj: &appsv1.StatefulSet{
+				ObjectMeta: v1.ObjectMeta{Name: "redis", Generation: 1},
+				Spec: appsv1.StatefulSetSpec{
+					Replicas:       &replicas,
+					UpdateStrategy: appsv1.StatefulSetUpdateStrategy{Type: appsv1.RollingUpdateStatefulSetStrategyType},
+				},
+				Status: appsv1.StatefulSetStatus{ObservedGeneration: 1, ReadyReplicas: 1},
+			},
+		},
+		{
+			name: "should wait for statefulset pods to be updated",
+			obj: &appsv1.StatefulSet{
+				ObjectMeta: v1.ObjectMeta{Name: "redis", Generation: 1},
+				Spec: appsv1.StatefulSetSpec{
+					Replicas:       &replicas,
+					UpdateStrategy: appsv1.StatefulSetUpdateStrategy{Type: appsv1.RollingUpdateStatefulSetStrategyType},
+				},
+				Status: appsv1.StatefulSetStatus{
+					ObservedGeneration: 1, ReadyReplicas: 2, CurrentRevision: "redis-1", UpdateRevision: "redis-2",
+				},
+			},
+		},
+		{
+			name: "should be done when a partitioned rollout is updated",
+			obj: &appsv1.StatefulSet{
+				ObjectMeta: v1.ObjectMeta{Name: "redis", Generation: 1},
+				Spec: appsv1.StatefulSetSpec{
+					Replicas: &replicas,
+					UpdateStrategy: appsv1.StatefulSetUpdateStrategy{
+						Type:          appsv1.RollingUpdateStatefulSetStrategyType,
+						RollingUpdate: &appsv1.RollingUpdateStatefulSetStrategy{Partition: &partition},
+					},
+				},
+				Status: appsv1.StatefulSetStatus{ObservedGeneration: 1, ReadyReplicas: 2, UpdatedReplicas: 1},
+			},
+			wantDone: true,
+		},
+		{
+			name: "should be done for statefulsets that are updated on delete",
+			obj: &appsv1.StatefulSet{
+				ObjectMeta: v1.ObjectMeta{Name: "redis", Generation: 1},
+				Spec: appsv1.StatefulSetSpec{
+					Replicas:       &replicas,
+					UpdateStrategy: appsv1.StatefulSetUpdateStrategy{Type: appsv1.OnDeleteStatefulSetStrategyType},
+				},
+			},
+			wantDone: true,
+		},
+		{
+			name:    "should fail for unsupported workloads",
+			obj:     &corev1.Pod{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			_, done, err := rolloutStatus(tt.obj)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("rolloutStatus() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if done != tt.wantDone {
+				t.Errorf("rolloutStatus() done = %v, want %v", done, tt.wantDone)
+			}
+		})
+	}
+}
+
+func TestApp_namespaces(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "authz")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) }) //nolint:errcheck
+
+	if err := os.Chdir(dir); err != nil { //nolint:govet // Why: We're OK shadowing err
+		t.Fatal(err)
+	}
+
+	a, err := NewApp(logrus.New(), nil, nil, ".", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"authz", "authz--bento1a"}
+	if got := a.namespaces(); !reflect.DeepEqual(got, want) {
+		t.Errorf("App.namespaces() = %v, want %v", got, want)
+	}
+}
+
+func TestApp_waitForWorkloads(t *testing.T) {
+	k := fake.NewSimpleClientset()
+	k.PrependReactor("list", "deployments", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, kerrors.NewForbidden(schema.GroupResource{Group: "apps", Resource: "deployments"}, "", nil)
+	})
+
+	a := &App{log: logrus.New(), k: k, RepositoryName: "authz", WaitTimeout: time.Minute}
+
+	start := time.Now()
+	if err := a.waitForWorkloads(context.Background()); err == nil {
+		t.Error("App.waitForWorkloads() should fail when listing workloads is forbidden")
+	}
+
+	if elapsed := time.Since(start); elapsed > waitInterval {
+		t.Errorf("App.waitForWorkloads() retried for %s, want it to fail immediately", elapsed)
+	}
+}
@@ -0,0 +1,263 @@
+// Package appregistry implements the registry of applications deployed
+// into a developer environment, and the history of their deploys. The
+// registry is stored in ConfigMaps inside of the cluster, so it's always
+// in sync with what's deployed.
+package appregistry
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// Namespace is the namespace the registry is stored in
+	Namespace = "kube-system"
+
+	// ConfigMapName is the name of the ConfigMap the registry is
+	// stored in
+	ConfigMapName = "devenv-deployed-apps"
+
+	// HistoryConfigMapName is the name of the ConfigMap the deploy
+	// history of applications is stored in
+	HistoryConfigMapName = "devenv-deploy-history"
+
+	// MaxHistory is the number of deploys kept in the history of an
+	// application
+	MaxHistory = 10
+)
+
+// invalidKeyReg matches characters that aren't allowed in ConfigMap keys
+var invalidKeyReg = regexp.MustCompile(`[^-._a-zA-Z0-9]`)
+
+// Entry is an application deployed into the developer environment
+type Entry struct {
+	// Name is the name of the application
+	Name string `json:"name"`
+
+	// Revision is the number of this deploy in the history of the
+	// application, starting at 1
+	Revision int `json:"revision"`
+
+	// Reference is what the application was deployed from, without the
+	// version, e.g. authz, github.com/org/repo or the absolute path of a
+	// local application
+	Reference string `json:"reference"`
+
+	// Version is the version that was requested, if any
+	Version string `json:"version,omitempty"`
+
+	// Commit is the git commit that was deployed, if known
+	Commit string `json:"commit,omitempty"`
+
+	// Local denotes if the application was deployed from local disk
+	Local bool `json:"local"`
+
+	// DeployedAt is when the application was last deployed
+	DeployedAt time.Time `json:"deployedAt"`
+
+	// Namespaces are the namespaces the application is deployed into
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// Images are the docker images of the application, without a tag
+	Images []string `json:"images,omitempty"`
+
+	// ImageIDs are the IDs of the docker images that were built for this
+	// deploy, by image name
+	ImageIDs map[string]string `json:"imageIDs,omitempty"`
+}
+
+// Ref returns the reference, including the version, to deploy this
+// application again, e.g. authz@v1.2.3
+func (e *Entry) Ref() string {
+	if e.Version == "" {
+		return e.Reference
+	}
+
+	return e.Reference + "@" + e.Version
+}
+
+// Registry is the registry of applications deployed into a developer
+// environment
+type Registry struct {
+	k kubernetes.Interface
+}
+
+// New returns the registry of the cluster k talks to
+func New(k kubernetes.Interface) *Registry {
+	return &Registry{k: k}
+}
+
+// key returns the ConfigMap key an application is stored under
+func key(name string) string {
+	return invalidKeyReg.ReplaceAllString(name, "_")
+}
+
+// List returns all deployed applications, sorted by name
+func (r *Registry) List(ctx context.Context) ([]Entry, error) {
+	cm, err := r.k.CoreV1().ConfigMaps(Namespace).Get(ctx, ConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to get deployed applications")
+	}
+
+	entries := make([]Entry, 0, len(cm.Data))
+	for k, v := range cm.Data {
+		var e Entry
+		if err := json.Unmarshal([]byte(v), &e); err != nil { //nolint:govet // Why: We're OK shadowing err
+			return nil, errors.Wrapf(err, "failed to parse deployed application %s", k)
+		}
+		entries = append(entries, e)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+
+	return entries, nil
+}
+
+// Get returns a deployed application by name, if the application isn't
+// deployed nil is returned
+func (r *Registry) Get(ctx context.Context, name string) (*Entry, error) {
+	entries, err := r.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range entries {
+		if entries[i].Name == name {
+			return &entries[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+// History returns the deploys of an application, oldest first. Only the
+// last MaxHistory deploys are kept.
+func (r *Registry) History(ctx context.Context, name string) ([]Entry, error) {
+	cm, err := r.k.CoreV1().ConfigMaps(Namespace).Get(ctx, HistoryConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to get deploy history")
+	}
+
+	return decodeHistory(cm.Data[key(name)])
+}
+
+// decodeHistory parses the deploy history of an application
+func decodeHistory(s string) ([]Entry, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var history []Entry
+	return history, errors.Wrap(json.Unmarshal([]byte(s), &history), "failed to parse deploy history")
+}
+
+// Put records that an application was deployed, replacing the previous
+// deployment of it. The deploy is added to the history of the application
+// and its revision set.
+func (r *Registry) Put(ctx context.Context, e *Entry) error {
+	err := r.update(ctx, HistoryConfigMapName, func(data map[string]string) error {
+		history, err := decodeHistory(data[key(e.Name)])
+		if err != nil {
+			return err
+		}
+
+		e.Revision = 1
+		if len(history) != 0 {
+			e.Revision = history[len(history)-1].Revision + 1
+		}
+
+		history = append(history, *e)
+		if len(history) > MaxHistory {
+			history = history[len(history)-MaxHistory:]
+		}
+
+		b, err := json.Marshal(history)
+		if err != nil {
+			return errors.Wrap(err, "failed to encode deploy history")
+		}
+
+		data[key(e.Name)] = string(b)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return r.update(ctx, ConfigMapName, func(data map[string]string) error {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return errors.Wrap(err, "failed to encode deployed application")
+		}
+
+		data[key(e.Name)] = string(b)
+		return nil
+	})
+}
+
+// Remove records that an application was deleted, its history is kept
+func (r *Registry) Remove(ctx context.Context, name string) error {
+	return r.update(ctx, ConfigMapName, func(data map[string]string) error {
+		delete(data, key(name))
+		return nil
+	})
+}
+
+// update modifies the data of a ConfigMap of the registry, creating it if
+// it doesn't exist. Applications are deployed in parallel, so conflicting
+// updates are retried.
+func (r *Registry) update(ctx context.Context, name string, fn func(data map[string]string) error) error {
+	cms := r.k.CoreV1().ConfigMaps(Namespace)
+
+	err := retry.OnError(retry.DefaultRetry, func(err error) bool {
+		return kerrors.IsConflict(err) || kerrors.IsAlreadyExists(err)
+	}, func() error {
+		cm, err := cms.Get(ctx, name, metav1.GetOptions{})
+		if kerrors.IsNotFound(err) {
+			cm = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: Namespace,
+				},
+				Data: make(map[string]string),
+			}
+			if err := fn(cm.Data); err != nil { //nolint:govet // Why: We're OK shadowing err
+				return err
+			}
+
+			_, err = cms.Create(ctx, cm, metav1.CreateOptions{})
+			return err
+		} else if err != nil {
+			return err
+		}
+
+		if cm.Data == nil {
+			cm.Data = make(map[string]string)
+		}
+		if err := fn(cm.Data); err != nil { //nolint:govet // Why: We're OK shadowing err
+			return err
+		}
+
+		_, err = cms.Update(ctx, cm, metav1.UpdateOptions{})
+		return err
+	})
+
+	return errors.Wrap(err, "failed to update deployed applications")
+}
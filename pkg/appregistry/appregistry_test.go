@@ -0,0 +1,140 @@
+package appregistry
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestEntry_Ref(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry Entry
+		want  string
+	}{
+		{
+			name:  "should return the reference without a version",
+			entry: Entry{Reference: "authz"},
+			want:  "authz",
+		},
+		{
+			name:  "should return the reference with its version",
+			entry: Entry{Reference: "github.com/org/repo", Version: "v1.2.3"},
+			want:  "github.com/org/repo@v1.2.3",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.entry.Ref(); got != tt.want {
+				t.Errorf("Entry.Ref() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	ctx := context.Background()
+	r := New(fake.NewSimpleClientset())
+
+	entries, err := r.List(ctx)
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("Registry.List() = %v, %v without a ConfigMap, want nothing", entries, err)
+	}
+
+	deployedAt := time.Date(2021, 9, 1, 0, 0, 0, 0, time.UTC)
+	authz := Entry{Name: "authz", Reference: "authz", Commit: "abc123", DeployedAt: deployedAt, Namespaces: []string{"authz--bento1a"}}
+	local := Entry{Name: "flagship", Reference: "/src/flagship", Local: true, DeployedAt: deployedAt}
+	for _, e := range []*Entry{&local, &authz} {
+		if err := r.Put(ctx, e); err != nil { //nolint:govet // Why: We're OK shadowing err
+			t.Fatal(err)
+		}
+	}
+	previous := authz
+
+	authz.Version = "v1.2.3"
+	if err := r.Put(ctx, &authz); err != nil { //nolint:govet // Why: We're OK shadowing err
+		t.Fatal(err)
+	}
+
+	entries, err = r.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []Entry{authz, local}; !reflect.DeepEqual(entries, want) {
+		t.Errorf("Registry.List() = %v, want %v", entries, want)
+	}
+
+	if err := r.Remove(ctx, "flagship"); err != nil { //nolint:govet // Why: We're OK shadowing err
+		t.Fatal(err)
+	}
+
+	if e, err := r.Get(ctx, "flagship"); err != nil || e != nil { //nolint:govet // Why: We're OK shadowing err
+		t.Errorf("Registry.Get() = %v, %v after Remove, want nil", e, err)
+	}
+
+	if e, err := r.Get(ctx, "authz"); err != nil || e == nil || e.Version != "v1.2.3" { //nolint:govet // Why: We're OK shadowing err
+		t.Errorf("Registry.Get() = %v, %v, want authz@v1.2.3", e, err)
+	}
+
+	history, err := r.History(ctx, "authz")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []Entry{previous, authz}; !reflect.DeepEqual(history, want) {
+		t.Errorf("Registry.History() = %v, want %v", history, want)
+	}
+
+	// The history of deleted applications is kept
+	if history, err := r.History(ctx, "flagship"); err != nil || len(history) != 1 { //nolint:govet // Why: We're OK shadowing err
+		t.Errorf("Registry.History() = %v, %v after Remove, want revision 1", history, err)
+	}
+}
+
+func TestRegistry_HistoryLimit(t *testing.T) {
+	ctx := context.Background()
+	r := New(fake.NewSimpleClientset())
+
+	for i := 0; i < MaxHistory+2; i++ {
+		if err := r.Put(ctx, &Entry{Name: "authz", Reference: "authz"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	history, err := r.History(ctx, "authz")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(history) != MaxHistory || history[0].Revision != 3 || history[MaxHistory-1].Revision != MaxHistory+2 {
+		t.Errorf("Registry.History() = %v, want revisions 3 to %d", history, MaxHistory+2)
+	}
+}
+
+func TestPrintEntries(t *testing.T) {
+	var b bytes.Buffer
+	err := PrintEntries(&b, []Entry{
+		{Name: "authz", Reference: "authz", Commit: "abc1234def", DeployedAt: time.Now().Add(-time.Hour), Namespaces: []string{"authz", "authz--bento1a"}},
+		{Name: "flagship", Reference: "/src/flagship", Version: "v1.2.3", Local: true, DeployedAt: time.Now().Add(-time.Minute)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := strings.Fields(b.String())
+	want := []string{
+		"NAME", "VERSION", "SOURCE", "DEPLOYED", "NAMESPACES",
+		"authz", "latest", "(abc1234)", "authz", "60m", "ago", "authz,authz--bento1a",
+		"flagship", "v1.2.3", "local:", "/src/flagship", "60s", "ago",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("PrintEntries() = %q, want %q", b.String(), want)
+	}
+}
@@ -0,0 +1,37 @@
+package appregistry
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ManagedNamespaceLabel is set on namespaces that were created by
+// deploying an application with devenv. Only these namespaces are removed
+// when devenv stops managing a cluster it doesn't own, e.g. an adopted
+// cluster.
+const ManagedNamespaceLabel = "devenv.outreach.io/managed"
+
+// ManagedNamespaceSelector selects the namespaces created by devenv, see
+// ManagedNamespaceLabel
+const ManagedNamespaceSelector = ManagedNamespaceLabel + "=true"
+
+// MarkNamespacesManaged labels namespaces as created by devenv, see
+// ManagedNamespaceLabel. Namespaces that don't exist are skipped.
+func MarkNamespacesManaged(ctx context.Context, k kubernetes.Interface, namespaces []string) error {
+	patch := []byte(fmt.Sprintf(`{"metadata":{"labels":{%q:"true"}}}`, ManagedNamespaceLabel))
+
+	for _, ns := range namespaces {
+		_, err := k.CoreV1().Namespaces().Patch(ctx, ns, types.MergePatchType, patch, metav1.PatchOptions{})
+		if err != nil && !kerrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to label namespace %s", ns)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package appregistry
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/duration"
+)
+
+// PrintEntries prints a table of deployed applications
+func PrintEntries(out io.Writer, entries []Entry) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVERSION\tSOURCE\tDEPLOYED\tNAMESPACES")
+
+	for i := range entries {
+		e := &entries[i]
+
+		version := e.Version
+		if version == "" {
+			version = "latest"
+		}
+		if len(e.Commit) >= 7 {
+			version += " (" + e.Commit[:7] + ")"
+		}
+
+		source := e.Reference
+		if e.Local {
+			source = "local: " + source
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s ago\t%s\n", e.Name, version, source,
+			duration.HumanDuration(time.Since(e.DeployedAt)), strings.Join(e.Namespaces, ","))
+	}
+
+	return w.Flush()
+}
@@ -77,15 +77,12 @@ func GetYesOrNoInput(ctx context.Context) (bool, error) {
 	return false, nil
 }
 
-// RunKubernetesCommand runs a command with KUBECONFIG set. This command runs in the
-// provided working directory
-func RunKubernetesCommand(ctx context.Context, wd string, onlyOutputOnError bool, name string, args ...string) error {
-	ctx = trace.StartCall(ctx, "devenvutil.RunKubernetesCommand", olog.F{"command": name})
-	defer trace.EndCall(ctx)
-
+// NewKubernetesCommand creates a command with KUBECONFIG set, that runs
+// in the provided working directory
+func NewKubernetesCommand(ctx context.Context, wd, name string, args ...string) (*exec.Cmd, error) {
 	kubeConfPath, err := kube.GetKubeConfig()
 	if err != nil {
-		return errors.Wrap(err, "failed to get kubeconfig")
+		return nil, errors.Wrap(err, "failed to get kubeconfig")
 	}
 
 	cmd := exec.CommandContext(ctx, name, args...)
@@ -94,6 +91,20 @@ func RunKubernetesCommand(ctx context.Context, wd string, onlyOutputOnError bool
 		fmt.Sprintf("KUBECONFIG=%s", kubeConfPath),
 		fmt.Sprintf("DEVENV_VERSION=%s", app.Version),
 	)
+	return cmd, nil
+}
+
+// RunKubernetesCommand runs a command with KUBECONFIG set. This command runs in the
+// provided working directory
+func RunKubernetesCommand(ctx context.Context, wd string, onlyOutputOnError bool, name string, args ...string) error {
+	ctx = trace.StartCall(ctx, "devenvutil.RunKubernetesCommand", olog.F{"command": name})
+	defer trace.EndCall(ctx)
+
+	cmd, err := NewKubernetesCommand(ctx, wd, name, args...)
+	if err != nil {
+		return err
+	}
+
 	if !onlyOutputOnError {
 		cmd.Stdout = os.Stdout
 		cmd.Stdin = os.Stdin
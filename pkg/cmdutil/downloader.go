@@ -106,16 +106,25 @@ func downloadPureFile(resp *http.Response, execPath string) error {
 	return nil
 }
 
-// EnsureBinary downloads a binary if it's not found, based on the name of the binary
-// otherwise it returns the path to it.
-func EnsureBinary(log logrus.FieldLogger, name, downloadDesc, downloadURL, archiveFileName string) (string, error) { //nolint:funlen
+// GetDependencyDir returns the directory that downloaded binaries
+// are stored in.
+func GetDependencyDir() (string, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return "", err
 	}
 
 	// TODO: We need to figure out where to store these paths we use.
-	sourceDir := filepath.Join(homeDir, ".local", "dev-environment", ".deps")
+	return filepath.Join(homeDir, ".local", "dev-environment", ".deps"), nil
+}
+
+// EnsureBinary downloads a binary if it's not found, based on the name of the binary
+// otherwise it returns the path to it.
+func EnsureBinary(log logrus.FieldLogger, name, downloadDesc, downloadURL, archiveFileName string) (string, error) { //nolint:funlen
+	sourceDir, err := GetDependencyDir()
+	if err != nil {
+		return "", err
+	}
 	execPath := filepath.Join(sourceDir, name)
 
 	// TODO: better support for other archives in the future
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/getoutreach/gobox/pkg/box"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// BoxConfig is devenv specific configuration that is stored in the
+// box configuration, but isn't (yet) known to gobox.
+type BoxConfig struct {
+	// DeveloperEnvironmentConfig is the devenv section of the box
+	DeveloperEnvironmentConfig BoxDeveloperEnvironmentConfig `yaml:"devenv"`
+}
+
+// BoxDeveloperEnvironmentConfig is the devenv section of the box
+type BoxDeveloperEnvironmentConfig struct {
+	// RuntimeConfig stores configuration specific to different devenv
+	// runtimes.
+	RuntimeConfig BoxRuntimeConfig `yaml:"runtimeConfig"`
+
+	// Repositories configures where application repositories are
+	// fetched from.
+	Repositories BoxRepositoriesConfig `yaml:"repositories"`
+}
+
+// BoxRepositoriesConfig configures where application repositories are
+// fetched from.
+type BoxRepositoriesConfig struct {
+	// DefaultHost is the host repositories referenced by name, e.g. authz,
+	// are fetched from. Defaults to github.com.
+	DefaultHost string `yaml:"defaultHost"`
+
+	// DefaultOrg is the org, or group, repositories referenced by name are
+	// in. Defaults to getoutreach.
+	DefaultOrg string `yaml:"defaultOrg"`
+
+	// Hosts maps a host to the URL repositories on it are cloned from,
+	// e.g. gitlab.example.com: https://gitlab-mirror.example.com/. The path
+	// of the repository is appended to the URL. Hosts that aren't mapped
+	// are cloned over ssh, e.g. git@github.com:.
+	Hosts map[string]string `yaml:"hosts"`
+}
+
+// BoxRuntimeConfig stores configuration specific to different runtimes.
+type BoxRuntimeConfig struct {
+	// KubernetesVersion is the recommended version of Kubernetes
+	// to use for clusters that devenv creates.
+	KubernetesVersion string `yaml:"kubernetesVersion"`
+
+	// Loft is configuration for the loft runtime
+	Loft BoxLoftRuntimeConfig `yaml:"loft"`
+}
+
+// BoxLoftRuntimeConfig is configuration for loft runtimes, the URL
+// of the loft instance is stored in box.LoftRuntimeConfig.
+type BoxLoftRuntimeConfig struct {
+	// Template is the vcluster template to create vclusters from
+	Template string `yaml:"template"`
+
+	// SleepAfter is the duration of inactivity, e.g. 1h, after which
+	// vclusters are put to sleep
+	SleepAfter string `yaml:"sleepAfter"`
+
+	// Cluster is the loft cluster to create vclusters in
+	Cluster string `yaml:"cluster"`
+
+	// Space is the space to create vclusters in
+	Space string `yaml:"space"`
+}
+
+// boxStorage is the storage wrapper of a box configuration, see
+// box.Storage
+type boxStorage struct {
+	Config *BoxConfig `yaml:"config"`
+}
+
+// LoadBoxConfig reads the devenv specific configuration from the
+// box configuration on disk. If no box exists an empty configuration
+// is returned.
+func LoadBoxConfig() (*BoxConfig, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read user's home dir")
+	}
+
+	f, err := os.Open(filepath.Join(homeDir, box.BoxConfigPath, box.BoxConfigFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &BoxConfig{}, nil
+		}
+		return nil, errors.Wrap(err, "failed to open box config for reading")
+	}
+	defer f.Close()
+
+	var s boxStorage
+	if err := yaml.NewDecoder(f).Decode(&s); err != nil { //nolint:govet // Why: We're OK shadowing err
+		return nil, errors.Wrap(err, "failed to decode box config")
+	}
+
+	if s.Config == nil {
+		return &BoxConfig{}, nil
+	}
+
+	return s.Config, nil
+}
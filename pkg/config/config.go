@@ -3,17 +3,174 @@ package config
 
 import (
 	"context"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/getoutreach/devenv/pkg/filelock"
 	"github.com/pkg/errors"
 	"gopkg.in/yaml.v2"
 )
 
+const (
+	// Version is the current schema version of the config file, see
+	// migrations for how older versions are upgraded.
+	Version = 1
+
+	// DefaultContext is the context used when no config file exists
+	DefaultContext = "kind:dev-environment"
+)
+
 type Config struct {
+	// Version is the schema version of this config.
+	Version int `yaml:"version"`
+
 	// CurrentContext is the current devenv in use.
 	CurrentContext string `yaml:"currentContext"`
+
+	// KubernetesVersion is the version of Kubernetes to use when creating
+	// clusters, this takes precedence over the version recommended by
+	// the box.
+	KubernetesVersion string `yaml:"kubernetesVersion,omitempty"`
+
+	// AdoptedClusters are clusters that weren't created by devenv, but
+	// have been adopted by the existing runtime.
+	AdoptedClusters []*AdoptedCluster `yaml:"adoptedClusters,omitempty"`
+
+	// Contexts are settings specific to a context.
+	Contexts []*ContextConfig `yaml:"contexts,omitempty"`
+
+	// BoxOverrides are values that take precedence over those in the
+	// box configuration.
+	BoxOverrides BoxOverrides `yaml:"boxOverrides,omitempty"`
+}
+
+// ContextConfig stores settings specific to a context
+type ContextConfig struct {
+	// Name is the name of the context, e.g. kind:dev-environment.
+	Name string `yaml:"name"`
+
+	// RuntimeOptions are runtime specific options, e.g. the parameters
+	// the cluster was created with.
+	RuntimeOptions map[string]string `yaml:"runtimeOptions,omitempty"`
+
+	// Snapshot is the snapshot to provision this context from by default.
+	Snapshot SnapshotConfig `yaml:"snapshot,omitempty"`
+
+	// DeployedApps are the applications that have been deployed into
+	// this context with deploy-app.
+	DeployedApps []string `yaml:"deployedApps,omitempty"`
+
+	// Tunnel are the preferences for devenv tunnel.
+	Tunnel TunnelConfig `yaml:"tunnel,omitempty"`
+}
+
+// SnapshotConfig stores the snapshot to provision a context from
+type SnapshotConfig struct {
+	// Target is the snapshot target, e.g. base.
+	Target string `yaml:"target,omitempty"`
+
+	// Channel is the snapshot channel, e.g. stable.
+	Channel string `yaml:"channel,omitempty"`
+}
+
+// TunnelConfig stores the preferences for devenv tunnel
+type TunnelConfig struct {
+	// LocalApps are the applications to run through local-app when
+	// no --local-app flag was provided.
+	LocalApps []string `yaml:"localApps,omitempty"`
+}
+
+// AdoptedCluster is an already existing cluster that is being used
+// as a devenv.
+type AdoptedCluster struct {
+	// Name is the name of this cluster in devenv contexts.
+	Name string `yaml:"name"`
+
+	// KubeConfigPath is the path to the kubeconfig that contains
+	// the context for this cluster.
+	KubeConfigPath string `yaml:"kubeConfigPath"`
+
+	// Context is the name of the context inside of KubeConfigPath
+	// to use.
+	Context string `yaml:"context"`
+
+	// PreexistingNamespaces are the namespaces that existed before
+	// the cluster was adopted. These are never removed by devenv.
+	PreexistingNamespaces []string `yaml:"preexistingNamespaces"`
+}
+
+// NewConfig returns the config used when no config file exists
+func NewConfig() *Config {
+	return &Config{
+		Version:        Version,
+		CurrentContext: DefaultContext,
+	}
+}
+
+// ContextName returns the name of the context of a cluster created by
+// a runtime
+func ContextName(runtime, clusterName string) string {
+	return runtime + ":" + clusterName
+}
+
+// GetAdoptedCluster returns an adopted cluster by name, if not
+// found nil is returned.
+func (c *Config) GetAdoptedCluster(name string) *AdoptedCluster {
+	for _, ac := range c.AdoptedClusters {
+		if ac.Name == name {
+			return ac
+		}
+	}
+
+	return nil
+}
+
+// GetContextConfig returns the settings of a context by name, if not
+// found nil is returned.
+func (c *Config) GetContextConfig(name string) *ContextConfig {
+	for _, cc := range c.Contexts {
+		if cc.Name == name {
+			return cc
+		}
+	}
+
+	return nil
+}
+
+// EnsureContextConfig returns the settings of a context by name,
+// creating them if they don't exist.
+func (c *Config) EnsureContextConfig(name string) *ContextConfig {
+	if cc := c.GetContextConfig(name); cc != nil {
+		return cc
+	}
+
+	cc := &ContextConfig{Name: name}
+	c.Contexts = append(c.Contexts, cc)
+	return cc
+}
+
+// AddDeployedApp records that an application has been deployed
+func (cc *ContextConfig) AddDeployedApp(app string) {
+	for _, a := range cc.DeployedApps {
+		if a == app {
+			return
+		}
+	}
+
+	cc.DeployedApps = append(cc.DeployedApps, app)
+}
+
+// RemoveDeployedApp records that an application has been deleted
+func (cc *ContextConfig) RemoveDeployedApp(app string) {
+	apps := make([]string, 0, len(cc.DeployedApps))
+	for _, a := range cc.DeployedApps {
+		if a != app {
+			apps = append(apps, a)
+		}
+	}
+	cc.DeployedApps = apps
 }
 
 // ParseContext returns the runtime and name of the current context
@@ -36,46 +193,135 @@ func getConfigFile() (string, error) {
 	return filepath.Join(homeDir, ".config", "devenv", "config.yaml"), nil
 }
 
-// LoadConfig reads the config from disk
-func LoadConfig(ctx context.Context) (*Config, error) {
+// LoadConfig reads the config from disk, migrating it to the current
+// version if needed. If no config exists, NewConfig is returned. Settings
+// overridden by DEVENV_* environment variables are applied, so the
+// returned config shouldn't be saved, see UpdateConfig.
+func LoadConfig(_ context.Context) (*Config, error) {
 	confPath, err := getConfigFile()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get config file path")
 	}
 
-	f, err := os.Open(confPath)
+	conf, err := loadConfig(confPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conf.applyEnvOverrides(); err != nil { //nolint:govet // Why: We're OK shadowing err
+		return nil, err
+	}
+
+	return conf, nil
+}
+
+// loadConfig reads and migrates the config at confPath
+func loadConfig(confPath string) (*Config, error) {
+	b, err := ioutil.ReadFile(confPath)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			// For now stub the config and return the kind devenv. In the future
-			// we might want to do something more sophisticated here.
-			return &Config{CurrentContext: "kind:dev-environment"}, nil
+			return NewConfig(), nil
 		}
-		return nil, errors.Wrap(err, "failed to open config file for reading")
+		return nil, errors.Wrap(err, "failed to read config file")
 	}
-	defer f.Close()
 
-	var conf *Config
-	err = yaml.NewDecoder(f).Decode(&conf)
-	return conf, err
+	b, err = migrate(b)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to migrate config file")
+	}
+
+	conf := NewConfig()
+	if err := yaml.Unmarshal(b, conf); err != nil { //nolint:govet // Why: We're OK shadowing err
+		return nil, errors.Wrap(err, "failed to parse config file")
+	}
+
+	return conf, nil
 }
 
-// SaveConfig saves a provided config to disk
+// SaveConfig saves a provided config to disk. The config is written
+// atomically while holding the config file lock. To modify the existing
+// config UpdateConfig should be used instead, which doesn't lose changes
+// made by other devenv processes in the meantime.
 func SaveConfig(_ context.Context, c *Config) error {
 	confPath, err := getConfigFile()
 	if err != nil {
 		return errors.Wrap(err, "failed to get config file path")
 	}
 
-	err = os.MkdirAll(filepath.Dir(confPath), 0755)
+	unlock, err := lockConfig(confPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return writeConfig(confPath, c)
+}
+
+// UpdateConfig loads the config, calls fn to modify it and then saves
+// it. The config file lock is held throughout, so concurrent updates
+// don't overwrite each other. If fn returns an error the config isn't
+// saved. Unlike LoadConfig, environment variable overrides aren't
+// applied to the config passed to fn.
+func UpdateConfig(_ context.Context, fn func(*Config) error) error {
+	confPath, err := getConfigFile()
+	if err != nil {
+		return errors.Wrap(err, "failed to get config file path")
+	}
+
+	unlock, err := lockConfig(confPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	conf, err := loadConfig(confPath)
 	if err != nil {
-		return errors.Wrap(err, "failed to ensure config dirs existed")
+		return err
+	}
+
+	if err := fn(conf); err != nil { //nolint:govet // Why: We're OK shadowing err
+		return err
 	}
 
-	f, err := os.Create(confPath)
+	return writeConfig(confPath, conf)
+}
+
+// writeConfig writes a config to a temporary file and then renames it
+// to confPath, so readers never observe a partially written config.
+func writeConfig(confPath string, c *Config) error {
+	c.Version = Version
+
+	b, err := yaml.Marshal(c)
 	if err != nil {
-		return errors.Wrap(err, "failed to open config file for writing")
+		return errors.Wrap(err, "failed to encode config")
 	}
-	defer f.Close()
 
-	return yaml.NewEncoder(f).Encode(c)
+	f, err := ioutil.TempFile(filepath.Dir(confPath), filepath.Base(confPath)+".*")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temporary config file")
+	}
+	defer os.Remove(f.Name()) //nolint:errcheck // Why: It's renamed on success
+
+	if _, err := f.Write(b); err != nil { //nolint:govet // Why: We're OK shadowing err
+		f.Close() //nolint:errcheck // Why: Already failed
+		return errors.Wrap(err, "failed to write config file")
+	}
+
+	if err := f.Sync(); err != nil { //nolint:govet // Why: We're OK shadowing err
+		f.Close() //nolint:errcheck // Why: Already failed
+		return errors.Wrap(err, "failed to write config file")
+	}
+
+	if err := f.Close(); err != nil { //nolint:govet // Why: We're OK shadowing err
+		return errors.Wrap(err, "failed to write config file")
+	}
+
+	return errors.Wrap(os.Rename(f.Name(), confPath), "failed to replace config file")
+}
+
+// lockConfig takes an exclusive lock on the config file, blocking until
+// it's available. The returned function releases the lock.
+func lockConfig(confPath string) (func(), error) {
+	unlock, err := filelock.Lock(confPath + ".lock")
+	return unlock, errors.Wrap(err, "failed to lock config file")
 }
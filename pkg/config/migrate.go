@@ -0,0 +1,48 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// migrations upgrade a config file from one version to the next, the
+// migration at index i upgrades version i to version i+1. When changing
+// the schema in an incompatible way, bump Version and add a migration.
+//
+//nolint:gochecknoglobals // Why: This is a static list
+var migrations = []func([]byte) ([]byte, error){
+	migrateV0,
+}
+
+// migrate upgrades a config file to the current version
+func migrate(b []byte) ([]byte, error) {
+	var header struct {
+		Version int `yaml:"version"`
+	}
+	if err := yaml.Unmarshal(b, &header); err != nil {
+		return nil, errors.Wrap(err, "failed to parse config file version")
+	}
+
+	if header.Version > Version {
+		return nil, fmt.Errorf("config file version %d is newer than the supported version %d, please update devenv",
+			header.Version, Version)
+	}
+
+	for v := header.Version; v < Version; v++ {
+		var err error
+		b, err = migrations[v](b)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to migrate config file from version %d", v)
+		}
+	}
+
+	return b, nil
+}
+
+// migrateV0 upgrades unversioned configs, which only stored the current
+// context. Version 1 only added fields, so they're read as is.
+func migrateV0(b []byte) ([]byte, error) {
+	return b, nil
+}
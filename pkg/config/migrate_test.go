@@ -0,0 +1,72 @@
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_loadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		want    *Config
+		wantErr bool
+	}{
+		{
+			name: "should migrate unversioned configs",
+			file: "currentContext: loft:jane-devenv\n",
+			want: &Config{
+				Version:        Version,
+				CurrentContext: "loft:jane-devenv",
+			},
+		},
+		{
+			name: "should load current configs as is",
+			file: `version: 1
+currentContext: kind:dev-environment
+contexts:
+- name: kind:dev-environment
+  snapshot:
+    target: flagship
+  deployedApps:
+  - authz
+`,
+			want: &Config{
+				Version:        Version,
+				CurrentContext: "kind:dev-environment",
+				Contexts: []*ContextConfig{
+					{
+						Name:         "kind:dev-environment",
+						Snapshot:     SnapshotConfig{Target: "flagship"},
+						DeployedApps: []string{"authz"},
+					},
+				},
+			},
+		},
+		{
+			name:    "should refuse configs from newer versions",
+			file:    "version: 1000\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			confPath := filepath.Join(t.TempDir(), "config.yaml")
+			if err := ioutil.WriteFile(confPath, []byte(tt.file), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := loadConfig(confPath)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loadConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
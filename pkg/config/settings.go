@@ -0,0 +1,439 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/getoutreach/gobox/pkg/box"
+	"github.com/pkg/errors"
+)
+
+// BoxOverrides are values that take precedence over those in the
+// box configuration, see Config.ApplyBoxOverrides
+type BoxOverrides struct {
+	// EnabledRuntimes overrides the runtimes that are enabled
+	EnabledRuntimes []string `yaml:"enabledRuntimes,omitempty"`
+
+	// ImageRegistry overrides the registry images are pulled from
+	ImageRegistry string `yaml:"imageRegistry,omitempty"`
+
+	// LoftURL overrides the URL of the loft instance
+	LoftURL string `yaml:"loftURL,omitempty"`
+
+	// VaultEnabled overrides whether vault is used
+	VaultEnabled *bool `yaml:"vaultEnabled,omitempty"`
+
+	// VaultAddress overrides the address of vault
+	VaultAddress string `yaml:"vaultAddress,omitempty"`
+
+	// DefaultSnapshot overrides the snapshot target used by default
+	DefaultSnapshot string `yaml:"defaultSnapshot,omitempty"`
+}
+
+// SettingType is the type of the value of a setting
+type SettingType string
+
+const (
+	// SettingTypeString is a string setting
+	SettingTypeString SettingType = "string"
+
+	// SettingTypeBool is a boolean setting, e.g. true
+	SettingTypeBool SettingType = "bool"
+
+	// SettingTypeStringSlice is a list of strings, separated by commas
+	// e.g. kind,loft
+	SettingTypeStringSlice SettingType = "[]string"
+)
+
+// SettingSource is where the value of a setting came from
+type SettingSource string
+
+const (
+	// SettingSourceEnv is a value set by a DEVENV_* environment variable
+	SettingSourceEnv SettingSource = "env"
+
+	// SettingSourceConfig is a value set in the devenv config
+	SettingSourceConfig SettingSource = "config"
+
+	// SettingSourceBox is a value from the box configuration
+	SettingSourceBox SettingSource = "box"
+
+	// SettingSourceDefault is an unset value
+	SettingSourceDefault SettingSource = "default"
+)
+
+// Setting is a typed key that can be read and written through
+// devenv config. Values are represented as strings, with an empty
+// string denoting that the setting isn't set.
+type Setting struct {
+	// Key is the name of the setting, e.g. box.enabledRuntimes
+	Key string
+
+	// Type is the type of the value of this setting
+	Type SettingType
+
+	// Description is a human readable description of this setting
+	Description string
+
+	// validate validates an already type checked value, optional. See
+	// Validate for runtimeNames.
+	validate func(value string, runtimeNames []string) error
+
+	// get returns the value of this setting in a config
+	get func(*Config) string
+
+	// set sets the value of this setting in a config
+	set func(*Config, string)
+
+	// getBox returns the value of this setting in a box configuration,
+	// only set for box overrides
+	getBox func(*box.Config) string
+}
+
+// EnvVar returns the environment variable that overrides this setting,
+// e.g. box.enabledRuntimes is DEVENV_BOX_ENABLED_RUNTIMES
+func (s *Setting) EnvVar() string {
+	var sb strings.Builder
+	sb.WriteString("DEVENV_")
+
+	var prev rune
+	for _, r := range s.Key {
+		switch {
+		case r == '.':
+			sb.WriteRune('_')
+		case unicode.IsUpper(r) && unicode.IsLower(prev):
+			sb.WriteRune('_')
+			sb.WriteRune(r)
+		default:
+			sb.WriteRune(unicode.ToUpper(r))
+		}
+		prev = r
+	}
+
+	return sb.String()
+}
+
+// Validate ensures that value is a valid value for this setting, an
+// empty value is always valid and unsets the setting. runtimeNames are
+// the names of all kubernetes runtimes, see
+// kubernetesruntime.GetRuntimeNames, if nil runtime names aren't
+// validated.
+func (s *Setting) Validate(value string, runtimeNames []string) error {
+	if value == "" {
+		return nil
+	}
+
+	if s.Type == SettingTypeBool {
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("invalid value '%s' for %s, expected a boolean", value, s.Key)
+		}
+	}
+
+	if s.validate != nil {
+		return errors.Wrapf(s.validate(value, runtimeNames), "invalid value '%s' for %s", value, s.Key)
+	}
+
+	return nil
+}
+
+// Get returns the value of this setting in a config
+func (s *Setting) Get(c *Config) string {
+	return s.get(c)
+}
+
+// Set validates and sets the value of this setting in a config, see
+// Validate for runtimeNames
+func (s *Setting) Set(c *Config, value string, runtimeNames []string) error {
+	if err := s.Validate(value, runtimeNames); err != nil {
+		return err
+	}
+
+	s.set(c, value)
+	return nil
+}
+
+// Unset removes the value of this setting from a config
+func (s *Setting) Unset(c *Config) {
+	s.set(c, "")
+}
+
+// Resolve returns the effective value of this setting and where it came
+// from. Environment variables take precedence over the devenv config,
+// which takes precedence over the box configuration. The box may be nil.
+func (s *Setting) Resolve(c *Config, b *box.Config) (string, SettingSource) {
+	if v := os.Getenv(s.EnvVar()); v != "" {
+		return v, SettingSourceEnv
+	}
+
+	if v := s.get(c); v != "" {
+		return v, SettingSourceConfig
+	}
+
+	if s.getBox != nil && b != nil && b.DeveloperEnvironmentConfig != nil {
+		if v := s.getBox(b); v != "" {
+			return v, SettingSourceBox
+		}
+	}
+
+	return "", SettingSourceDefault
+}
+
+// GetSetting returns a setting by key, if not found nil is returned
+func GetSetting(key string) *Setting {
+	for _, s := range Settings() {
+		if s.Key == key {
+			return s
+		}
+	}
+
+	return nil
+}
+
+// splitList splits a comma separated list, ignoring empty elements
+func splitList(value string) []string {
+	list := make([]string, 0)
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// validateRuntimeName ensures that name is one of the names of all
+// runtimes, if names is nil any name is valid
+func validateRuntimeName(name string, names []string) error {
+	if names == nil {
+		return nil
+	}
+
+	for _, n := range names {
+		if n == name {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown runtime '%s', available runtimes: %s", name, strings.Join(names, ", "))
+}
+
+// validateURL ensures that a value is an absolute URL
+func validateURL(value string, _ []string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("expected an absolute URL, e.g. https://example.com")
+	}
+
+	return nil
+}
+
+// Settings returns all settings that can be changed with devenv config
+func Settings() []*Setting { //nolint:funlen // Why: This is a static list
+	return []*Setting{
+		{
+			Key:         "currentContext",
+			Type:        SettingTypeString,
+			Description: "The devenv in use, in the format runtime:name",
+			validate: func(v string, runtimeNames []string) error {
+				runtime, name := (&Config{CurrentContext: v}).ParseContext()
+				if runtime == "" || name == "" {
+					return fmt.Errorf("expected a context in the format runtime:name")
+				}
+				return validateRuntimeName(runtime, runtimeNames)
+			},
+			get: func(c *Config) string { return c.CurrentContext },
+			set: func(c *Config, v string) { c.CurrentContext = v },
+		},
+		{
+			Key:         "kubernetesVersion",
+			Type:        SettingTypeString,
+			Description: "The version of Kubernetes to create clusters with",
+			get:         func(c *Config) string { return c.KubernetesVersion },
+			set:         func(c *Config, v string) { c.KubernetesVersion = v },
+		},
+		{
+			Key:         "box.enabledRuntimes",
+			Type:        SettingTypeStringSlice,
+			Description: "The kubernetes runtimes that are enabled",
+			validate: func(v string, runtimeNames []string) error {
+				for _, name := range splitList(v) {
+					if err := validateRuntimeName(name, runtimeNames); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			get: func(c *Config) string { return strings.Join(c.BoxOverrides.EnabledRuntimes, ",") },
+			set: func(c *Config, v string) {
+				c.BoxOverrides.EnabledRuntimes = nil
+				if v != "" {
+					c.BoxOverrides.EnabledRuntimes = splitList(v)
+				}
+			},
+			getBox: func(b *box.Config) string {
+				if b.DeveloperEnvironmentConfig.RuntimeConfig == nil {
+					return ""
+				}
+				return strings.Join(b.DeveloperEnvironmentConfig.RuntimeConfig.EnabledRuntimes, ",")
+			},
+		},
+		{
+			Key:         "box.imageRegistry",
+			Type:        SettingTypeString,
+			Description: "The registry to pull application images from, e.g. gcr.io/outreach-docker",
+			get:         func(c *Config) string { return c.BoxOverrides.ImageRegistry },
+			set:         func(c *Config, v string) { c.BoxOverrides.ImageRegistry = v },
+			getBox:      func(b *box.Config) string { return b.DeveloperEnvironmentConfig.ImageRegistry },
+		},
+		{
+			Key:         "box.loft.url",
+			Type:        SettingTypeString,
+			Description: "The URL of the loft instance used by the loft runtime",
+			validate:    validateURL,
+			get:         func(c *Config) string { return c.BoxOverrides.LoftURL },
+			set:         func(c *Config, v string) { c.BoxOverrides.LoftURL = v },
+			getBox: func(b *box.Config) string {
+				if b.DeveloperEnvironmentConfig.RuntimeConfig == nil || b.DeveloperEnvironmentConfig.RuntimeConfig.Loft == nil {
+					return ""
+				}
+				return b.DeveloperEnvironmentConfig.RuntimeConfig.Loft.URL
+			},
+		},
+		{
+			Key:         "box.vault.enabled",
+			Type:        SettingTypeBool,
+			Description: "Whether to use vault for secrets",
+			get: func(c *Config) string {
+				if c.BoxOverrides.VaultEnabled == nil {
+					return ""
+				}
+				return strconv.FormatBool(*c.BoxOverrides.VaultEnabled)
+			},
+			set: func(c *Config, v string) {
+				c.BoxOverrides.VaultEnabled = nil
+				if enabled, err := strconv.ParseBool(v); err == nil {
+					c.BoxOverrides.VaultEnabled = &enabled
+				}
+			},
+			getBox: func(b *box.Config) string {
+				if b.DeveloperEnvironmentConfig.VaultConfig == nil {
+					return ""
+				}
+				return strconv.FormatBool(b.DeveloperEnvironmentConfig.VaultConfig.Enabled)
+			},
+		},
+		{
+			Key:         "box.vault.address",
+			Type:        SettingTypeString,
+			Description: "The address of vault",
+			validate:    validateURL,
+			get:         func(c *Config) string { return c.BoxOverrides.VaultAddress },
+			set:         func(c *Config, v string) { c.BoxOverrides.VaultAddress = v },
+			getBox: func(b *box.Config) string {
+				if b.DeveloperEnvironmentConfig.VaultConfig == nil {
+					return ""
+				}
+				return b.DeveloperEnvironmentConfig.VaultConfig.Address
+			},
+		},
+		{
+			Key:         "box.snapshots.defaultName",
+			Type:        SettingTypeString,
+			Description: "The snapshot target to provision from by default, e.g. flagship",
+			get:         func(c *Config) string { return c.BoxOverrides.DefaultSnapshot },
+			set:         func(c *Config, v string) { c.BoxOverrides.DefaultSnapshot = v },
+			getBox: func(b *box.Config) string {
+				if b.DeveloperEnvironmentConfig.SnapshotConfig == nil {
+					return ""
+				}
+				return b.DeveloperEnvironmentConfig.SnapshotConfig.DefaultName
+			},
+		},
+	}
+}
+
+// applyEnvOverrides sets the value of all settings that have been
+// overridden by an environment variable. Runtime names aren't validated,
+// as the runtimes aren't known here.
+func (c *Config) applyEnvOverrides() error {
+	for _, s := range Settings() {
+		v := os.Getenv(s.EnvVar())
+		if v == "" {
+			continue
+		}
+
+		if err := s.Set(c, v, nil); err != nil {
+			return errors.Wrapf(err, "failed to apply %s", s.EnvVar())
+		}
+	}
+
+	return nil
+}
+
+// ApplyBoxOverrides sets the values of a box configuration that have
+// been overridden in this config
+func (c *Config) ApplyBoxOverrides(b *box.Config) {
+	if b.DeveloperEnvironmentConfig == nil {
+		b.DeveloperEnvironmentConfig = &box.DeveloperEnvironmentConfig{}
+	}
+	dc := b.DeveloperEnvironmentConfig
+
+	if dc.RuntimeConfig == nil {
+		dc.RuntimeConfig = &box.DeveloperEnvironmentRuntimeConfig{}
+	}
+	if dc.RuntimeConfig.Loft == nil {
+		dc.RuntimeConfig.Loft = &box.LoftRuntimeConfig{}
+	}
+	if dc.VaultConfig == nil {
+		dc.VaultConfig = &box.VaultConfig{}
+	}
+	if dc.SnapshotConfig == nil {
+		dc.SnapshotConfig = &box.SnapshotConfig{}
+	}
+
+	o := &c.BoxOverrides
+	if len(o.EnabledRuntimes) != 0 {
+		dc.RuntimeConfig.EnabledRuntimes = o.EnabledRuntimes
+	}
+	if o.ImageRegistry != "" {
+		dc.ImageRegistry = o.ImageRegistry
+	}
+	if o.LoftURL != "" {
+		dc.RuntimeConfig.Loft.URL = o.LoftURL
+	}
+	if o.VaultEnabled != nil {
+		dc.VaultConfig.Enabled = *o.VaultEnabled
+	}
+	if o.VaultAddress != "" {
+		dc.VaultConfig.Address = o.VaultAddress
+	}
+	if o.DefaultSnapshot != "" {
+		dc.SnapshotConfig.DefaultName = o.DefaultSnapshot
+	}
+}
+
+// LoadBox loads the box configuration with the overrides from the
+// devenv config, and DEVENV_* environment variables, applied. This
+// should be used over box.LoadBox.
+func LoadBox() (*box.Config, error) {
+	b, err := box.LoadBox()
+	if err != nil {
+		return nil, err
+	}
+
+	conf, err := LoadConfig(context.Background())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load devenv config")
+	}
+	conf.ApplyBoxOverrides(b)
+
+	return b, nil
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestSetting_EnvVar(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{key: "currentContext", want: "DEVENV_CURRENT_CONTEXT"},
+		{key: "box.enabledRuntimes", want: "DEVENV_BOX_ENABLED_RUNTIMES"},
+		{key: "box.loft.url", want: "DEVENV_BOX_LOFT_URL"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.key, func(t *testing.T) {
+			s := &Setting{Key: tt.key}
+			if got := s.EnvVar(); got != tt.want {
+				t.Errorf("Setting.EnvVar() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetting_Set(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "should set known runtimes",
+			key:   "box.enabledRuntimes",
+			value: "kind, loft",
+			want:  "kind,loft",
+		},
+		{
+			name:    "should reject unknown runtimes",
+			key:     "box.enabledRuntimes",
+			value:   "kind,minikube",
+			wantErr: true,
+		},
+		{
+			name:    "should reject invalid booleans",
+			key:     "box.vault.enabled",
+			value:   "yes please",
+			wantErr: true,
+		},
+		{
+			name:  "should set booleans",
+			key:   "box.vault.enabled",
+			value: "false",
+			want:  "false",
+		},
+		{
+			name:    "should reject relative URLs",
+			key:     "box.loft.url",
+			value:   "loft.example.com",
+			wantErr: true,
+		},
+		{
+			name:    "should reject contexts without a runtime",
+			key:     "currentContext",
+			value:   "dev-environment",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s := GetSetting(tt.key)
+			if s == nil {
+				t.Fatalf("GetSetting(%q) = nil", tt.key)
+			}
+
+			c := NewConfig()
+			if err := s.Set(c, tt.value, []string{"kind", "loft"}); (err != nil) != tt.wantErr {
+				t.Fatalf("Setting.Set() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got := s.Get(c); !tt.wantErr && got != tt.want {
+				t.Errorf("Setting.Get() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,14 @@
 package containerruntime
 
 const (
+	// ContainerName is the name of the control-plane container of
+	// the default cluster.
 	ContainerName    = "dev-environment-control-plane"
 	ContainerNetwork = "kind"
 )
+
+// GetContainerName returns the name of the control-plane container
+// of a given cluster
+func GetContainerName(clusterName string) string {
+	return clusterName + "-control-plane"
+}
@@ -15,12 +15,12 @@ import (
 	olog "github.com/getoutreach/gobox/pkg/log"
 )
 
-// RemoveImage deletes an image from the containerruntime
-func RemoveImage(ctx context.Context, image string) error {
+// RemoveImage deletes an image from the containerruntime of a given cluster
+func RemoveImage(ctx context.Context, clusterName, image string) error {
 	ctx = trace.StartCall(ctx, "containerruntime.RemoveImage", olog.F{"image": image})
 	defer trace.EndCall(ctx)
 
-	if !HasImage(ctx, image) {
+	if !HasImage(ctx, clusterName, image) {
 		return nil
 	}
 
@@ -30,7 +30,7 @@ func RemoveImage(ctx context.Context, image string) error {
 		false,
 		"docker",
 		"exec",
-		ContainerName,
+		GetContainerName(clusterName),
 		"ctr",
 		"--namespace",
 		"k8s.io",
@@ -41,15 +41,16 @@ func RemoveImage(ctx context.Context, image string) error {
 	return trace.SetCallStatus(ctx, err)
 }
 
-// HasImage checks to see if the containerruntime has the given image in its cache
-func HasImage(ctx context.Context, image string) bool {
+// HasImage checks to see if the containerruntime of a given cluster has the given
+// image in its cache
+func HasImage(ctx context.Context, clusterName, image string) bool {
 	ctx = trace.StartCall(ctx, "containerruntime.HasImage", olog.F{"image": image})
 	defer trace.EndCall(ctx)
 
 	//nolint:gosec // Why: We need to pass args.
 	cmd := exec.CommandContext(ctx, "docker",
 		"exec",
-		ContainerName,
+		GetContainerName(clusterName),
 		"ctr", "--namespace", "k8s.io", "images", "list", "-q",
 		fmt.Sprintf("name==%s", image),
 	)
@@ -66,8 +67,8 @@ func HasImage(ctx context.Context, image string) bool {
 	return false
 }
 
-// PullImage fetches an image inside for our containerruntime to use.
-func PullImage(ctx context.Context, image string) error {
+// PullImage fetches an image inside for the containerruntime of a given cluster to use.
+func PullImage(ctx context.Context, clusterName, image string) error {
 	ctx = trace.StartCall(ctx, "containerruntime.PullImage", olog.F{"image": image})
 	defer trace.EndCall(ctx)
 
@@ -89,7 +90,7 @@ func PullImage(ctx context.Context, image string) error {
 		false,
 		"docker",
 		"exec",
-		ContainerName,
+		GetContainerName(clusterName),
 		"ctr",
 		"--namespace",
 		"k8s.io",
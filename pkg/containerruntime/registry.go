@@ -0,0 +1,97 @@
+package containerruntime
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+
+	"github.com/getoutreach/gobox/pkg/trace"
+	"github.com/pkg/errors"
+)
+
+const (
+	// RegistryContainerName is the name of the local registry container
+	// that is shared by all clusters using it.
+	RegistryContainerName = "devenv-registry"
+
+	// RegistryImage is the image used for the local registry
+	RegistryImage = "registry:2"
+
+	// DefaultRegistryPort is the port the local registry is exposed on
+	// the host by default. Port 5000 is used by the AirPlay receiver on
+	// macOS.
+	DefaultRegistryPort = 5001
+
+	// registryPort is the port the registry listens on inside of its
+	// container
+	registryPort = "5000"
+
+	// RegistryClusterAddress is the address of the local registry from
+	// inside of ContainerNetwork
+	RegistryClusterAddress = RegistryContainerName + ":" + registryPort
+)
+
+// EnsureRegistry ensures that the local registry container exists
+// and is running, and returns its address from the host. A registry
+// that doesn't exist yet is exposed on the given port of the host,
+// an existing registry keeps the port it was created with.
+func EnsureRegistry(ctx context.Context, port int) (string, error) {
+	ctx = trace.StartCall(ctx, "containerruntime.EnsureRegistry")
+	defer trace.EndCall(ctx)
+
+	//nolint:gosec // Why: We're passing constants
+	b, err := exec.CommandContext(ctx, "docker", "inspect", "-f", "{{.State.Running}}", RegistryContainerName).Output()
+	if err != nil {
+		//nolint:gosec // Why: We're passing constants
+		b, err = exec.CommandContext(ctx, "docker", "run", "-d", "--restart=always",
+			"-p", fmt.Sprintf("127.0.0.1:%d:%s", port, registryPort), "--name", RegistryContainerName, RegistryImage).CombinedOutput()
+		if err != nil {
+			return "", trace.SetCallStatus(ctx, errors.Wrapf(err, "failed to create local registry: %s", b))
+		}
+	} else if strings.TrimSpace(string(b)) != "true" {
+		//nolint:gosec // Why: We're passing constants
+		b, err = exec.CommandContext(ctx, "docker", "start", RegistryContainerName).CombinedOutput()
+		if err != nil {
+			return "", trace.SetCallStatus(ctx, errors.Wrapf(err, "failed to start local registry: %s", b))
+		}
+	}
+
+	addr, err := registryAddress(ctx)
+	return addr, trace.SetCallStatus(ctx, err)
+}
+
+// registryAddress returns the address of the local registry from the
+// host, based on the port it's exposed on
+func registryAddress(ctx context.Context) (string, error) {
+	//nolint:gosec // Why: We're passing constants
+	b, err := exec.CommandContext(ctx, "docker", "port", RegistryContainerName, registryPort+"/tcp").Output()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get port of local registry")
+	}
+
+	// e.g. 127.0.0.1:5001, one line per address it's bound to
+	binding := strings.SplitN(strings.TrimSpace(string(b)), "\n", 2)[0]
+	_, port, err := net.SplitHostPort(binding)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse port of local registry '%s'", binding)
+	}
+
+	return "localhost:" + port, nil
+}
+
+// ConnectRegistry attaches the local registry to ContainerNetwork, which
+// allows clusters to pull from it via RegistryClusterAddress.
+func ConnectRegistry(ctx context.Context) error {
+	ctx = trace.StartCall(ctx, "containerruntime.ConnectRegistry")
+	defer trace.EndCall(ctx)
+
+	//nolint:gosec // Why: We're passing constants
+	b, err := exec.CommandContext(ctx, "docker", "network", "connect", ContainerNetwork, RegistryContainerName).CombinedOutput()
+	if err != nil && !strings.Contains(string(b), "already exists") {
+		return trace.SetCallStatus(ctx, errors.Wrapf(err, "failed to connect local registry to network: %s", b))
+	}
+
+	return nil
+}
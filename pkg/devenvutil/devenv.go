@@ -16,6 +16,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -89,24 +90,28 @@ type DeleteObjectsObjects struct {
 	Validator  func(obj *unstructured.Unstructured) (filter bool)
 }
 
-func DeleteObjects(ctx context.Context, log logrus.FieldLogger, k kubernetes.Interface, conf *rest.Config, opts DeleteObjectsObjects) error { //nolint:funlen
+// findObjects returns the objects matching opts, along with their REST
+// mapping and a dynamic client to modify them
+func findObjects(ctx context.Context, k kubernetes.Interface, conf *rest.Config,
+	opts DeleteObjectsObjects) (*meta.RESTMapping, dynamic.Interface, []interface{}, error) {
 	traceCtx := trace.StartCall(ctx, "kubernetes.GetPods")
+	defer trace.EndCall(traceCtx)
 
 	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(k.Discovery()))
 
 	if opts.Type == nil {
-		return fmt.Errorf("missing Type")
+		return nil, nil, nil, fmt.Errorf("missing Type")
 	}
 
 	gvk := opts.Type.GetObjectKind().GroupVersionKind()
 	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
 	if err != nil {
-		return err
+		return nil, nil, nil, err
 	}
 
 	dyn, err := dynamic.NewForConfig(conf)
 	if err != nil {
-		return err
+		return nil, nil, nil, err
 	}
 
 	dr := dyn.Resource(mapping.Resource)
@@ -119,7 +124,7 @@ func DeleteObjects(ctx context.Context, log logrus.FieldLogger, k kubernetes.Int
 			Continue: cursor,
 		})
 		if trace.SetCallStatus(traceCtx, err) != nil {
-			return errors.Wrap(err, "failed to get pods")
+			return nil, nil, nil, errors.Wrap(err, "failed to get pods")
 		}
 
 		for i := range items.Items {
@@ -150,6 +155,35 @@ func DeleteObjects(ctx context.Context, log logrus.FieldLogger, k kubernetes.Int
 		}
 	}
 
+	return mapping, dyn, objs, nil
+}
+
+// FindObjects returns the keys, namespace/name, of the objects that
+// DeleteObjects would delete
+func FindObjects(ctx context.Context, k kubernetes.Interface, conf *rest.Config, opts DeleteObjectsObjects) ([]string, error) {
+	_, _, objs, err := findObjects(ctx, k, conf, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, len(objs))
+	for i, obj := range objs {
+		unstruct := obj.(unstructured.Unstructured)
+		keys[i] = fmt.Sprintf("%s/%s", unstruct.GetNamespace(), unstruct.GetName())
+	}
+
+	return keys, nil
+}
+
+func DeleteObjects(ctx context.Context, log logrus.FieldLogger, k kubernetes.Interface, conf *rest.Config, opts DeleteObjectsObjects) error {
+	mapping, dyn, objs, err := findObjects(ctx, k, conf, opts)
+	if err != nil {
+		return err
+	}
+
+	traceCtx := trace.StartCall(ctx, "kubernetes.DeleteObjects")
+	defer trace.EndCall(traceCtx)
+
 	_, err = worker.ProcessArray(traceCtx, objs, func(ctx context.Context, obj interface{}) (interface{}, error) {
 		unstruct := obj.(unstructured.Unstructured)
 
@@ -161,6 +195,24 @@ func DeleteObjects(ctx context.Context, log logrus.FieldLogger, k kubernetes.Int
 	return err
 }
 
+// IsPodReady returns true if a pod has completed or its ready checks
+// passed
+func IsPodReady(po *corev1.Pod) bool {
+	// Completed pods are never going to be ready
+	if po.Status.Phase == corev1.PodSucceeded {
+		return true
+	}
+
+	for i := range po.Status.Conditions {
+		cond := &po.Status.Conditions[i]
+		if cond.Type == corev1.PodReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+
+	return false
+}
+
 // FindUnreadyPods checks all namespaces to find pods that are unready, they are
 // then returned. If an error occurs, err is returned.
 func FindUnreadyPods(ctx context.Context, k kubernetes.Interface) ([]string, error) {
@@ -172,12 +224,6 @@ func FindUnreadyPods(ctx context.Context, k kubernetes.Interface) ([]string, err
 	unreadyPods := []string{}
 	for i := range pods.Items {
 		po := &pods.Items[i]
-		ready := false
-
-		// Skip completed pods
-		if po.Status.Phase == corev1.PodSucceeded {
-			continue
-		}
 
 		// Special case for strimzi which is broken currently.
 		// TODO(jaredallard): Need to figure out what to do here long term.
@@ -185,19 +231,8 @@ func FindUnreadyPods(ctx context.Context, k kubernetes.Interface) ([]string, err
 			continue
 		}
 
-		// Check that a pod is ready (e.g. the ready checks passed)
-		for ii := range po.Status.Conditions {
-			cond := &po.Status.Conditions[ii]
-			if cond.Type == corev1.PodReady { // Ready
-				if cond.Status == corev1.ConditionTrue { // True
-					ready = true
-					break
-				}
-			}
-		}
-
 		// if ready, skip it
-		if ready {
+		if IsPodReady(po) {
 			continue
 		}
 
@@ -0,0 +1,63 @@
+// Package filelock implements exclusive advisory file locks, used to
+// coordinate devenv processes that run at the same time
+package filelock
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// open opens, and creates if needed, the lock file at path
+func open(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, errors.Wrap(err, "failed to create lock file dir")
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	return f, errors.Wrap(err, "failed to open lock file")
+}
+
+// Lock takes an exclusive lock on the file at path, blocking until it's
+// available. The file, and its parent directories, are created if they
+// don't exist. The returned function releases the lock.
+func Lock(path string) (unlock func(), err error) {
+	f, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+		if err != syscall.EINTR {
+			break
+		}
+	}
+	if err != nil {
+		f.Close() //nolint:errcheck // Why: Already failed
+		return nil, errors.Wrapf(err, "failed to lock %s", path)
+	}
+
+	return func() { f.Close() }, nil //nolint:errcheck // Why: Closing releases the lock
+}
+
+// TryLock takes an exclusive lock on the file at path if it's available,
+// ok is false if it's held by someone else. See Lock.
+func TryLock(path string) (unlock func(), ok bool, err error) {
+	f, err := open(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil { //nolint:govet // Why: We're OK shadowing err
+		f.Close() //nolint:errcheck // Why: Not locked
+		if err == syscall.EWOULDBLOCK {
+			return nil, false, nil
+		}
+		return nil, false, errors.Wrapf(err, "failed to lock %s", path)
+	}
+
+	return func() { f.Close() }, true, nil //nolint:errcheck // Why: Closing releases the lock
+}
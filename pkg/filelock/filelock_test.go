@@ -0,0 +1,27 @@
+package filelock
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestTryLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dir", ".lock")
+
+	unlock, err := Lock(path)
+	if err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+
+	if _, ok, err := TryLock(path); err != nil || ok {
+		t.Fatalf("TryLock() = %v, %v, want it to fail while locked", ok, err)
+	}
+
+	unlock()
+
+	unlock, ok, err := TryLock(path)
+	if err != nil || !ok {
+		t.Fatalf("TryLock() = %v, %v, want it to succeed once unlocked", ok, err)
+	}
+	unlock()
+}
@@ -0,0 +1,331 @@
+package kubernetesruntime
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/getoutreach/devenv/cmd/devenv/status"
+	"github.com/getoutreach/devenv/pkg/appregistry"
+	"github.com/getoutreach/devenv/pkg/config"
+	"github.com/getoutreach/gobox/pkg/box"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ExistingRuntime is a runtime that adopts an already existing
+// cluster from a kubeconfig context, e.g. a shared CI cluster or
+// Docker Desktop, instead of creating one.
+type ExistingRuntime struct {
+	log logrus.FieldLogger
+
+	// cluster is the adopted cluster this runtime is currently
+	// operating on
+	cluster   *config.AdoptedCluster
+	clusterMu sync.Mutex
+}
+
+// NewExistingRuntime creates a new existing runtime
+func NewExistingRuntime() *ExistingRuntime {
+	return &ExistingRuntime{
+		log: logrus.New(),
+	}
+}
+
+// SetTarget sets the kubeconfig and context that should be adopted
+// by Create. If kubeConfigPath is empty the default kubeconfig loading
+// rules are used.
+func (er *ExistingRuntime) SetTarget(kubeConfigPath, contextName string) {
+	er.clusterMu.Lock()
+	defer er.clusterMu.Unlock()
+
+	er.cluster = &config.AdoptedCluster{
+		Name:           sanitizeContextName(contextName),
+		KubeConfigPath: kubeConfigPath,
+		Context:        contextName,
+	}
+}
+
+// sanitizeContextName converts a kubeconfig context name into
+// a name that is valid to be used as part of a devenv context
+func sanitizeContextName(contextName string) string {
+	return strings.NewReplacer(":", "-", "/", "-", "@", "-").Replace(contextName)
+}
+
+func (*ExistingRuntime) PreCreate(ctx context.Context) error {
+	return nil
+}
+
+func (er *ExistingRuntime) Configure(log logrus.FieldLogger, _ *box.Config) {
+	er.log = log
+
+	er.clusterMu.Lock()
+	defer er.clusterMu.Unlock()
+
+	// If we weren't given a target, use the cluster of the current context
+	if er.cluster != nil {
+		return
+	}
+
+	conf, err := config.LoadConfig(context.TODO())
+	if err != nil {
+		return
+	}
+
+	if runtime, name := conf.ParseContext(); runtime == er.GetConfig().Name {
+		er.cluster = conf.GetAdoptedCluster(name)
+	}
+}
+
+func (er *ExistingRuntime) GetConfig() RuntimeConfig {
+	clusterName := ""
+	if er.cluster != nil {
+		clusterName = er.cluster.Name
+	}
+
+	return RuntimeConfig{
+		Name:        "existing",
+		Type:        RuntimeTypeRemote,
+		ClusterName: clusterName,
+		// Adopted clusters are shared with whatever else runs in them, so
+		// we don't restore snapshots into them or manage their lifecycle.
+		Capabilities: RuntimeCapabilities{},
+	}
+}
+
+// loadKubeConfig loads the kubeconfig of an adopted cluster and returns
+// a flattened kubeconfig that only contains it's context
+func (er *ExistingRuntime) loadKubeConfig(ac *config.AdoptedCluster) (*api.Config, error) {
+	lr := clientcmd.NewDefaultClientConfigLoadingRules()
+	lr.ExplicitPath = ac.KubeConfigPath
+
+	kubeconfig, err := lr.Load()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load kubeconfig")
+	}
+
+	if _, ok := kubeconfig.Contexts[ac.Context]; !ok {
+		return nil, fmt.Errorf("context '%s' not found in kubeconfig", ac.Context)
+	}
+	kubeconfig.CurrentContext = ac.Context
+
+	if err := api.MinifyConfig(kubeconfig); err != nil { //nolint:govet // Why: We're OK shadowing err
+		return nil, errors.Wrap(err, "failed to minify kubeconfig")
+	}
+
+	// Embed any referenced certificates, the kubeconfig is written elsewhere
+	if err := api.FlattenConfig(kubeconfig); err != nil { //nolint:govet // Why: We're OK shadowing err
+		return nil, errors.Wrap(err, "failed to flatten kubeconfig")
+	}
+
+	c := kubeconfig.Contexts[ac.Context]
+	delete(kubeconfig.Contexts, ac.Context)
+	kubeconfig.Contexts[ac.Name] = c
+
+	// Compat with tools that want this context.
+	kubeconfig.Contexts["dev-environment"] = c
+	kubeconfig.CurrentContext = "dev-environment"
+
+	return kubeconfig, nil
+}
+
+// getClient creates a kubernetes client for an adopted cluster
+func (er *ExistingRuntime) getClient(ac *config.AdoptedCluster) (kubernetes.Interface, error) {
+	kubeconfig, err := er.loadKubeConfig(ac)
+	if err != nil {
+		return nil, err
+	}
+
+	rconf, err := clientcmd.NewDefaultClientConfig(*kubeconfig, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create rest config for context")
+	}
+
+	return kubernetes.NewForConfig(rconf)
+}
+
+// Status gets the status of the adopted cluster
+func (er *ExistingRuntime) Status(ctx context.Context) RuntimeStatus {
+	resp := RuntimeStatus{status.Status{
+		Status: status.Unprovisioned,
+	}}
+
+	if er.cluster == nil {
+		return resp
+	}
+
+	k, err := er.getClient(er.cluster)
+	if err != nil {
+		resp.Status.Status = status.Unknown
+		resp.Reason = err.Error()
+		return resp
+	}
+
+	v, err := k.Discovery().ServerVersion()
+	if err != nil {
+		resp.Status.Status = status.Degraded
+		resp.Reason = errors.Wrap(err, "failed to reach kubernetes").Error()
+		return resp
+	}
+
+	resp.Status.Status = status.Running
+	resp.KubernetesVersion = v.String()
+
+	return resp
+}
+
+// Create validates that we can access the targeted cluster and then
+// adopts it. No cluster is created.
+func (er *ExistingRuntime) Create(ctx context.Context) error {
+	if er.cluster == nil || er.cluster.Context == "" {
+		return fmt.Errorf("no kubeconfig context was provided to adopt")
+	}
+
+	k, err := er.getClient(er.cluster)
+	if err != nil {
+		return err
+	}
+
+	if _, err := k.Discovery().ServerVersion(); err != nil { //nolint:govet // Why: We're OK shadowing err
+		return errors.Wrapf(err, "failed to reach kubernetes cluster for context '%s'", er.cluster.Context)
+	}
+
+	namespaces, err := k.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to list namespaces, do you have access to this cluster?")
+	}
+
+	er.cluster.PreexistingNamespaces = make([]string, len(namespaces.Items))
+	for i := range namespaces.Items {
+		er.cluster.PreexistingNamespaces[i] = namespaces.Items[i].Name
+	}
+
+	return config.UpdateConfig(ctx, func(conf *config.Config) error {
+		if conf.GetAdoptedCluster(er.cluster.Name) != nil {
+			return fmt.Errorf("context '%s' has already been adopted", er.cluster.Context)
+		}
+		conf.AdoptedClusters = append(conf.AdoptedClusters, er.cluster)
+		return nil
+	})
+}
+
+// Destroy removes the namespaces devenv created in the adopted cluster,
+// see appregistry.ManagedNamespaceLabel, and then forgets about the
+// cluster. The cluster itself is left intact.
+func (er *ExistingRuntime) Destroy(ctx context.Context) error {
+	if er.cluster == nil {
+		return fmt.Errorf("no adopted cluster found")
+	}
+
+	k, err := er.getClient(er.cluster)
+	if err != nil {
+		return err
+	}
+
+	if err := deleteManagedNamespaces(ctx, er.log, k, er.cluster.PreexistingNamespaces); err != nil { //nolint:govet // Why: We're OK shadowing err
+		return err
+	}
+
+	err = config.UpdateConfig(ctx, func(conf *config.Config) error {
+		adopted := make([]*config.AdoptedCluster, 0, len(conf.AdoptedClusters))
+		for _, ac := range conf.AdoptedClusters {
+			if ac.Name != er.cluster.Name {
+				adopted = append(adopted, ac)
+			}
+		}
+		conf.AdoptedClusters = adopted
+		return nil
+	})
+	return errors.Wrap(err, "failed to save devenv config")
+}
+
+// deleteManagedNamespaces deletes the namespaces devenv created, see
+// appregistry.ManagedNamespaceLabel. Namespaces that existed when the
+// cluster was adopted, and system namespaces, are never deleted.
+func deleteManagedNamespaces(ctx context.Context, log logrus.FieldLogger, k kubernetes.Interface, preexisting []string) error {
+	skip := make(map[string]bool)
+	for _, ns := range preexisting {
+		skip[ns] = true
+	}
+
+	namespaces, err := k.CoreV1().Namespaces().List(ctx, metav1.ListOptions{
+		LabelSelector: appregistry.ManagedNamespaceSelector,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to list namespaces")
+	}
+
+	for i := range namespaces.Items {
+		ns := namespaces.Items[i].Name
+		if skip[ns] || strings.HasPrefix(ns, "kube-") || ns == metav1.NamespaceDefault {
+			continue
+		}
+
+		log.WithField("namespace", ns).Info("Removing devenv managed namespace")
+		err := k.CoreV1().Namespaces().Delete(ctx, ns, metav1.DeleteOptions{}) //nolint:govet // Why: We're OK shadowing err
+		if err != nil && !kerrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete namespace '%s'", ns)
+		}
+	}
+
+	return nil
+}
+
+// Start is not supported, devenv doesn't manage the lifecycle
+// of adopted clusters
+func (er *ExistingRuntime) Start(ctx context.Context) error {
+	return errors.Wrap(ErrNotSupported, "adopted clusters can't be started by devenv")
+}
+
+// Stop is not supported, devenv doesn't manage the lifecycle
+// of adopted clusters
+func (er *ExistingRuntime) Stop(ctx context.Context) error {
+	return errors.Wrap(ErrNotSupported, "adopted clusters can't be stopped by devenv")
+}
+
+// LoadImage is not supported, adopted clusters pull images from
+// a registry
+func (er *ExistingRuntime) LoadImage(ctx context.Context, image string) error {
+	return errors.Wrap(ErrNotSupported, "images can't be loaded into adopted clusters")
+}
+
+// GetKubeConfig returns the kubeconfig of the adopted cluster
+func (er *ExistingRuntime) GetKubeConfig(ctx context.Context) (*api.Config, error) {
+	if er.cluster == nil {
+		return nil, fmt.Errorf("no adopted cluster found")
+	}
+
+	return er.loadKubeConfig(er.cluster)
+}
+
+// GetClusters returns all clusters that have been adopted
+func (er *ExistingRuntime) GetClusters(ctx context.Context) ([]*RuntimeCluster, error) {
+	conf, err := config.LoadConfig(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load devenv config")
+	}
+
+	rclusters := make([]*RuntimeCluster, 0, len(conf.AdoptedClusters))
+	for _, ac := range conf.AdoptedClusters {
+		kubeconfig, err := er.loadKubeConfig(ac)
+		if err != nil {
+			er.log.WithError(err).WithField("context", ac.Context).Warn("Failed to load adopted cluster, skipping")
+			continue
+		}
+
+		rclusters = append(rclusters, &RuntimeCluster{
+			RuntimeName: er.GetConfig().Name,
+			Name:        ac.Name,
+			KubeConfig:  kubeconfig,
+		})
+	}
+
+	return rclusters, nil
+}
@@ -0,0 +1,91 @@
+package kubernetesruntime
+
+import (
+	"context"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/getoutreach/devenv/pkg/appregistry"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// namespace returns a namespace, managed namespaces are labelled as
+// created by devenv
+func namespace(name string, managed bool) runtime.Object {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	if managed {
+		ns.Labels = map[string]string{appregistry.ManagedNamespaceLabel: "true"}
+	}
+	return ns
+}
+
+func TestDeleteManagedNamespaces(t *testing.T) {
+	tests := []struct {
+		name        string
+		namespaces  []runtime.Object
+		preexisting []string
+		want        []string
+	}{
+		{
+			name: "should delete namespaces created by devenv",
+			namespaces: []runtime.Object{
+				namespace("default", false),
+				namespace("authz", true),
+				namespace("authz--bento1a", true),
+			},
+			want: []string{"default"},
+		},
+		{
+			name: "should keep namespaces created by others after adoption",
+			namespaces: []runtime.Object{
+				namespace("authz", true),
+				namespace("other-team", false),
+			},
+			want: []string{"other-team"},
+		},
+		{
+			name: "should keep namespaces that existed when adopted",
+			namespaces: []runtime.Object{
+				namespace("authz", true),
+				namespace("kube-system", true),
+			},
+			preexisting: []string{"authz"},
+			want:        []string{"authz", "kube-system"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			k := fake.NewSimpleClientset(tt.namespaces...)
+
+			log := logrus.New()
+			log.Out = ioutil.Discard
+
+			if err := deleteManagedNamespaces(ctx, log, k, tt.preexisting); err != nil {
+				t.Fatalf("deleteManagedNamespaces() error = %v", err)
+			}
+
+			namespaces, err := k.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]string, 0)
+			for i := range namespaces.Items {
+				got = append(got, namespaces.Items[i].Name)
+			}
+			sort.Strings(got)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("deleteManagedNamespaces() left %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
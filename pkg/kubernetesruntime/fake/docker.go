@@ -0,0 +1,64 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+	dockerclient "github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+)
+
+// DockerClient is a docker client that keeps track of volumes in
+// memory. Only the methods used by devenv commands are implemented,
+// calling any other method panics.
+type DockerClient struct {
+	// APIClient is embedded to satisfy dockerclient.APIClient, it's
+	// always nil.
+	dockerclient.APIClient
+
+	mu sync.Mutex
+
+	// Volumes are the names of volumes that exist
+	Volumes map[string]bool
+
+	// Containers are returned by ContainerList
+	Containers []types.Container
+}
+
+// NewDockerClient creates a new docker client with the given volumes
+func NewDockerClient(volumes ...string) *DockerClient {
+	d := &DockerClient{Volumes: make(map[string]bool)}
+	for _, v := range volumes {
+		d.Volumes[v] = true
+	}
+	return d
+}
+
+// HasVolume returns true if a volume exists
+func (d *DockerClient) HasVolume(name string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.Volumes[name]
+}
+
+func (d *DockerClient) VolumeRemove(_ context.Context, volumeID string, _ bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.Volumes[volumeID] {
+		return errdefs.NotFound(fmt.Errorf("no such volume: %s", volumeID))
+	}
+
+	delete(d.Volumes, volumeID)
+	return nil
+}
+
+func (d *DockerClient) ContainerList(_ context.Context, _ types.ContainerListOptions) ([]types.Container, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([]types.Container{}, d.Containers...), nil
+}
@@ -0,0 +1,285 @@
+// Package fake implements an in-memory kubernetes runtime, and docker
+// client, for testing commands without a real cluster.
+package fake
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/getoutreach/devenv/cmd/devenv/status"
+	"github.com/getoutreach/devenv/pkg/kubernetesruntime"
+	"github.com/getoutreach/gobox/pkg/box"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// DefaultKubernetesVersion is the version reported by clusters
+// created by a Runtime when none was set
+const DefaultKubernetesVersion = "v1.20.7"
+
+// Ensure Runtime implements kubernetesruntime.Runtime
+var _ kubernetesruntime.Runtime = &Runtime{}
+
+// Runtime is a scriptable kubernetes runtime whose cluster is backed
+// by a client-go fake clientset. All methods are safe to call
+// concurrently.
+type Runtime struct {
+	mu sync.Mutex
+
+	// Config is returned by GetConfig
+	Config kubernetesruntime.RuntimeConfig
+
+	// Objects are added to the clientset of the cluster when it's created
+	Objects []runtime.Object
+
+	// KubernetesVersion is the version reported by the cluster
+	KubernetesVersion string
+
+	// Errors are returned by the method with the given name, e.g.
+	// "Create", instead of running it
+	Errors map[string]error
+
+	// Clusters are returned by GetClusters in addition to the cluster
+	// created by this runtime
+	Clusters []*kubernetesruntime.RuntimeCluster
+
+	// Clientset is the clientset of the cluster, it's nil when no
+	// cluster has been created
+	Clientset *fakekube.Clientset
+
+	// Calls are the names of the methods that have been called, in order
+	Calls []string
+
+	// Images are the images that have been loaded into the cluster
+	Images []string
+
+	running bool
+}
+
+// NewRuntime creates a new runtime with the given name whose cluster
+// contains objects once created
+func NewRuntime(name string, objects ...runtime.Object) *Runtime {
+	return &Runtime{
+		Config: kubernetesruntime.RuntimeConfig{
+			Name:        name,
+			Type:        kubernetesruntime.RuntimeTypeLocal,
+			ClusterName: kubernetesruntime.KindClusterName,
+		},
+		Objects:           objects,
+		KubernetesVersion: DefaultKubernetesVersion,
+		Errors:            make(map[string]error),
+	}
+}
+
+// NewProvisionedRuntime creates a new runtime whose cluster has already
+// been created and is running
+func NewProvisionedRuntime(name string, objects ...runtime.Object) *Runtime {
+	r := NewRuntime(name, objects...)
+	r.createCluster()
+	return r
+}
+
+// call records a call to a method, returning the error scripted for it
+func (r *Runtime) call(method string) error {
+	r.Calls = append(r.Calls, method)
+	return r.Errors[method]
+}
+
+// Called returns the number of times a method has been called
+func (r *Runtime) Called(method string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var count int
+	for _, c := range r.Calls {
+		if c == method {
+			count++
+		}
+	}
+	return count
+}
+
+// createCluster creates the clientset backing the cluster
+func (r *Runtime) createCluster() {
+	r.Clientset = fakekube.NewSimpleClientset(r.Objects...)
+	r.Clientset.Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = &version.Info{
+		GitVersion: r.KubernetesVersion,
+	}
+	r.running = true
+}
+
+func (r *Runtime) GetConfig() kubernetesruntime.RuntimeConfig {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.Config
+}
+
+func (r *Runtime) Configure(_ logrus.FieldLogger, _ *box.Config) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.call("Configure") //nolint:errcheck // Why: Configure can't fail
+}
+
+func (r *Runtime) PreCreate(_ context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.call("PreCreate")
+}
+
+// Status returns the status of the cluster, a scripted error is
+// reported as an unknown status
+func (r *Runtime) Status(_ context.Context) kubernetesruntime.RuntimeStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	resp := kubernetesruntime.RuntimeStatus{Status: status.Status{Status: status.Unknown}}
+	if err := r.call("Status"); err != nil {
+		resp.Reason = err.Error()
+		return resp
+	}
+
+	switch {
+	case r.Clientset == nil:
+		resp.Status.Status = status.Unprovisioned
+	case !r.running:
+		resp.Status.Status = status.Stopped
+	default:
+		resp.Status.Status = status.Running
+		resp.KubernetesVersion = r.KubernetesVersion
+	}
+
+	return resp
+}
+
+func (r *Runtime) Create(_ context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.call("Create"); err != nil {
+		return err
+	}
+
+	if r.Clientset != nil {
+		return fmt.Errorf("cluster '%s' already exists", r.Config.ClusterName)
+	}
+
+	r.createCluster()
+	return nil
+}
+
+func (r *Runtime) Destroy(_ context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.call("Destroy"); err != nil {
+		return err
+	}
+
+	r.Clientset = nil
+	r.running = false
+	return nil
+}
+
+func (r *Runtime) Start(_ context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.call("Start"); err != nil {
+		return err
+	}
+
+	if r.Clientset == nil {
+		return fmt.Errorf("cluster '%s' doesn't exist", r.Config.ClusterName)
+	}
+
+	r.running = true
+	return nil
+}
+
+func (r *Runtime) Stop(_ context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.call("Stop"); err != nil {
+		return err
+	}
+
+	if r.Clientset == nil {
+		return fmt.Errorf("cluster '%s' doesn't exist", r.Config.ClusterName)
+	}
+
+	r.running = false
+	return nil
+}
+
+func (r *Runtime) GetKubeConfig(_ context.Context) (*api.Config, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.call("GetKubeConfig"); err != nil {
+		return nil, err
+	}
+
+	if r.Clientset == nil {
+		return nil, fmt.Errorf("found no kubeconfig, was a cluster created?")
+	}
+
+	return r.kubeConfig(), nil
+}
+
+// kubeConfig returns a kubeconfig pointing to an unreachable server,
+// the cluster should be accessed through Clientset instead
+func (r *Runtime) kubeConfig() *api.Config {
+	name := r.Config.Name + "-" + r.Config.ClusterName
+
+	kubeConfig := api.NewConfig()
+	kubeConfig.Clusters[name] = &api.Cluster{Server: "https://" + name + ".invalid"}
+	kubeConfig.AuthInfos[name] = &api.AuthInfo{Token: "fake"}
+	kubeConfig.Contexts[kubernetesruntime.KindClusterName] = &api.Context{Cluster: name, AuthInfo: name}
+	kubeConfig.CurrentContext = kubernetesruntime.KindClusterName
+	return kubeConfig
+}
+
+func (r *Runtime) GetClusters(_ context.Context) ([]*kubernetesruntime.RuntimeCluster, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.call("GetClusters"); err != nil {
+		return nil, err
+	}
+
+	clusters := append([]*kubernetesruntime.RuntimeCluster{}, r.Clusters...)
+	if r.Clientset != nil {
+		clusters = append(clusters, &kubernetesruntime.RuntimeCluster{
+			RuntimeName: r.Config.Name,
+			Name:        r.Config.ClusterName,
+			KubeConfig:  r.kubeConfig(),
+		})
+	}
+
+	return clusters, nil
+}
+
+func (r *Runtime) LoadImage(_ context.Context, image string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.call("LoadImage"); err != nil {
+		return err
+	}
+
+	if r.Clientset == nil {
+		return fmt.Errorf("cluster '%s' doesn't exist", r.Config.ClusterName)
+	}
+
+	r.Images = append(r.Images, image)
+	return nil
+}
@@ -0,0 +1,273 @@
+package kubernetesruntime
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"runtime"
+	"text/template"
+
+	"github.com/getoutreach/devenv/cmd/devenv/status"
+	"github.com/getoutreach/devenv/pkg/cmdutil"
+	"github.com/getoutreach/devenv/pkg/embed"
+	"github.com/getoutreach/gobox/pkg/app"
+	"github.com/getoutreach/gobox/pkg/box"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+
+	dockerclient "github.com/docker/docker/client"
+)
+
+const (
+	K3dVersion     = "v5.2.2"
+	K3dDownloadURL = "https://github.com/rancher/k3d/releases/download/" + K3dVersion + "/k3d-" + runtime.GOOS + "-" + runtime.GOARCH
+	K3dClusterName = "dev-environment"
+)
+
+var k3dConfigTemplate = template.Must(template.New("k3d.yaml").Parse(string(embed.MustRead(embed.Config.ReadFile("config/k3d.yaml")))))
+
+type K3dRuntime struct {
+	log logrus.FieldLogger
+
+	// kubernetesVersion is the version of kubernetes to create
+	// clusters with, and image is the k3s image that provides it
+	kubernetesVersion string
+	image             string
+}
+
+// NewK3dRuntime creates a new k3d runtime
+func NewK3dRuntime() *K3dRuntime {
+	return &K3dRuntime{}
+}
+
+// ensureK3d ensures that k3d exists and returns
+// the location of k3d. Note: this outputs text
+// if k3d is being downloaded
+func (*K3dRuntime) ensureK3d(log logrus.FieldLogger) (string, error) {
+	return cmdutil.EnsureBinary(log, "k3d-"+K3dVersion, "Kubernetes Runtime", K3dDownloadURL, "")
+}
+
+// getServerContainerName returns the name of the container
+// that runs the k3s server for our cluster
+func (*K3dRuntime) getServerContainerName() string {
+	return "k3d-" + K3dClusterName + "-server-0"
+}
+
+// SetKubernetesVersion sets the version of Kubernetes that new clusters
+// should be created with. An error is returned if the version isn't
+// supported by k3d.
+func (kr *K3dRuntime) SetKubernetesVersion(version string) error {
+	resolvedVersion, image, err := ResolveK3sImage(version)
+	if err != nil {
+		return err
+	}
+
+	kr.kubernetesVersion = resolvedVersion
+	kr.image = image
+	return nil
+}
+
+// PreCreate ensures that the Kubernetes version to create the cluster
+// with is supported, using the configured default if one wasn't set.
+func (kr *K3dRuntime) PreCreate(ctx context.Context) error {
+	if kr.image != "" {
+		return nil
+	}
+
+	version := GetDefaultKubernetesVersion(ctx)
+	return errors.Wrapf(kr.SetKubernetesVersion(version), "invalid default kubernetes version '%s'", version)
+}
+
+func (kr *K3dRuntime) Configure(log logrus.FieldLogger, _ *box.Config) {
+	kr.log = log
+}
+
+func (*K3dRuntime) GetConfig() RuntimeConfig {
+	return RuntimeConfig{
+		Name:        "k3d",
+		Type:        RuntimeTypeLocal,
+		ClusterName: K3dClusterName,
+		Capabilities: RuntimeCapabilities{
+			CanLoadImages:     true,
+			HasHostPorts:      true,
+			SupportsSleep:     true,
+			SupportsSnapshots: true,
+			SingleNode:        true,
+			RunsOnDocker:      true,
+		},
+	}
+}
+
+// Status gets the status of a runtime
+func (kr *K3dRuntime) Status(ctx context.Context) RuntimeStatus {
+	resp := RuntimeStatus{status.Status{
+		Status: status.Unknown,
+	}}
+
+	d, err := dockerclient.NewClientWithOpts(dockerclient.FromEnv)
+	if err != nil {
+		resp.Reason = errors.Wrap(err, "failed to connect to docker").Error()
+		return resp
+	}
+
+	cont, err := d.ContainerInspect(ctx, kr.getServerContainerName())
+	if err != nil {
+		if dockerclient.IsErrNotFound(err) {
+			resp.Status.Status = status.Unprovisioned
+			return resp
+		}
+
+		resp.Reason = errors.Wrap(err, "failed to inspect container").Error()
+		return resp
+	}
+
+	if _, ok := cont.Config.Labels["io.outreach.devenv.version"]; ok {
+		resp.Version = cont.Config.Labels["io.outreach.devenv.version"]
+	}
+
+	if _, ok := cont.Config.Labels[kubernetesVersionLabel]; ok {
+		resp.KubernetesVersion = cont.Config.Labels[kubernetesVersionLabel]
+	}
+
+	if cont.State.Status == "exited" {
+		resp.Status.Status = status.Stopped
+		return resp
+	}
+
+	if cont.State.Status == "running" {
+		resp.Status.Status = status.Running
+	}
+
+	return resp
+}
+
+// Create creates a new k3d cluster
+func (kr *K3dRuntime) Create(ctx context.Context) error {
+	k3d, err := kr.ensureK3d(kr.log)
+	if err != nil {
+		return err
+	}
+
+	renderedConfig, err := os.CreateTemp("", "k3d-config-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(renderedConfig.Name())
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return errors.Wrap(err, "failed to get user home dir")
+	}
+
+	err = k3dConfigTemplate.Execute(renderedConfig, map[string]string{
+		"Home":              homeDir,
+		"Name":              K3dClusterName,
+		"Image":             kr.image,
+		"DevenvVersion":     app.Info().Version,
+		"KubernetesVersion": kr.kubernetesVersion,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to generate k3d configuration")
+	}
+
+	cmd := exec.CommandContext(ctx, k3d, "cluster", "create", "--config", renderedConfig.Name())
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = os.Stdin
+	return errors.Wrap(cmd.Run(), "failed to run k3d")
+}
+
+// Destroy destroys a k3d cluster
+func (kr *K3dRuntime) Destroy(ctx context.Context) error {
+	k3d, err := kr.ensureK3d(kr.log)
+	if err != nil {
+		return err
+	}
+
+	b, err := exec.CommandContext(ctx, k3d, "cluster", "delete", K3dClusterName).CombinedOutput()
+	return errors.Wrapf(err, "failed to run k3d: %s", b)
+}
+
+// Start starts a stopped k3d cluster
+func (kr *K3dRuntime) Start(ctx context.Context) error {
+	k3d, err := kr.ensureK3d(kr.log)
+	if err != nil {
+		return err
+	}
+
+	b, err := exec.CommandContext(ctx, k3d, "cluster", "start", K3dClusterName).CombinedOutput()
+	return errors.Wrapf(err, "failed to run k3d: %s", b)
+}
+
+// Stop stops a running k3d cluster
+func (kr *K3dRuntime) Stop(ctx context.Context) error {
+	k3d, err := kr.ensureK3d(kr.log)
+	if err != nil {
+		return err
+	}
+
+	b, err := exec.CommandContext(ctx, k3d, "cluster", "stop", K3dClusterName).CombinedOutput()
+	return errors.Wrapf(err, "failed to run k3d: %s", b)
+}
+
+// GetKubeConfig reads a kubeconfig from k3d and returns it
+func (kr *K3dRuntime) GetKubeConfig(ctx context.Context) (*api.Config, error) {
+	k3d, err := kr.ensureK3d(logrus.New())
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := exec.CommandContext(ctx, k3d, "kubeconfig", "get", K3dClusterName).Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to run k3d: %s", b)
+	}
+
+	kubeconfig, err := clientcmd.Load(b)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load client config")
+	}
+
+	if c, ok := kubeconfig.Contexts["k3d-"+K3dClusterName]; ok {
+		kubeconfig.Contexts[K3dClusterName] = c
+		delete(kubeconfig.Contexts, "k3d-"+K3dClusterName)
+	}
+
+	kubeconfig.CurrentContext = K3dClusterName
+
+	return kubeconfig, nil
+}
+
+func (kr *K3dRuntime) GetClusters(ctx context.Context) ([]*RuntimeCluster, error) {
+	curStatus := kr.Status(ctx).Status.Status
+
+	if curStatus == status.Unprovisioned || curStatus == status.Unknown {
+		// Only return a cluster if it's actively running
+		return []*RuntimeCluster{}, nil
+	}
+
+	kubeconfig, err := kr.GetKubeConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return []*RuntimeCluster{
+		{
+			Name:        K3dClusterName,
+			RuntimeName: kr.GetConfig().Name,
+			KubeConfig:  kubeconfig,
+		},
+	}, nil
+}
+
+// LoadImage imports a docker image into the k3d cluster
+func (kr *K3dRuntime) LoadImage(ctx context.Context, image string) error {
+	k3d, err := kr.ensureK3d(kr.log)
+	if err != nil {
+		return errors.Wrap(err, "failed to find/download k3d")
+	}
+
+	b, err := exec.CommandContext(ctx, k3d, "image", "import", image, "--cluster", K3dClusterName).CombinedOutput()
+	return errors.Wrapf(err, "failed to run k3d: %s", b)
+}
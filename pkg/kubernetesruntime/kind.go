@@ -2,14 +2,19 @@ package kubernetesruntime
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strings"
+	"sync"
 	"text/template"
+	"time"
 
 	"github.com/getoutreach/devenv/cmd/devenv/status"
 	"github.com/getoutreach/devenv/pkg/cmdutil"
+	"github.com/getoutreach/devenv/pkg/config"
 	"github.com/getoutreach/devenv/pkg/containerruntime"
 	"github.com/getoutreach/devenv/pkg/embed"
 	"github.com/getoutreach/gobox/pkg/app"
@@ -19,6 +24,8 @@ import (
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/clientcmd/api"
 
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
 	dockerclient "github.com/docker/docker/client"
 )
 
@@ -26,6 +33,23 @@ const (
 	KindVersion     = "v0.12.0-outreach.1"
 	KindDownloadURL = "https://github.com/getoutreach/kind/releases/download/" + KindVersion + "/kind-" + runtime.GOOS + "-" + runtime.GOARCH
 	KindClusterName = "dev-environment"
+
+	// kindClusterLabel is the label kind sets on containers with
+	// the name of the cluster they belong to
+	kindClusterLabel = "io.x-k8s.kind.cluster"
+
+	// devenvVersionLabel is the label we set on containers created
+	// by devenv
+	devenvVersionLabel = "io.outreach.devenv.version"
+
+	// kubernetesVersionLabel is the label we set on containers created
+	// by devenv with the version of Kubernetes they were created with
+	kubernetesVersionLabel = "io.outreach.devenv.kubernetes-version"
+
+	// localRegistryOption is the runtime option, see
+	// config.ContextConfig.RuntimeOptions, that stores the address of the
+	// local registry used by a cluster
+	localRegistryOption = "localRegistry"
 )
 
 var configTemplate = template.Must(template.New("kind.yaml").Parse(string(embed.MustRead(embed.Config.ReadFile("config/kind.yaml")))))
@@ -36,6 +60,30 @@ var EnsureKind = (&KindRuntime{}).ensureKind
 
 type KindRuntime struct {
 	log logrus.FieldLogger
+	box *box.Config
+
+	// clusterName is the name of the kind cluster this runtime
+	// is operating on
+	clusterName   string
+	clusterNameMu sync.Mutex
+
+	// kubernetesVersion is the version of kubernetes to create
+	// clusters with, and nodeImage is the kind node image that
+	// provides it
+	kubernetesVersion string
+	nodeImage         string
+
+	// topology is the layout of the nodes of clusters created
+	// by this runtime
+	topology KindTopology
+
+	// registryPort is the port of the host to expose the local
+	// registry on, if new clusters should use it
+	registryPort int
+
+	// localRegistry is the address of the local registry used by
+	// the cluster, if it uses one
+	localRegistry string
 }
 
 // NewKindRuntime creates a new kind runtime
@@ -50,19 +98,127 @@ func (*KindRuntime) ensureKind(log logrus.FieldLogger) (string, error) { //nolin
 	return cmdutil.EnsureBinary(log, "kind-"+KindVersion, "Kubernetes Runtime", KindDownloadURL, "")
 }
 
-func (*KindRuntime) PreCreate(ctx context.Context) error {
+// SetClusterName sets the name of the cluster this runtime should
+// operate on, this allows creating more than one kind cluster.
+func (kr *KindRuntime) SetClusterName(name string) {
+	kr.clusterNameMu.Lock()
+	defer kr.clusterNameMu.Unlock()
+
+	kr.clusterName = name
+}
+
+// SetKubernetesVersion sets the version of Kubernetes that new clusters
+// should be created with. An error is returned if the version isn't
+// supported by kind.
+func (kr *KindRuntime) SetKubernetesVersion(version string) error {
+	resolvedVersion, image, err := ResolveKindNodeImage(version)
+	if err != nil {
+		return err
+	}
+
+	kr.kubernetesVersion = resolvedVersion
+	kr.nodeImage = image
+	return nil
+}
+
+// SetTopology sets the layout of the nodes that new clusters should
+// be created with.
+func (kr *KindRuntime) SetTopology(t KindTopology) error {
+	if err := t.Validate(); err != nil {
+		return errors.Wrap(err, "invalid topology")
+	}
+
+	kr.topology = t
 	return nil
 }
 
-func (kr *KindRuntime) Configure(log logrus.FieldLogger, _ *box.Config) {
+// EnableLocalRegistry makes new clusters pull images from the local
+// registry, see containerruntime.EnsureRegistry, before falling back
+// to the image registry. The registry is exposed on the given port of
+// the host, if it doesn't exist yet.
+func (kr *KindRuntime) EnableLocalRegistry(port int) {
+	kr.registryPort = port
+}
+
+// PreCreate ensures that the Kubernetes version to create the cluster
+// with is supported, using the configured default if one wasn't set.
+func (kr *KindRuntime) PreCreate(ctx context.Context) error {
+	if kr.nodeImage != "" {
+		return nil
+	}
+
+	version := GetDefaultKubernetesVersion(ctx)
+	return errors.Wrapf(kr.SetKubernetesVersion(version), "invalid default kubernetes version '%s'", version)
+}
+
+func (kr *KindRuntime) Configure(log logrus.FieldLogger, b *box.Config) {
 	kr.log = log
+	kr.box = b
+
+	conf, err := config.LoadConfig(context.TODO())
+	if err != nil {
+		return
+	}
+
+	kr.clusterNameMu.Lock()
+	if kr.clusterName == "" {
+		kr.clusterName = kr.currentCluster(context.TODO(), conf)
+	}
+	kr.clusterNameMu.Unlock()
+
+	// Clusters record the local registry they use when they're created
+	if cc := conf.GetContextConfig(config.ContextName("kind", kr.GetConfig().ClusterName)); cc != nil {
+		kr.localRegistry = cc.RuntimeOptions[localRegistryOption]
+	}
+}
+
+// currentCluster returns the cluster of the current devenv context, if
+// it's a kind context and the cluster still exists. Otherwise an empty
+// string is returned, so the default cluster is used.
+func (kr *KindRuntime) currentCluster(ctx context.Context, conf *config.Config) string {
+	runtimeName, name := conf.ParseContext()
+	if runtimeName != "kind" || name == "" {
+		return ""
+	}
+
+	d, err := dockerclient.NewClientWithOpts(dockerclient.FromEnv)
+	if err != nil {
+		return ""
+	}
+
+	nodes, err := kr.getNodeContainers(ctx, d, name)
+	if err != nil || len(nodes) == 0 {
+		return ""
+	}
+
+	return name
 }
 
-func (*KindRuntime) GetConfig() RuntimeConfig {
+func (kr *KindRuntime) GetConfig() RuntimeConfig {
+	kr.clusterNameMu.Lock()
+	clusterName := kr.clusterName
+	kr.clusterNameMu.Unlock()
+
+	if clusterName == "" {
+		clusterName = KindClusterName
+	}
+
 	return RuntimeConfig{
-		Name:        "kind",
-		Type:        RuntimeTypeLocal,
-		ClusterName: "dev-environment",
+		Name:          "kind",
+		Type:          RuntimeTypeLocal,
+		ClusterName:   clusterName,
+		LocalRegistry: kr.localRegistry,
+		Capabilities: RuntimeCapabilities{
+			CanLoadImages: true,
+			// Only one cluster can bind to the host's ports, so only the
+			// default cluster exposes them.
+			HasHostPorts:       clusterName == KindClusterName,
+			SupportsSleep:      true,
+			SupportsSnapshots:  true,
+			SingleNode:         kr.topology.Workers == 0,
+			PersistsImageCache: true,
+			RunsOnDocker:       true,
+		},
 	}
 }
 
@@ -80,7 +236,7 @@ func (kr *KindRuntime) Status(ctx context.Context) RuntimeStatus {
 
 	// check the status of the k3s container to determine
 	// if it's stopped
-	cont, err := d.ContainerInspect(ctx, containerruntime.ContainerName)
+	cont, err := d.ContainerInspect(ctx, containerruntime.GetContainerName(kr.GetConfig().ClusterName))
 	if err != nil {
 		if dockerclient.IsErrNotFound(err) {
 			resp.Status.Status = status.Unprovisioned
@@ -93,8 +249,11 @@ func (kr *KindRuntime) Status(ctx context.Context) RuntimeStatus {
 	}
 
 	// read the version of the container
-	if _, ok := cont.Config.Labels["io.outreach.devenv.version"]; ok {
-		resp.Version = cont.Config.Labels["io.outreach.devenv.version"]
+	if _, ok := cont.Config.Labels[devenvVersionLabel]; ok {
+		resp.Version = cont.Config.Labels[devenvVersionLabel]
+	}
+	if _, ok := cont.Config.Labels[kubernetesVersionLabel]; ok {
+		resp.KubernetesVersion = cont.Config.Labels[kubernetesVersionLabel]
 	}
 
 	// parse the container state
@@ -112,6 +271,10 @@ func (kr *KindRuntime) Status(ctx context.Context) RuntimeStatus {
 
 // Create creates a new Kind cluster
 func (kr *KindRuntime) Create(ctx context.Context) error {
+	if err := kr.PreCreate(ctx); err != nil {
+		return err
+	}
+
 	kind, err := kr.ensureKind(kr.log)
 	if err != nil {
 		return err
@@ -133,23 +296,81 @@ func (kr *KindRuntime) Create(ctx context.Context) error {
 		tagSuffix = "-" + runtime.GOARCH
 	}
 
-	err = configTemplate.Execute(renderedConfig, map[string]string{
+	registryClusterAddress := ""
+	if kr.registryPort != 0 {
+		kr.localRegistry, err = containerruntime.EnsureRegistry(ctx, kr.registryPort)
+		if err != nil {
+			return err
+		}
+		registryClusterAddress = containerruntime.RegistryClusterAddress
+	}
+
+	clusterName := kr.GetConfig().ClusterName
+	hostPorts := kr.GetConfig().Capabilities.HasHostPorts
+	if !hostPorts {
+		kr.log.Warn("Only the default devenv exposes ingress on localhost, use 'devenv tunnel' to access this devenv's services")
+	}
+
+	err = configTemplate.Execute(renderedConfig, map[string]interface{}{
 		"Home":          homeDir,
 		"Name":          "",
 		"DevenvVersion": app.Info().Version,
 		"TagSuffix":     tagSuffix,
+		"HostPorts":     hostPorts,
+
+		"KubernetesVersion": kr.kubernetesVersion,
+		"NodeImage":         kr.nodeImage,
+
+		"Workers":    make([]struct{}, kr.topology.Workers),
+		"NodeLabels": kr.topology.nodeLabelsArg(),
+		"NodeTaints": kr.topology.NodeTaints,
+
+		"LocalRegistry":    registryClusterAddress,
+		"MirroredRegistry": kr.getMirroredRegistry(),
 	})
 	if err != nil {
 		return errors.Wrap(err, "failed to generate kind configuration")
 	}
 
+	kr.log.WithField("kubernetes.version", kr.kubernetesVersion).WithField("workers", kr.topology.Workers).
+		Info("Creating kind cluster")
+
 	// we use a temp file for the kubeconfig because we don't actually use it
-	cmd := exec.CommandContext(ctx, kind, "create", "cluster", "--name", KindClusterName, "--wait", "5m", "--config", renderedConfig.Name(),
+	cmd := exec.CommandContext(ctx, kind, "create", "cluster", "--name", clusterName, "--wait", "5m", "--config", renderedConfig.Name(),
 		"--kubeconfig", filepath.Join(os.TempDir(), "devenv-kubeconfig-tmp.yaml"))
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
-	return errors.Wrap(cmd.Run(), "failed to run kind")
+	if err := cmd.Run(); err != nil { //nolint:govet // Why: We're OK shadowing err
+		return errors.Wrap(err, "failed to run kind")
+	}
+
+	if kr.localRegistry == "" {
+		return nil
+	}
+
+	// The kind network is created with the first cluster, so we can
+	// only attach the registry to it now.
+	if err := containerruntime.ConnectRegistry(ctx); err != nil { //nolint:govet // Why: We're OK shadowing err
+		return err
+	}
+
+	err = config.UpdateConfig(ctx, func(conf *config.Config) error {
+		cc := conf.EnsureContextConfig(config.ContextName("kind", clusterName))
+		cc.RuntimeOptions = map[string]string{localRegistryOption: kr.localRegistry}
+		return nil
+	})
+	return errors.Wrap(err, "failed to save local registry to devenv config")
+}
+
+// getMirroredRegistry returns the host of the image registry that the
+// local registry is a mirror for
+func (kr *KindRuntime) getMirroredRegistry() string {
+	if kr.box == nil || kr.box.DeveloperEnvironmentConfig.ImageRegistry == "" {
+		return "gcr.io"
+	}
+
+	return strings.SplitN(kr.box.DeveloperEnvironmentConfig.ImageRegistry, "/", 2)[0]
 }
 
 // Destroy destroys a kind cluster
@@ -159,8 +380,107 @@ func (kr *KindRuntime) Destroy(ctx context.Context) error {
 		return err
 	}
 
-	b, err := exec.CommandContext(ctx, kind, "delete", "cluster", "--name", KindClusterName).CombinedOutput()
-	return errors.Wrapf(err, "failed to run kind: %s", b)
+	clusterName := kr.GetConfig().ClusterName
+	b, err := exec.CommandContext(ctx, kind, "delete", "cluster", "--name", clusterName).CombinedOutput()
+	if err != nil {
+		return errors.Wrapf(err, "failed to run kind: %s", b)
+	}
+
+	err = config.UpdateConfig(ctx, func(conf *config.Config) error {
+		if cc := conf.GetContextConfig(config.ContextName("kind", clusterName)); cc != nil {
+			cc.RuntimeOptions = nil
+		}
+		return nil
+	})
+	return errors.Wrap(err, "failed to save devenv config")
+}
+
+// getNodeContainers returns the IDs of the containers of all nodes
+// of a kind cluster, including stopped ones
+func (kr *KindRuntime) getNodeContainers(ctx context.Context, d dockerclient.APIClient, clusterName string) ([]string, error) {
+	conts, err := d.ContainerList(ctx, types.ContainerListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", kindClusterLabel+"="+clusterName)),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list kind nodes")
+	}
+
+	ids := make([]string, len(conts))
+	for i := range conts {
+		ids[i] = conts[i].ID
+	}
+	return ids, nil
+}
+
+// Start starts all nodes of a stopped kind cluster
+func (kr *KindRuntime) Start(ctx context.Context) error {
+	d, err := dockerclient.NewClientWithOpts(dockerclient.FromEnv)
+	if err != nil {
+		return errors.Wrap(err, "failed to connect to docker")
+	}
+
+	conts, err := kr.getNodeContainers(ctx, d, kr.GetConfig().ClusterName)
+	if err != nil {
+		return err
+	}
+
+	if len(conts) == 0 {
+		if _, err = d.ContainerInspect(ctx, "k3s"); err == nil {
+			kr.log.Info("Please destroy and reprovision your cluster. This will greatly increase the stability.")
+			return fmt.Errorf("found older kubernetes runtime environment (k3s)")
+		}
+
+		return fmt.Errorf("developer environment not found")
+	}
+
+	for _, id := range conts {
+		if err := d.ContainerStart(ctx, id, types.ContainerStartOptions{}); err != nil {
+			return errors.Wrap(err, "failed to start container")
+		}
+	}
+
+	return nil
+}
+
+// Stop stops all nodes of a running kind cluster, as well as any
+// containers left over from older devenv versions
+func (kr *KindRuntime) Stop(ctx context.Context) error {
+	d, err := dockerclient.NewClientWithOpts(dockerclient.FromEnv)
+	if err != nil {
+		return errors.Wrap(err, "failed to connect to docker")
+	}
+
+	containers, err := kr.getNodeContainers(ctx, d, kr.GetConfig().ClusterName)
+	if err != nil {
+		return err
+	}
+
+	if kr.GetConfig().ClusterName == KindClusterName {
+		containers = append(containers,
+			"k3s",
+
+			// older containers
+			"proxy",
+			"proxy-http",
+			"proxy-https",
+
+			// new proxy containers
+			"proxy-6443",
+			"proxy-443",
+			"proxy-80",
+		)
+	}
+
+	timeout := time.Duration(0)
+	for _, cont := range containers {
+		err := d.ContainerStop(ctx, cont, &timeout)
+		if err != nil && !dockerclient.IsErrNotFound(err) {
+			return errors.Wrapf(err, "failed to stop container '%s'", cont)
+		}
+	}
+
+	return nil
 }
 
 // GetKubeConfig reads a kubeconfig from Kind and returns it
@@ -168,12 +488,17 @@ func (kr *KindRuntime) Destroy(ctx context.Context) error {
 // "$kindPath" get kubeconfig --name "$(yq -r ".name" <"$LIBDIR/kind.yaml")"
 //   | sed 's/kind-dev-environment/dev-environment/' >"$KUBECONFIG"
 func (kr *KindRuntime) GetKubeConfig(ctx context.Context) (*api.Config, error) {
+	return kr.getKubeConfigForCluster(ctx, kr.GetConfig().ClusterName)
+}
+
+// getKubeConfigForCluster reads the kubeconfig of a given kind cluster
+func (kr *KindRuntime) getKubeConfigForCluster(ctx context.Context, clusterName string) (*api.Config, error) {
 	kind, err := kr.ensureKind(logrus.New())
 	if err != nil {
 		return nil, err
 	}
 
-	b, err := exec.CommandContext(ctx, kind, "get", "kubeconfig", "--name", KindClusterName).Output()
+	b, err := exec.CommandContext(ctx, kind, "get", "kubeconfig", "--name", clusterName).Output()
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to run kind: %s", b)
 	}
@@ -183,9 +508,13 @@ func (kr *KindRuntime) GetKubeConfig(ctx context.Context) (*api.Config, error) {
 		return nil, errors.Wrap(err, "failed to load client config")
 	}
 
-	if c, ok := kubeconfig.Contexts["kind-"+KindClusterName]; ok {
+	c, ok := kubeconfig.Contexts["kind-"+clusterName]
+	if ok {
+		kubeconfig.Contexts[clusterName] = c
+		delete(kubeconfig.Contexts, "kind-"+clusterName)
+
+		// Compat with tools that want this context.
 		kubeconfig.Contexts[KindClusterName] = c
-		delete(kubeconfig.Contexts, "kind-"+KindClusterName)
 	}
 
 	kubeconfig.CurrentContext = KindClusterName
@@ -193,24 +522,55 @@ func (kr *KindRuntime) GetKubeConfig(ctx context.Context) (*api.Config, error) {
 	return kubeconfig, nil
 }
 
+// GetClusters returns all running kind clusters that were created by devenv
 func (kr *KindRuntime) GetClusters(ctx context.Context) ([]*RuntimeCluster, error) {
-	curStatus := kr.Status(ctx).Status.Status
-
-	if curStatus == status.Unprovisioned || curStatus == status.Unknown {
-		// Only return a cluster if it's actively running
-		return []*RuntimeCluster{}, nil
+	d, err := dockerclient.NewClientWithOpts(dockerclient.FromEnv)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to docker")
 	}
 
-	kubeconfig, err := kr.GetKubeConfig(ctx)
+	// Only return clusters that are actively running, we can't
+	// read the kubeconfig of a stopped cluster.
+	conts, err := d.ContainerList(ctx, types.ContainerListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("label", devenvVersionLabel),
+			filters.Arg("label", "io.x-k8s.kind.role=control-plane"),
+			filters.Arg("status", "running"),
+		),
+	})
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrap(err, "failed to list kind clusters")
 	}
 
-	return []*RuntimeCluster{
-		{
-			Name:        KindClusterName,
+	rclusters := make([]*RuntimeCluster, 0, len(conts))
+	for i := range conts {
+		clusterName := conts[i].Labels[kindClusterLabel]
+		if clusterName == "" {
+			continue
+		}
+
+		kubeconfig, err := kr.getKubeConfigForCluster(ctx, clusterName)
+		if err != nil {
+			return nil, err
+		}
+
+		rclusters = append(rclusters, &RuntimeCluster{
+			Name:        clusterName,
 			RuntimeName: kr.GetConfig().Name,
 			KubeConfig:  kubeconfig,
-		},
-	}, nil
+		})
+	}
+
+	return rclusters, nil
+}
+
+// LoadImage loads a docker image into all nodes of the kind cluster
+func (kr *KindRuntime) LoadImage(ctx context.Context, image string) error {
+	kind, err := kr.ensureKind(kr.log)
+	if err != nil {
+		return errors.Wrap(err, "failed to find/download kind")
+	}
+
+	b, err := exec.CommandContext(ctx, kind, "load", "docker-image", image, "--name", kr.GetConfig().ClusterName).CombinedOutput()
+	return errors.Wrapf(err, "failed to run kind: %s", b)
 }
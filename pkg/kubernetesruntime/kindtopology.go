@@ -0,0 +1,93 @@
+package kubernetesruntime
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// KindTopology is the layout of the nodes of a kind cluster
+type KindTopology struct {
+	// Workers is the number of worker nodes to create in addition
+	// to the control-plane node
+	Workers int
+
+	// NodeLabels are labels to set on all worker nodes
+	NodeLabels map[string]string
+
+	// NodeTaints are taints to set on all worker nodes
+	NodeTaints []corev1.Taint
+}
+
+// Validate ensures that a topology can be created
+func (t *KindTopology) Validate() error {
+	if t.Workers < 0 {
+		return fmt.Errorf("number of workers can't be negative")
+	}
+
+	if t.Workers == 0 && (len(t.NodeLabels) != 0 || len(t.NodeTaints) != 0) {
+		return fmt.Errorf("node labels and taints require at least one worker node")
+	}
+
+	return nil
+}
+
+// nodeLabelsArg returns the node labels in the format
+// expected by the kubelet's --node-labels flag
+func (t *KindTopology) nodeLabelsArg() string {
+	labels := make([]string, 0, len(t.NodeLabels))
+	for k, v := range t.NodeLabels {
+		labels = append(labels, k+"="+v)
+	}
+	sort.Strings(labels)
+
+	return strings.Join(labels, ",")
+}
+
+// ParseNodeLabels parses labels in the format key=value
+func ParseNodeLabels(labels []string) (map[string]string, error) {
+	parsed := make(map[string]string)
+	for _, l := range labels {
+		spl := strings.SplitN(l, "=", 2)
+		if len(spl) != 2 || spl[0] == "" {
+			return nil, fmt.Errorf("invalid node label '%s', expected key=value", l)
+		}
+
+		parsed[spl[0]] = spl[1]
+	}
+
+	return parsed, nil
+}
+
+// ParseNodeTaints parses taints in the format key[=value]:Effect,
+// as used by kubectl taint.
+func ParseNodeTaints(taints []string) ([]corev1.Taint, error) {
+	parsed := make([]corev1.Taint, 0, len(taints))
+	for _, t := range taints {
+		spl := strings.Split(t, ":")
+		if len(spl) != 2 || spl[0] == "" {
+			return nil, fmt.Errorf("invalid node taint '%s', expected key[=value]:Effect", t)
+		}
+
+		taint := corev1.Taint{
+			Key:    spl[0],
+			Effect: corev1.TaintEffect(spl[1]),
+		}
+		if kv := strings.SplitN(spl[0], "=", 2); len(kv) == 2 {
+			taint.Key = kv[0]
+			taint.Value = kv[1]
+		}
+
+		switch taint.Effect {
+		case corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+		default:
+			return nil, fmt.Errorf("invalid effect '%s' for node taint '%s'", taint.Effect, t)
+		}
+
+		parsed = append(parsed, taint)
+	}
+
+	return parsed, nil
+}
@@ -7,6 +7,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/getoutreach/devenv/cmd/devenv/status"
 	"github.com/getoutreach/devenv/pkg/config"
@@ -16,8 +17,9 @@ import (
 )
 
 var (
-	ErrNotFound   = errors.New("runtime not found")
-	ErrNotRunning = errors.New("no runtime is running")
+	ErrNotFound     = errors.New("runtime not found")
+	ErrNotRunning   = errors.New("no runtime is running")
+	ErrNotSupported = errors.New("operation not supported by runtime")
 )
 
 // RuntimeType dictates what type of runtime this kubernetes runtime
@@ -46,6 +48,48 @@ type RuntimeConfig struct {
 
 	// ClusterName is the name of the cluster this runtime creates
 	ClusterName string
+
+	// LocalRegistry is the address, from the host, of a registry attached
+	// to the cluster. If set, images should be pushed to it instead of
+	// being loaded into the cluster.
+	LocalRegistry string
+
+	// Capabilities are the features this runtime supports, these
+	// should be used over Type when determining if something
+	// is supported.
+	Capabilities RuntimeCapabilities
+}
+
+// RuntimeCapabilities is the set of optional features a runtime
+// supports.
+type RuntimeCapabilities struct {
+	// CanLoadImages denotes that locally built docker images can be
+	// loaded into the cluster without pushing them to a registry
+	CanLoadImages bool `json:"canLoadImages"`
+
+	// HasHostPorts denotes that the ingress controller of the cluster
+	// is bound to ports 80/443 on the host
+	HasHostPorts bool `json:"hasHostPorts"`
+
+	// SupportsSleep denotes that the cluster can be stopped and
+	// started again while keeping its state, see Runtime.Stop
+	SupportsSleep bool `json:"supportsSleep"`
+
+	// SupportsSnapshots denotes that the cluster can be provisioned
+	// from a snapshot
+	SupportsSnapshots bool `json:"supportsSnapshots"`
+
+	// SingleNode denotes that all workloads run on a single node, so
+	// resource requests should be removed from pods to let them fit
+	SingleNode bool `json:"singleNode"`
+
+	// PersistsImageCache denotes that the image cache of the cluster
+	// is kept on the host between clusters
+	PersistsImageCache bool `json:"persistsImageCache"`
+
+	// RunsOnDocker denotes that the nodes of the cluster are containers
+	// of the local docker daemon, so it has to have enough resources
+	RunsOnDocker bool `json:"runsOnDocker"`
 }
 
 // RuntimeCluster is a cluster that is currently provisioned / accessible by a given
@@ -59,6 +103,15 @@ type RuntimeCluster struct {
 
 	// KubeConfig is the kubeconfig that allows access to this cluster.
 	KubeConfig *api.Config
+
+	// Details are runtime specific details about this cluster, e.g.
+	// the parameters it was created with. These are only used for
+	// display purposes.
+	Details map[string]string
+
+	// Shared denotes that this cluster is owned by someone else and
+	// has been shared with the current user.
+	Shared bool
 }
 
 // RuntimeStatus is the status of a given runtime
@@ -81,6 +134,13 @@ type Runtime interface {
 	// Destroy destroys a kubernetes cluster from this runtime
 	Destroy(context.Context) error
 
+	// Start starts a stopped kubernetes cluster from this runtime
+	Start(context.Context) error
+
+	// Stop stops a running kubernetes cluster from this runtime, this
+	// persists the state of the cluster unlike Destroy.
+	Stop(context.Context) error
+
 	// PreCreate is ran before creating a kubernetes cluster, useful
 	// for implementing pre-requirements.
 	PreCreate(context.Context) error
@@ -95,15 +155,107 @@ type Runtime interface {
 
 	// GetClusters returns all clusters currently accessible by this runtime
 	GetClusters(context.Context) ([]*RuntimeCluster, error)
+
+	// LoadImage loads a locally built docker image into the active
+	// cluster created by this runtime. Runtimes that can't load images,
+	// see RuntimeCapabilities.CanLoadImages, return ErrNotSupported.
+	LoadImage(ctx context.Context, image string) error
+}
+
+var runtimes = []Runtime{NewLoftRuntime(), NewKindRuntime(), NewK3dRuntime(), NewExistingRuntime()}
+
+// GetRuntimeNames returns the names of all runtimes, including those
+// provided by plugins
+func GetRuntimeNames() []string {
+	all := getAllRuntimes()
+
+	names := make([]string, len(all))
+	for i, r := range all {
+		names[i] = getName(r)
+	}
+	return names
+}
+
+// GetContextStatus returns the status reported by the runtime of
+// the current context, or nil if it couldn't be determined, see
+// status.RuntimeStatusFunc
+func GetContextStatus(ctx context.Context, log logrus.FieldLogger) *status.Status {
+	b, err := config.LoadBox()
+	if err != nil {
+		return nil
+	}
+
+	conf, err := config.LoadConfig(ctx)
+	if err != nil {
+		return nil
+	}
+
+	r, err := GetRuntimeFromContext(conf, b)
+	if err != nil {
+		return nil
+	}
+	r.Configure(log, b)
+
+	rs := r.Status(ctx)
+	return &rs.Status
+}
+
+var (
+	// pluginRuntimes are runtimes provided by plugins, these
+	// are discovered when first needed, see getAllRuntimes.
+	pluginRuntimes     []Runtime
+	pluginRuntimesOnce sync.Once
+)
+
+// getName returns the name of a runtime. Plugin names are
+// determined without invoking the plugin.
+func getName(r Runtime) string {
+	if pr, ok := r.(*PluginRuntime); ok {
+		return pr.name
+	}
+
+	return r.GetConfig().Name
+}
+
+// isBuiltin returns true if a runtime with the given name is built-in
+func isBuiltin(name string) bool {
+	for _, r := range runtimes {
+		if getName(r) == name {
+			return true
+		}
+	}
+
+	return false
 }
 
-var runtimes = []Runtime{NewLoftRuntime(), NewKindRuntime()}
+// getAllRuntimes returns all built-in runtimes and runtimes provided
+// by plugins. Built-in runtimes take precedence over plugins with the
+// same name. Plugins are discovered on the first call, which searches
+// the PATH, so this should only be used when a plugin could be needed.
+func getAllRuntimes() []Runtime {
+	pluginRuntimesOnce.Do(func() {
+		for _, p := range DiscoverPlugins() {
+			if isBuiltin(getName(p)) {
+				continue
+			}
+			pluginRuntimes = append(pluginRuntimes, p)
+		}
+	})
+
+	return append(append([]Runtime{}, runtimes...), pluginRuntimes...)
+}
 
 // GetRuntime returns a runtime by name, if not found
-// nil is returned
+// nil is returned. Plugins are only discovered if no built-in
+// runtime has the name.
 func GetRuntime(name string) (Runtime, error) {
-	for _, r := range runtimes {
-		if r.GetConfig().Name == name {
+	candidates := runtimes
+	if !isBuiltin(name) {
+		candidates = getAllRuntimes()
+	}
+
+	for _, r := range candidates {
+		if getName(r) == name {
 			return r, nil
 		}
 	}
@@ -111,19 +263,28 @@ func GetRuntime(name string) (Runtime, error) {
 	return nil, ErrNotFound
 }
 
-// GetRuntimes returns all registered runtimes. Generally
-// GetEnabledRuntimes should be used over this.
+// GetRuntimes returns all registered runtimes, including those provided
+// by plugins. Generally GetEnabledRuntimes should be used over this.
 func GetRuntimes() []Runtime {
-	return runtimes
+	return getAllRuntimes()
 }
 
 // GetEnabledRuntimes returns a list of enabled runtimes
-// based on a given box configuration
+// based on a given box configuration. Plugins are only discovered
+// if a runtime that isn't built-in is enabled.
 func GetEnabledRuntimes(b *box.Config) []Runtime {
+	candidates := runtimes
+	for _, enabled := range b.DeveloperEnvironmentConfig.RuntimeConfig.EnabledRuntimes {
+		if !isBuiltin(enabled) {
+			candidates = getAllRuntimes()
+			break
+		}
+	}
+
 	selectedRuntimes := make([]Runtime, 0)
-	for _, r := range runtimes {
+	for _, r := range candidates {
 		for _, enabled := range b.DeveloperEnvironmentConfig.RuntimeConfig.EnabledRuntimes {
-			if enabled == r.GetConfig().Name {
+			if enabled == getName(r) {
 				selectedRuntimes = append(selectedRuntimes, r)
 			}
 		}
@@ -148,7 +309,7 @@ func GetRuntimeFromContext(conf *config.Config, b *box.Config) (Runtime, error)
 
 	runtimes := GetEnabledRuntimes(b)
 	for _, r := range runtimes {
-		if r.GetConfig().Name == runtime {
+		if getName(r) == runtime {
 			return r, nil
 		}
 	}
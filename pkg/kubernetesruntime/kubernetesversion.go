@@ -0,0 +1,113 @@
+package kubernetesruntime
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/getoutreach/devenv/pkg/config"
+)
+
+// DefaultKubernetesVersion is the version of Kubernetes used when
+// neither the devenv config nor the box recommend one.
+const DefaultKubernetesVersion = "v1.20.7"
+
+// KindNodeImages maps the Kubernetes versions that are supported by
+// KindVersion to the node image that provides them.
+var KindNodeImages = map[string]string{
+	"v1.19.16": "kindest/node:v1.19.16",
+	"v1.20.7":  "gcr.io/outreach-docker/kindest/node:v1.20.7",
+	"v1.21.10": "kindest/node:v1.21.10",
+	"v1.22.7":  "kindest/node:v1.22.7",
+	"v1.23.4":  "kindest/node:v1.23.4",
+}
+
+// K3sImages maps the Kubernetes versions that are supported by the k3d
+// runtime to the k3s image that provides them.
+var K3sImages = map[string]string{
+	"v1.19.16": "rancher/k3s:v1.19.16-k3s1",
+	"v1.20.7":  "rancher/k3s:v1.20.7-k3s1",
+	"v1.21.10": "rancher/k3s:v1.21.10-k3s1",
+	"v1.22.7":  "rancher/k3s:v1.22.7-k3s1",
+	"v1.23.4":  "rancher/k3s:v1.23.4-k3s1",
+}
+
+// GetSupportedKubernetesVersions returns all versions in KindNodeImages
+func GetSupportedKubernetesVersions() []string {
+	return supportedVersions(KindNodeImages)
+}
+
+// supportedVersions returns the sorted versions of an image table
+func supportedVersions(images map[string]string) []string {
+	versions := make([]string, 0, len(images))
+	for v := range images {
+		versions = append(versions, v)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// ResolveKindNodeImage resolves a Kubernetes version into the kind node
+// image that provides it, see resolveImage.
+func ResolveKindNodeImage(version string) (resolvedVersion, image string, err error) {
+	return resolveImage(KindNodeImages, version)
+}
+
+// ResolveK3sImage resolves a Kubernetes version into the k3s image that
+// provides it, see resolveImage.
+func ResolveK3sImage(version string) (resolvedVersion, image string, err error) {
+	return resolveImage(K3sImages, version)
+}
+
+// resolveImage resolves a Kubernetes version into the image, from an
+// image table, that provides it. Versions may be given with or without a
+// leading "v", and without a patch version, e.g. "1.21", in which case
+// the highest supported patch version is used.
+func resolveImage(images map[string]string, version string) (resolvedVersion, image string, err error) {
+	if !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+
+	if image, ok := images[version]; ok {
+		return version, image, nil
+	}
+
+	// Only a major.minor version can match multiple patch versions
+	if strings.Count(version, ".") == 1 {
+		resolvedPatch := -1
+		for v := range images {
+			if !strings.HasPrefix(v, version+".") {
+				continue
+			}
+
+			patch, err := strconv.Atoi(strings.TrimPrefix(v, version+".")) //nolint:govet // Why: We're OK shadowing err
+			if err == nil && patch > resolvedPatch {
+				resolvedVersion, resolvedPatch = v, patch
+			}
+		}
+
+		if resolvedVersion != "" {
+			return resolvedVersion, images[resolvedVersion], nil
+		}
+	}
+
+	return "", "", fmt.Errorf("kubernetes version '%s' is not supported, supported versions: %s",
+		version, strings.Join(supportedVersions(images), ", "))
+}
+
+// GetDefaultKubernetesVersion returns the Kubernetes version that should
+// be used when none was explicitly requested. The devenv config takes
+// precedence over the version recommended by the box.
+func GetDefaultKubernetesVersion(ctx context.Context) string {
+	if conf, err := config.LoadConfig(ctx); err == nil && conf.KubernetesVersion != "" {
+		return conf.KubernetesVersion
+	}
+
+	if b, err := config.LoadBoxConfig(); err == nil && b.DeveloperEnvironmentConfig.RuntimeConfig.KubernetesVersion != "" {
+		return b.DeveloperEnvironmentConfig.RuntimeConfig.KubernetesVersion
+	}
+
+	return DefaultKubernetesVersion
+}
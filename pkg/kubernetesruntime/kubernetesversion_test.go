@@ -0,0 +1,99 @@
+package kubernetesruntime
+
+import "testing"
+
+func TestResolveKindNodeImage(t *testing.T) {
+	tests := []struct {
+		name        string
+		version     string
+		wantVersion string
+		wantImage   string
+		wantErr     bool
+	}{
+		{
+			name:    "should reject a major version",
+			version: "1",
+			wantErr: true,
+		},
+		{
+			name:        "should resolve a minor version to its patch version",
+			version:     "1.21",
+			wantVersion: "v1.21.10",
+			wantImage:   "kindest/node:v1.21.10",
+		},
+		{
+			name:        "should resolve a minor version with a leading v",
+			version:     "v1.21",
+			wantVersion: "v1.21.10",
+			wantImage:   "kindest/node:v1.21.10",
+		},
+		{
+			name:        "should resolve a patch version",
+			version:     "1.21.10",
+			wantVersion: "v1.21.10",
+			wantImage:   "kindest/node:v1.21.10",
+		},
+		{
+			name:    "should reject an unsupported patch version",
+			version: "1.21.3",
+			wantErr: true,
+		},
+		{
+			name:    "should reject an unsupported minor version",
+			version: "1.2",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			gotVersion, gotImage, err := ResolveKindNodeImage(tt.version)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveKindNodeImage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if gotVersion != tt.wantVersion || gotImage != tt.wantImage {
+				t.Errorf("ResolveKindNodeImage() = %v, %v, want %v, %v", gotVersion, gotImage, tt.wantVersion, tt.wantImage)
+			}
+		})
+	}
+}
+
+func TestResolveK3sImage(t *testing.T) {
+	tests := []struct {
+		name        string
+		version     string
+		wantVersion string
+		wantImage   string
+		wantErr     bool
+	}{
+		{
+			name:        "should resolve a minor version to its patch version",
+			version:     "1.22",
+			wantVersion: "v1.22.7",
+			wantImage:   "rancher/k3s:v1.22.7-k3s1",
+		},
+		{
+			name:        "should resolve the default version",
+			version:     DefaultKubernetesVersion,
+			wantVersion: "v1.20.7",
+			wantImage:   "rancher/k3s:v1.20.7-k3s1",
+		},
+		{
+			name:    "should reject an unsupported version",
+			version: "1.18",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			gotVersion, gotImage, err := ResolveK3sImage(tt.version)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveK3sImage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if gotVersion != tt.wantVersion || gotImage != tt.wantImage {
+				t.Errorf("ResolveK3sImage() = %v, %v, want %v, %v", gotVersion, gotImage, tt.wantVersion, tt.wantImage)
+			}
+		})
+	}
+}
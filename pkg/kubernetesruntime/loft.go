@@ -11,20 +11,28 @@ import (
 	"path"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/getoutreach/devenv/cmd/devenv/status"
 	"github.com/getoutreach/devenv/pkg/cmdutil"
+	"github.com/getoutreach/devenv/pkg/config"
 	"github.com/getoutreach/gobox/pkg/box"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	rbacv1 "k8s.io/api/rbac/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/clientcmd/api"
 
 	managementv1 "github.com/loft-sh/api/pkg/apis/management/v1"
+	storagev1 "github.com/loft-sh/api/pkg/apis/storage/v1"
 	loftapi "github.com/loft-sh/api/pkg/client/clientset_generated/clientset"
 	loftconfig "github.com/loft-sh/loftctl/pkg/client"
 	clientauthv1alpha1 "k8s.io/client-go/pkg/apis/clientauthentication/v1alpha1"
@@ -33,8 +41,72 @@ import (
 const (
 	loftVersion     = "v1.15.0"
 	loftDownloadURL = "https://github.com/loft-sh/loft/releases/download/" + loftVersion + "/loft-" + runtime.GOOS + "-" + runtime.GOARCH
+
+	// LoftDefaultTemplate is the vcluster template used when
+	// none was provided
+	LoftDefaultTemplate = "devenv"
+
+	// LoftDefaultSleepAfter is the duration of inactivity after which
+	// vclusters are put to sleep when none was provided
+	LoftDefaultSleepAfter = time.Hour
+
+	// loftShareLabel is the label on the roles and role bindings created
+	// by devenv share, its value is the user the vcluster is shared with
+	loftShareLabel = "io.outreach.devenv/shared-with"
+
+	// loftTemplateAnnotation, loftSleepAfterAnnotation,
+	// loftClusterAnnotation and loftSpaceAnnotation are set on
+	// VirtualClusters created by devenv and store the parameters they
+	// were created with, see LoftOptions.
+	loftTemplateAnnotation   = "io.outreach.devenv/template"
+	loftSleepAfterAnnotation = "io.outreach.devenv/sleep-after"
+	loftClusterAnnotation    = "io.outreach.devenv/cluster"
+	loftSpaceAnnotation      = "io.outreach.devenv/space"
 )
 
+// LoftOptions are the parameters to create vclusters with
+type LoftOptions struct {
+	// Template is the vcluster template to create the vcluster from
+	Template string
+
+	// SleepAfter is the duration of inactivity after which the
+	// vcluster is put to sleep
+	SleepAfter time.Duration
+
+	// Cluster is the loft cluster to create the vcluster in, if not
+	// set loft picks one.
+	Cluster string
+
+	// Space is the space to create the vcluster in, if not set loft
+	// creates one.
+	Space string
+}
+
+// annotations returns the annotations that store these parameters
+// on a VirtualCluster
+func (o *LoftOptions) annotations() map[string]string {
+	return map[string]string{
+		loftTemplateAnnotation:   o.Template,
+		loftSleepAfterAnnotation: o.SleepAfter.String(),
+		loftClusterAnnotation:    o.Cluster,
+		loftSpaceAnnotation:      o.Space,
+	}
+}
+
+// loftOptionsFromAnnotations returns the parameters a VirtualCluster
+// was created with, ok is false if it wasn't created by devenv
+func loftOptionsFromAnnotations(annotations map[string]string) (opts LoftOptions, ok bool) {
+	opts.Template, ok = annotations[loftTemplateAnnotation]
+	if !ok {
+		return LoftOptions{}, false
+	}
+
+	opts.SleepAfter, _ = time.ParseDuration(annotations[loftSleepAfterAnnotation]) //nolint:errcheck // Why: Best effort
+	opts.Cluster = annotations[loftClusterAnnotation]
+	opts.Space = annotations[loftSpaceAnnotation]
+	return opts, true
+}
+
 type LoftRuntime struct {
 	// kubeConfig stores the kubeconfig of the last created
 	// cluster by Create()
@@ -45,8 +117,15 @@ type LoftRuntime struct {
 	loft     loftapi.Interface
 	loftUser *managementv1.Self
 
+	// loftRestConfig is the rest config used to talk to the loft
+	// management API
+	loftRestConfig *rest.Config
+
 	clusterName   string
 	clusterNameMu sync.Mutex
+
+	// opts are the parameters vclusters are created with
+	opts LoftOptions
 }
 
 func NewLoftRuntime() *LoftRuntime {
@@ -60,9 +139,46 @@ func (*LoftRuntime) ensureLoft(log logrus.FieldLogger) (string, error) {
 	return cmdutil.EnsureBinary(log, "loft-"+loftVersion, "Kubernetes Runtime", loftDownloadURL, "")
 }
 
+// SetOptions sets the parameters vclusters are created with, any
+// unset parameter is read from the box configuration.
+func (lr *LoftRuntime) SetOptions(opts LoftOptions) {
+	lr.opts = opts
+}
+
 func (lr *LoftRuntime) Configure(log logrus.FieldLogger, conf *box.Config) {
 	lr.box = conf
 	lr.log = log
+
+	// Default any parameters that weren't explicitly set
+	bconf := config.BoxLoftRuntimeConfig{}
+	if b, err := config.LoadBoxConfig(); err == nil {
+		bconf = b.DeveloperEnvironmentConfig.RuntimeConfig.Loft
+	}
+
+	if lr.opts.Template == "" {
+		lr.opts.Template = bconf.Template
+	}
+	if lr.opts.Template == "" {
+		lr.opts.Template = LoftDefaultTemplate
+	}
+
+	if lr.opts.SleepAfter == 0 && bconf.SleepAfter != "" {
+		sleepAfter, err := time.ParseDuration(bconf.SleepAfter)
+		if err != nil {
+			log.WithError(err).Warn("Ignoring invalid loft sleepAfter in box configuration")
+		}
+		lr.opts.SleepAfter = sleepAfter
+	}
+	if lr.opts.SleepAfter == 0 {
+		lr.opts.SleepAfter = LoftDefaultSleepAfter
+	}
+
+	if lr.opts.Cluster == "" {
+		lr.opts.Cluster = bconf.Cluster
+	}
+	if lr.opts.Space == "" {
+		lr.opts.Space = bconf.Space
+	}
 }
 
 func (lr *LoftRuntime) getLoftConfigPath() (string, error) {
@@ -74,37 +190,32 @@ func (lr *LoftRuntime) getLoftConfigPath() (string, error) {
 	return filepath.Join(homeDir, ".loft", "config.json"), nil
 }
 
-func (lr *LoftRuntime) PreCreate(ctx context.Context) error { //nolint:funlen // Why: will address later
-	lcli, err := lr.ensureLoft(lr.log)
-	if err != nil {
-		return err
-	}
-
+// loadLoftConfig reads the configuration, and credentials, of the
+// loft CLI
+func (lr *LoftRuntime) loadLoftConfig() (*loftconfig.Config, error) {
 	loftConf, err := lr.getLoftConfigPath()
 	if err != nil {
-		return errors.Wrap(err, "failed to determine loft config path")
+		return nil, errors.Wrap(err, "failed to determine loft config path")
 	}
 
 	f, err := os.Open(loftConf)
 	if err != nil {
-		lr.log.WithError(err).Info("Authenticating with loft")
-		err = cmdutil.RunKubernetesCommand(ctx, "", false, lcli, "login", lr.box.DeveloperEnvironmentConfig.RuntimeConfig.Loft.URL)
-		if err != nil {
-			return errors.Wrap(err, "failed to authenticate with loft")
-		}
-
-		f, err = os.Open(loftConf)
-		if err != nil {
-			return errors.Wrap(err, "failed to read loft config after authenticating")
-		}
+		return nil, errors.Wrap(err, "failed to open loft config")
 	}
 	defer f.Close()
 
 	var conf loftconfig.Config
 	if err := json.NewDecoder(f).Decode(&conf); err != nil { //nolint:govet // Why: We're OK shadowing error.
-		return errors.Wrap(err, "failed to read loft config")
+		return nil, errors.Wrap(err, "failed to read loft config")
 	}
 
+	return &conf, nil
+}
+
+// newClient creates a client for the loft management API from the
+// credentials of the loft CLI. This never logs in, an error is returned
+// if the credentials are no longer valid.
+func (lr *LoftRuntime) newClient(ctx context.Context, conf *loftconfig.Config) error {
 	restConf := &rest.Config{
 		Host:        "https://" + path.Join(strings.TrimPrefix(conf.Host, "https://"), "kubernetes", "management"),
 		BearerToken: conf.AccessKey,
@@ -116,24 +227,56 @@ func (lr *LoftRuntime) PreCreate(ctx context.Context) error { //nolint:funlen //
 	}
 
 	self, err := loftClient.ManagementV1().Selves().Create(ctx, &managementv1.Self{}, metav1.CreateOptions{})
-	if err != nil || self.Status.User == "" { // auth token likely expired, so just refresh it
-		lr.log.WithError(err).Info("Authenticating with loft")
-		err = cmdutil.RunKubernetesCommand(ctx, "", false, lcli, "login", conf.Host)
-		if err != nil {
-			return errors.Wrap(err, "failed to authenticate with loft")
-		}
-
-		// ensure that the new credentials are valid
-		return lr.PreCreate(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to validate loft credentials")
+	}
+	if self.Status.User == "" {
+		return fmt.Errorf("loft credentials have expired")
 	}
 
 	// we have valid credentials, so set the client.
 	lr.loft = loftClient
 	lr.loftUser = self
+	lr.loftRestConfig = restConf
 
 	return nil
 }
 
+// PreCreate ensures we're authenticated with loft, logging in if there
+// are no valid credentials, and creates a client for its management API
+func (lr *LoftRuntime) PreCreate(ctx context.Context) error {
+	lcli, err := lr.ensureLoft(lr.log)
+	if err != nil {
+		return err
+	}
+
+	host := lr.box.DeveloperEnvironmentConfig.RuntimeConfig.Loft.URL
+	if conf, err := lr.loadLoftConfig(); err == nil { //nolint:govet // Why: We're OK shadowing err
+		err = lr.newClient(ctx, conf)
+		if err == nil {
+			return nil
+		}
+
+		// auth token likely expired, so just refresh it
+		lr.log.WithError(err).Info("Authenticating with loft")
+		host = conf.Host
+	} else {
+		lr.log.WithError(err).Info("Authenticating with loft")
+	}
+
+	if err := cmdutil.RunKubernetesCommand(ctx, "", false, lcli, "login", host); err != nil { //nolint:govet // Why: We're OK shadowing err
+		return errors.Wrap(err, "failed to authenticate with loft")
+	}
+
+	conf, err := lr.loadLoftConfig()
+	if err != nil {
+		return errors.Wrap(err, "failed to read loft config after authenticating")
+	}
+
+	// ensure that the new credentials are valid
+	return errors.Wrap(lr.newClient(ctx, conf), "failed to authenticate with loft")
+}
+
 func (lr *LoftRuntime) GetConfig() RuntimeConfig {
 	// Generate the cluster name. Ensure that this is
 	// thread safe.
@@ -154,43 +297,114 @@ func (lr *LoftRuntime) GetConfig() RuntimeConfig {
 		Name:        "loft",
 		Type:        RuntimeTypeRemote,
 		ClusterName: lr.clusterName,
+		Capabilities: RuntimeCapabilities{
+			SupportsSleep:     true,
+			SupportsSnapshots: true,
+		},
 	}
 }
 
+// Status gets the status of the vcluster from the loft management API
 func (lr *LoftRuntime) Status(ctx context.Context) RuntimeStatus {
 	resp := RuntimeStatus{status.Status{
-		Status: status.Unprovisioned,
+		Status: status.Unknown,
 	}}
 
-	lcli, err := lr.ensureLoft(lr.log)
+	// Status may be called without PreCreate, e.g. by 'devenv status', it
+	// must never log in so only existing credentials are used
+	if lr.loft == nil {
+		conf, err := lr.loadLoftConfig()
+		if err == nil {
+			err = lr.newClient(ctx, conf)
+		}
+		if err != nil {
+			resp.Reason = errors.Wrap(err, "not logged in to loft").Error()
+			return resp
+		}
+	}
+
+	vc, err := lr.findVirtualCluster(ctx)
 	if err != nil {
-		resp.Status.Status = status.Unknown
-		resp.Status.Reason = errors.Wrap(err, "failed to get loft CLI").Error()
+		resp.Reason = err.Error()
 		return resp
 	}
 
-	out, err := exec.CommandContext(ctx, lcli, "list", "vclusters").CombinedOutput()
-	if err != nil {
-		resp.Status.Status = status.Unknown
-		resp.Status.Reason = errors.Wrap(err, "failed to list clusters").Error()
+	if vc == nil {
+		resp.Status.Status = status.Unprovisioned
+		return resp
+	}
+
+	if vc.SleepModeConfig != nil && vc.SleepModeConfig.Status.SleepingSince != 0 {
+		resp.Status.Status = status.Stopped
+		resp.Reason = "vcluster is sleeping, run 'devenv start' to wake it up"
 		return resp
 	}
 
-	// TODO(jaredallard): See if we can hit loft's API instead of this
-	// hacky not totally valid contains check.
-	if strings.Contains(string(out), lr.clusterName) {
+	release := vc.VirtualCluster.Status.HelmRelease
+	if release == nil {
+		release = &storagev1.VirtualClusterHelmReleaseStatus{}
+	}
+
+	switch phase := release.Phase; phase {
+	case storagev1.VirtualClusterHelmReleaseStatusDeployed:
 		resp.Status.Status = status.Running
+	case storagev1.VirtualClusterHelmReleaseStatusFailed:
+		resp.Status.Status = status.Degraded
+		resp.Reason = "vcluster failed to deploy"
+		if release.Message != "" {
+			resp.Reason += ": " + release.Message
+		}
+	default:
+		if phase == storagev1.VirtualClusterHelmReleaseStatusNotDeployed {
+			phase = "NotDeployed"
+		}
+
+		resp.Status.Status = status.Degraded
+		resp.Reason = fmt.Sprintf("vcluster is not ready (phase: %s)", phase)
 	}
 
 	return resp
 }
 
+// validateTemplate ensures that the vcluster template we'll create
+// the vcluster from exists
+func (lr *LoftRuntime) validateTemplate(ctx context.Context) error {
+	if lr.loft == nil {
+		return fmt.Errorf("loft client not configured, was PreCreate called?")
+	}
+
+	_, err := lr.loft.ManagementV1().VirtualClusterTemplates().Get(ctx, lr.opts.Template, metav1.GetOptions{})
+	if err == nil {
+		return nil
+	}
+
+	if !kerrors.IsNotFound(err) {
+		return errors.Wrap(err, "failed to get vcluster template")
+	}
+
+	templates, err := lr.loft.ManagementV1().VirtualClusterTemplates().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("vcluster template '%s' doesn't exist", lr.opts.Template)
+	}
+
+	names := make([]string, len(templates.Items))
+	for i := range templates.Items {
+		names[i] = templates.Items[i].Name
+	}
+	return fmt.Errorf("vcluster template '%s' doesn't exist, available templates: %s",
+		lr.opts.Template, strings.Join(names, ", "))
+}
+
 func (lr *LoftRuntime) Create(ctx context.Context) error {
 	loft, err := lr.ensureLoft(lr.log)
 	if err != nil {
 		return err
 	}
 
+	if err := lr.validateTemplate(ctx); err != nil { //nolint:govet // Why: We're OK shadowing err
+		return err
+	}
+
 	kubeConfig, err := os.CreateTemp("", "loft-kubeconfig-*")
 	if err != nil {
 		return err
@@ -198,9 +412,21 @@ func (lr *LoftRuntime) Create(ctx context.Context) error {
 	kubeConfig.Close() //nolint:errcheck
 	defer os.Remove(kubeConfig.Name())
 
-	cmd := exec.CommandContext(ctx, loft, "create", "vcluster",
-		"--sleep-after", "3600", // sleeps after 1 hour
-		"--template", "devenv", lr.clusterName)
+	args := []string{"create", "vcluster",
+		"--sleep-after", strconv.Itoa(int(lr.opts.SleepAfter.Seconds())),
+		"--template", lr.opts.Template,
+	}
+	if lr.opts.Cluster != "" {
+		args = append(args, "--cluster", lr.opts.Cluster)
+	}
+	if lr.opts.Space != "" {
+		args = append(args, "--space", lr.opts.Space)
+	}
+
+	lr.log.WithField("template", lr.opts.Template).WithField("sleepAfter", lr.opts.SleepAfter).
+		Info("Creating loft vcluster")
+
+	cmd := exec.CommandContext(ctx, loft, append(args, lr.clusterName)...)
 	cmd.Env = append(os.Environ(), "KUBECONFIG="+kubeConfig.Name())
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
@@ -211,7 +437,39 @@ func (lr *LoftRuntime) Create(ctx context.Context) error {
 	}
 
 	lr.kubeConfig, err = ioutil.ReadFile(kubeConfig.Name())
-	return errors.Wrap(err, "failed to read kubeconfig")
+	if err != nil {
+		return errors.Wrap(err, "failed to read kubeconfig")
+	}
+
+	return errors.Wrap(lr.saveParameters(ctx), "failed to save vcluster parameters")
+}
+
+// saveParameters records the parameters the vcluster was created with
+// as annotations on its VirtualCluster, so that they're available to
+// anyone with access to it
+func (lr *LoftRuntime) saveParameters(ctx context.Context) error {
+	vc, err := lr.getVirtualCluster(ctx)
+	if err != nil {
+		return err
+	}
+
+	lc, err := lr.getClusterLoftClient(vc.Cluster)
+	if err != nil {
+		return err
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": lr.opts.annotations(),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = lc.StorageV1().VirtualClusters(vc.VirtualCluster.Namespace).
+		Patch(ctx, vc.VirtualCluster.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return errors.Wrap(err, "failed to annotate vcluster")
 }
 
 func (lr *LoftRuntime) Destroy(ctx context.Context) error {
@@ -224,6 +482,80 @@ func (lr *LoftRuntime) Destroy(ctx context.Context) error {
 	return errors.Wrapf(err, "failed to delete loft vcluster: %s", out)
 }
 
+// findVirtualCluster returns the virtual cluster of this runtime, if
+// it doesn't exist nil is returned. When it exists, the parameters it
+// was created with replace the configured ones.
+func (lr *LoftRuntime) findVirtualCluster(ctx context.Context) (*managementv1.ClusterVirtualCluster, error) {
+	if lr.loft == nil {
+		return nil, fmt.Errorf("loft client not configured, was PreCreate called?")
+	}
+
+	clusters, err := lr.loft.ManagementV1().Users().ListVirtualClusters(ctx, lr.loftUser.Status.User, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list available clusters")
+	}
+
+	for i := range clusters.VirtualClusters {
+		vc := &clusters.VirtualClusters[i]
+		if vc.VirtualCluster.Name != lr.GetConfig().ClusterName {
+			continue
+		}
+
+		if opts, ok := loftOptionsFromAnnotations(vc.VirtualCluster.Annotations); ok {
+			lr.opts = opts
+		}
+		return vc, nil
+	}
+
+	return nil, nil
+}
+
+// getVirtualCluster returns the virtual cluster of this runtime
+func (lr *LoftRuntime) getVirtualCluster(ctx context.Context) (*managementv1.ClusterVirtualCluster, error) {
+	vc, err := lr.findVirtualCluster(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if vc == nil {
+		return nil, fmt.Errorf("failed to find loft vcluster '%s'", lr.GetConfig().ClusterName)
+	}
+
+	return vc, nil
+}
+
+// Start wakes up a sleeping loft vcluster
+func (lr *LoftRuntime) Start(ctx context.Context) error {
+	loft, err := lr.ensureLoft(lr.log)
+	if err != nil {
+		return err
+	}
+
+	vc, err := lr.getVirtualCluster(ctx)
+	if err != nil {
+		return err
+	}
+
+	out, err := exec.CommandContext(ctx, loft, "wakeup", vc.VirtualCluster.Namespace, "--cluster", vc.Cluster).CombinedOutput()
+	return errors.Wrapf(err, "failed to wake up loft vcluster: %s", out)
+}
+
+// Stop puts a loft vcluster to sleep
+func (lr *LoftRuntime) Stop(ctx context.Context) error {
+	loft, err := lr.ensureLoft(lr.log)
+	if err != nil {
+		return err
+	}
+
+	vc, err := lr.getVirtualCluster(ctx)
+	if err != nil {
+		return err
+	}
+
+	out, err := exec.CommandContext(ctx, loft, "sleep", vc.VirtualCluster.Namespace, "--cluster", vc.Cluster).CombinedOutput()
+	return errors.Wrapf(err, "failed to put loft vcluster to sleep: %s", out)
+}
+
 func (lr *LoftRuntime) GetKubeConfig(ctx context.Context) (*api.Config, error) {
 	if len(lr.kubeConfig) == 0 {
 		return nil, fmt.Errorf("found no kubeconfig, was a cluster created?")
@@ -297,12 +629,224 @@ func (lr *LoftRuntime) GetClusters(ctx context.Context) ([]*RuntimeCluster, erro
 	for i := range clusters.VirtualClusters {
 		c := &clusters.VirtualClusters[i]
 
+		shared, err := lr.isSharedWithUser(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+
+		details := map[string]string{
+			"cluster": c.Cluster,
+			"space":   c.VirtualCluster.Namespace,
+		}
+		if opts, ok := loftOptionsFromAnnotations(c.VirtualCluster.Annotations); ok {
+			details["template"] = opts.Template
+			details["sleepAfter"] = opts.SleepAfter.String()
+		}
+
 		rclusters[i] = &RuntimeCluster{
 			RuntimeName: lr.GetConfig().Name,
 			Name:        c.VirtualCluster.Name,
 			KubeConfig:  lr.getKubeConfigForVCluster(ctx, c),
+			Details:     details,
+			Shared:      shared,
 		}
 	}
 
 	return rclusters, nil
 }
+
+// LoadImage is not supported, loft vclusters pull images from
+// a registry
+func (lr *LoftRuntime) LoadImage(ctx context.Context, image string) error {
+	return errors.Wrap(ErrNotSupported, "images can't be loaded into loft vclusters")
+}
+
+// getClusterRestConfig returns the rest config for a cluster connected
+// to loft, requests are proxied through the loft apiserver
+func (lr *LoftRuntime) getClusterRestConfig(cluster string) (*rest.Config, error) {
+	if lr.loftRestConfig == nil {
+		return nil, fmt.Errorf("loft client not configured, was PreCreate called?")
+	}
+
+	restConf := rest.CopyConfig(lr.loftRestConfig)
+	restConf.Host = strings.TrimSuffix(restConf.Host, "/management") + "/cluster/" + cluster
+	return restConf, nil
+}
+
+// getClusterClient returns a client for a cluster connected to loft
+func (lr *LoftRuntime) getClusterClient(cluster string) (kubernetes.Interface, error) {
+	restConf, err := lr.getClusterRestConfig(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := kubernetes.NewForConfig(restConf)
+	return k, errors.Wrapf(err, "failed to create client for loft cluster '%s'", cluster)
+}
+
+// getClusterLoftClient returns a client for the loft resources, e.g.
+// VirtualClusters, of a cluster connected to loft
+func (lr *LoftRuntime) getClusterLoftClient(cluster string) (loftapi.Interface, error) {
+	restConf, err := lr.getClusterRestConfig(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	lc, err := loftapi.NewForConfig(restConf)
+	return lc, errors.Wrapf(err, "failed to create loft client for loft cluster '%s'", cluster)
+}
+
+// shareRoleName returns the name of the role, and role binding, that
+// grants a user access to our vcluster
+func shareRoleName(userName string) string {
+	return "devenv-share-" + userName
+}
+
+// isSharedWithUser returns true if a vcluster was shared with the current
+// loft user by its owner, i.e. the user was bound to its share role
+func (lr *LoftRuntime) isSharedWithUser(ctx context.Context, vc *managementv1.ClusterVirtualCluster) (bool, error) {
+	k, err := lr.getClusterClient(vc.Cluster)
+	if err != nil {
+		return false, err
+	}
+
+	userName := lr.loftUser.Status.User
+	rb, err := k.RbacV1().RoleBindings(vc.VirtualCluster.Namespace).Get(ctx, shareRoleName(userName), metav1.GetOptions{})
+	if err != nil {
+		// The owner of a vcluster has no share binding, and can't be
+		// forbidden from reading it
+		if kerrors.IsNotFound(err) || kerrors.IsForbidden(err) {
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "failed to determine owner of vcluster '%s'", vc.VirtualCluster.Name)
+	}
+
+	return rb.Labels[loftShareLabel] == userName, nil
+}
+
+// Share grants another loft user access to the vcluster of this
+// runtime. Like sharing a vcluster in the loft UI, the user is granted
+// access to the VirtualCluster object only, not to the space it's in.
+func (lr *LoftRuntime) Share(ctx context.Context, userName string) error { //nolint:funlen
+	if userName == lr.loftUser.Status.User {
+		return fmt.Errorf("can't share a vcluster with its owner")
+	}
+
+	if _, err := lr.loft.ManagementV1().Users().Get(ctx, userName, metav1.GetOptions{}); err != nil {
+		if kerrors.IsNotFound(err) {
+			return fmt.Errorf("loft user '%s' doesn't exist", userName)
+		}
+		return errors.Wrap(err, "failed to get loft user")
+	}
+
+	vc, err := lr.getVirtualCluster(ctx)
+	if err != nil {
+		return err
+	}
+
+	lc, err := lr.getClusterLoftClient(vc.Cluster)
+	if err != nil {
+		return err
+	}
+
+	// Get the VirtualCluster from the cluster to own the role and role
+	// binding, so that they're removed with it
+	obj, err := lc.StorageV1().VirtualClusters(vc.VirtualCluster.Namespace).
+		Get(ctx, vc.VirtualCluster.Name, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to get vcluster")
+	}
+
+	k, err := lr.getClusterClient(vc.Cluster)
+	if err != nil {
+		return err
+	}
+
+	meta := metav1.ObjectMeta{
+		Name:      shareRoleName(userName),
+		Namespace: obj.Namespace,
+		Labels: map[string]string{
+			loftShareLabel: userName,
+		},
+		OwnerReferences: []metav1.OwnerReference{
+			*metav1.NewControllerRef(obj, storagev1.SchemeGroupVersion.WithKind("VirtualCluster")),
+		},
+	}
+
+	role := &rbacv1.Role{
+		ObjectMeta: meta,
+		Rules: []rbacv1.PolicyRule{
+			{
+				APIGroups:     []string{storagev1.SchemeGroupVersion.Group},
+				Resources:     []string{"virtualclusters"},
+				ResourceNames: []string{obj.Name},
+				Verbs:         []string{"get", "use"},
+			},
+			{
+				// Allows the user to find out that the vcluster was
+				// shared with them, see isSharedWithUser
+				APIGroups:     []string{rbacv1.GroupName},
+				Resources:     []string{"rolebindings"},
+				ResourceNames: []string{meta.Name},
+				Verbs:         []string{"get"},
+			},
+		},
+	}
+	if _, err := k.RbacV1().Roles(role.Namespace).Create(ctx, role, metav1.CreateOptions{}); err != nil && //nolint:govet // Why: We're OK shadowing err
+		!kerrors.IsAlreadyExists(err) {
+		return errors.Wrap(err, "failed to create vcluster share role")
+	}
+
+	rb := &rbacv1.RoleBinding{
+		ObjectMeta: meta,
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "Role",
+			Name:     role.Name,
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				APIGroup: rbacv1.GroupName,
+				Kind:     rbacv1.UserKind,
+				Name:     "loft:user:" + userName,
+			},
+		},
+	}
+
+	_, err = k.RbacV1().RoleBindings(rb.Namespace).Create(ctx, rb, metav1.CreateOptions{})
+	if kerrors.IsAlreadyExists(err) {
+		lr.log.WithField("user", userName).Info("vcluster is already shared with user")
+		return nil
+	}
+	return errors.Wrap(err, "failed to grant access to vcluster")
+}
+
+// Unshare revokes access to the vcluster of this runtime from a loft
+// user it was previously shared with
+func (lr *LoftRuntime) Unshare(ctx context.Context, userName string) error {
+	vc, err := lr.getVirtualCluster(ctx)
+	if err != nil {
+		return err
+	}
+
+	k, err := lr.getClusterClient(vc.Cluster)
+	if err != nil {
+		return err
+	}
+
+	err = k.RbacV1().RoleBindings(vc.VirtualCluster.Namespace).
+		Delete(ctx, shareRoleName(userName), metav1.DeleteOptions{})
+	if kerrors.IsNotFound(err) {
+		return fmt.Errorf("vcluster isn't shared with '%s'", userName)
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to revoke access to vcluster")
+	}
+
+	err = k.RbacV1().Roles(vc.VirtualCluster.Namespace).Delete(ctx, shareRoleName(userName), metav1.DeleteOptions{})
+	if err != nil && !kerrors.IsNotFound(err) {
+		return errors.Wrap(err, "failed to delete vcluster share role")
+	}
+
+	return nil
+}
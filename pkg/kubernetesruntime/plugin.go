@@ -0,0 +1,344 @@
+package kubernetesruntime
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/getoutreach/devenv/cmd/devenv/status"
+	"github.com/getoutreach/devenv/pkg/cmdutil"
+	"github.com/getoutreach/gobox/pkg/box"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+const (
+	// PluginPrefix is the prefix of executables that are
+	// discovered as runtime plugins, e.g. devenv-runtime-minikube
+	PluginPrefix = "devenv-runtime-"
+
+	// PluginProtocolVersion is the version of the protocol
+	// spoken to runtime plugins.
+	PluginProtocolVersion = 1
+)
+
+// PluginMethod is a method of the Runtime interface that can
+// be invoked on a plugin
+type PluginMethod string
+
+const (
+	PluginMethodGetConfig     PluginMethod = "GetConfig"
+	PluginMethodStatus        PluginMethod = "Status"
+	PluginMethodCreate        PluginMethod = "Create"
+	PluginMethodDestroy       PluginMethod = "Destroy"
+	PluginMethodStart         PluginMethod = "Start"
+	PluginMethodStop          PluginMethod = "Stop"
+	PluginMethodPreCreate     PluginMethod = "PreCreate"
+	PluginMethodGetKubeConfig PluginMethod = "GetKubeConfig"
+	PluginMethodGetClusters   PluginMethod = "GetClusters"
+	PluginMethodLoadImage     PluginMethod = "LoadImage"
+)
+
+// PluginRequest is written, as JSON, to the stdin of a plugin
+// for every method invocation.
+type PluginRequest struct {
+	// Version is the version of the protocol, see PluginProtocolVersion
+	Version int `json:"version"`
+
+	// Method is the Runtime method being invoked
+	Method PluginMethod `json:"method"`
+
+	// Box is the box configuration devenv is using
+	Box *box.Config `json:"box,omitempty"`
+
+	// Image is the docker image to load, set for LoadImage
+	Image string `json:"image,omitempty"`
+}
+
+// PluginResponse is read, as JSON, from the stdout of a plugin
+// after a method was invoked. Only the field matching the invoked
+// method is read.
+type PluginResponse struct {
+	// Error, if set, denotes that the method failed
+	Error string `json:"error,omitempty"`
+
+	// Config is the response to GetConfig
+	Config *PluginRuntimeConfig `json:"config,omitempty"`
+
+	// Status is the response to Status
+	Status *PluginRuntimeStatus `json:"status,omitempty"`
+
+	// KubeConfig is the response to GetKubeConfig, it should be a
+	// serialized kubeconfig
+	KubeConfig string `json:"kubeconfig,omitempty"`
+
+	// Clusters is the response to GetClusters
+	Clusters []PluginRuntimeCluster `json:"clusters,omitempty"`
+}
+
+// PluginRuntimeConfig is the wire format of RuntimeConfig
+type PluginRuntimeConfig struct {
+	Name         string              `json:"name"`
+	Type         RuntimeType         `json:"type"`
+	ClusterName  string              `json:"clusterName"`
+	Capabilities RuntimeCapabilities `json:"capabilities"`
+}
+
+// PluginRuntimeStatus is the wire format of RuntimeStatus
+type PluginRuntimeStatus struct {
+	Status            string `json:"status"`
+	Reason            string `json:"reason,omitempty"`
+	KubernetesVersion string `json:"kubernetesVersion,omitempty"`
+	Version           string `json:"version,omitempty"`
+}
+
+// PluginRuntimeCluster is the wire format of RuntimeCluster
+type PluginRuntimeCluster struct {
+	Name       string `json:"name"`
+	KubeConfig string `json:"kubeconfig"`
+}
+
+// PluginRuntime is a runtime that is implemented by an external
+// executable which speaks JSON over stdio, see PluginRequest and
+// PluginResponse.
+type PluginRuntime struct {
+	log logrus.FieldLogger
+	box *box.Config
+
+	// name is the name of the plugin, this is the executable
+	// name without PluginPrefix
+	name string
+
+	// path is the path to the executable
+	path string
+
+	conf   *RuntimeConfig
+	confMu sync.Mutex
+}
+
+// NewPluginRuntime creates a new runtime backed by the plugin
+// executable at the given path
+func NewPluginRuntime(path string) *PluginRuntime {
+	return &PluginRuntime{
+		name: strings.TrimPrefix(filepath.Base(path), PluginPrefix),
+		path: path,
+		log:  logrus.New(),
+	}
+}
+
+// DiscoverPlugins finds all runtime plugins in the devenv dependency
+// directory and on the PATH. Plugins in the dependency directory take
+// precedence over those found on the PATH.
+func DiscoverPlugins() []*PluginRuntime {
+	dirs := make([]string, 0)
+	if depDir, err := cmdutil.GetDependencyDir(); err == nil {
+		dirs = append(dirs, depDir)
+	}
+	dirs = append(dirs, filepath.SplitList(os.Getenv("PATH"))...)
+
+	found := make(map[string]bool)
+	plugins := make([]*PluginRuntime, 0)
+	for _, dir := range dirs {
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, f := range files {
+			if f.IsDir() || !strings.HasPrefix(f.Name(), PluginPrefix) {
+				continue
+			}
+
+			info, err := f.Info()
+			if err != nil || info.Mode()&0111 == 0 {
+				// skip non-executable files
+				continue
+			}
+
+			p := NewPluginRuntime(filepath.Join(dir, f.Name()))
+			if found[p.name] {
+				continue
+			}
+			found[p.name] = true
+
+			plugins = append(plugins, p)
+		}
+	}
+
+	return plugins
+}
+
+// call invokes a method on the plugin and returns its response
+func (pr *PluginRuntime) call(ctx context.Context, method PluginMethod) (*PluginResponse, error) {
+	return pr.send(ctx, &PluginRequest{Method: method})
+}
+
+// send sends a request to the plugin and returns its response, the
+// protocol version and box configuration are always set
+func (pr *PluginRuntime) send(ctx context.Context, r *PluginRequest) (*PluginResponse, error) {
+	r.Version = PluginProtocolVersion
+	r.Box = pr.box
+
+	req, err := json.Marshal(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode plugin request")
+	}
+
+	stdout := &bytes.Buffer{}
+
+	//nolint:gosec // Why: We're executing discovered plugins
+	cmd := exec.CommandContext(ctx, pr.path)
+	cmd.Stdin = bytes.NewReader(req)
+	cmd.Stdout = stdout
+	// Plugins communicate with the user through stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil { //nolint:govet // Why: We're OK shadowing err
+		return nil, errors.Wrapf(err, "failed to run runtime plugin '%s'", pr.name)
+	}
+
+	var resp PluginResponse
+	if err := json.NewDecoder(stdout).Decode(&resp); err != nil { //nolint:govet // Why: We're OK shadowing err
+		return nil, errors.Wrapf(err, "failed to decode response from runtime plugin '%s'", pr.name)
+	}
+
+	if resp.Error != "" {
+		return &resp, fmt.Errorf("runtime plugin '%s' failed: %s", pr.name, resp.Error)
+	}
+
+	return &resp, nil
+}
+
+func (pr *PluginRuntime) Configure(log logrus.FieldLogger, conf *box.Config) {
+	pr.log = log
+	pr.box = conf
+}
+
+func (pr *PluginRuntime) GetConfig() RuntimeConfig {
+	pr.confMu.Lock()
+	defer pr.confMu.Unlock()
+
+	if pr.conf != nil {
+		return *pr.conf
+	}
+
+	// Ensure that we always return the plugin name, even
+	// if the plugin failed to respond
+	conf := RuntimeConfig{
+		Name: pr.name,
+		Type: RuntimeTypeRemote,
+	}
+
+	resp, err := pr.call(context.TODO(), PluginMethodGetConfig)
+	if err != nil || resp.Config == nil {
+		pr.log.WithError(err).WithField("plugin", pr.name).Debug("Failed to get runtime plugin configuration")
+
+		// Don't invoke a failing plugin again for every call
+		pr.conf = &conf
+		return conf
+	}
+
+	conf.ClusterName = resp.Config.ClusterName
+	conf.Capabilities = resp.Config.Capabilities
+	if resp.Config.Type != "" {
+		conf.Type = resp.Config.Type
+	}
+	pr.conf = &conf
+
+	return conf
+}
+
+func (pr *PluginRuntime) Status(ctx context.Context) RuntimeStatus {
+	resp := RuntimeStatus{status.Status{
+		Status: status.Unknown,
+	}}
+
+	presp, err := pr.call(ctx, PluginMethodStatus)
+	if err != nil {
+		resp.Reason = err.Error()
+		return resp
+	}
+
+	if presp.Status == nil {
+		resp.Reason = fmt.Sprintf("runtime plugin '%s' returned no status", pr.name)
+		return resp
+	}
+
+	resp.Status = status.Status{
+		Status:            presp.Status.Status,
+		Reason:            presp.Status.Reason,
+		KubernetesVersion: presp.Status.KubernetesVersion,
+		Version:           presp.Status.Version,
+	}
+	return resp
+}
+
+func (pr *PluginRuntime) Create(ctx context.Context) error {
+	_, err := pr.call(ctx, PluginMethodCreate)
+	return err
+}
+
+func (pr *PluginRuntime) Destroy(ctx context.Context) error {
+	_, err := pr.call(ctx, PluginMethodDestroy)
+	return err
+}
+
+func (pr *PluginRuntime) Start(ctx context.Context) error {
+	_, err := pr.call(ctx, PluginMethodStart)
+	return err
+}
+
+func (pr *PluginRuntime) Stop(ctx context.Context) error {
+	_, err := pr.call(ctx, PluginMethodStop)
+	return err
+}
+
+func (pr *PluginRuntime) PreCreate(ctx context.Context) error {
+	_, err := pr.call(ctx, PluginMethodPreCreate)
+	return err
+}
+
+func (pr *PluginRuntime) GetKubeConfig(ctx context.Context) (*api.Config, error) {
+	resp, err := pr.call(ctx, PluginMethodGetKubeConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	kubeconfig, err := clientcmd.Load([]byte(resp.KubeConfig))
+	return kubeconfig, errors.Wrapf(err, "failed to load kubeconfig from runtime plugin '%s'", pr.name)
+}
+
+func (pr *PluginRuntime) GetClusters(ctx context.Context) ([]*RuntimeCluster, error) {
+	resp, err := pr.call(ctx, PluginMethodGetClusters)
+	if err != nil {
+		return nil, err
+	}
+
+	rclusters := make([]*RuntimeCluster, len(resp.Clusters))
+	for i := range resp.Clusters {
+		kubeconfig, err := clientcmd.Load([]byte(resp.Clusters[i].KubeConfig))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load kubeconfig for cluster '%s'", resp.Clusters[i].Name)
+		}
+
+		rclusters[i] = &RuntimeCluster{
+			RuntimeName: pr.GetConfig().Name,
+			Name:        resp.Clusters[i].Name,
+			KubeConfig:  kubeconfig,
+		}
+	}
+
+	return rclusters, nil
+}
+
+func (pr *PluginRuntime) LoadImage(ctx context.Context, image string) error {
+	_, err := pr.send(ctx, &PluginRequest{Method: PluginMethodLoadImage, Image: image})
+	return err
+}
@@ -0,0 +1,201 @@
+package kubernetesruntime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/getoutreach/gobox/pkg/box"
+	"github.com/sirupsen/logrus"
+)
+
+// newFakePlugin writes a plugin to dir that saves the request it's sent
+// to request.json, writes response to stdout and exits with exitCode
+func newFakePlugin(t *testing.T, dir, response string, exitCode int) *PluginRuntime {
+	t.Helper()
+
+	responsePath := filepath.Join(dir, "response.json")
+	if err := ioutil.WriteFile(responsePath, []byte(response), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	script := fmt.Sprintf("#!/bin/sh\ncat > %q\ncat %q\nexit %d\n",
+		filepath.Join(dir, "request.json"), responsePath, exitCode)
+
+	path := filepath.Join(dir, PluginPrefix+"fake")
+	if err := ioutil.WriteFile(path, []byte(script), 0700); err != nil { //nolint:gosec // Why: It has to be executable
+		t.Fatal(err)
+	}
+
+	log := logrus.New()
+	log.Out = ioutil.Discard
+
+	pr := NewPluginRuntime(path)
+	pr.Configure(log, &box.Config{})
+	return pr
+}
+
+func TestPluginRuntime_call(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   PluginMethod
+		response string
+		exitCode int
+		want     *PluginResponse
+		wantErr  bool
+	}{
+		{
+			name:     "should decode the config",
+			method:   PluginMethodGetConfig,
+			response: `{"config":{"name":"fake","type":"local","clusterName":"dev","capabilities":{"canLoadImages":true}}}`,
+			want: &PluginResponse{Config: &PluginRuntimeConfig{
+				Name:         "fake",
+				Type:         RuntimeTypeLocal,
+				ClusterName:  "dev",
+				Capabilities: RuntimeCapabilities{CanLoadImages: true},
+			}},
+		},
+		{
+			name:     "should decode the status",
+			method:   PluginMethodStatus,
+			response: `{"status":{"status":"running","kubernetesVersion":"v1.21.3"}}`,
+			want: &PluginResponse{Status: &PluginRuntimeStatus{
+				Status:            "running",
+				KubernetesVersion: "v1.21.3",
+			}},
+		},
+		{
+			name:     "should decode an empty response",
+			method:   PluginMethodCreate,
+			response: `{}`,
+			want:     &PluginResponse{},
+		},
+		{
+			name:     "should fail when the plugin returns an error",
+			method:   PluginMethodCreate,
+			response: `{"error":"out of quota"}`,
+			wantErr:  true,
+		},
+		{
+			name:     "should fail when the plugin exits non-zero",
+			method:   PluginMethodDestroy,
+			response: `{}`,
+			exitCode: 1,
+			wantErr:  true,
+		},
+		{
+			name:     "should fail on malformed output",
+			method:   PluginMethodStatus,
+			response: `not json`,
+			wantErr:  true,
+		},
+		{
+			name:    "should fail on no output",
+			method:  PluginMethodStop,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			pr := newFakePlugin(t, dir, tt.response, tt.exitCode)
+
+			got, err := pr.call(context.Background(), tt.method)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PluginRuntime.call() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PluginRuntime.call() = %+v, want %+v", got, tt.want)
+			}
+
+			b, err := ioutil.ReadFile(filepath.Join(dir, "request.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var req PluginRequest
+			if err := json.Unmarshal(b, &req); err != nil {
+				t.Fatalf("failed to decode request %q: %v", b, err)
+			}
+
+			if req.Version != PluginProtocolVersion || req.Method != tt.method || req.Box == nil {
+				t.Errorf("PluginRuntime.call() sent %s", b)
+			}
+		})
+	}
+}
+
+func TestPluginRuntime_GetConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		exitCode int
+		want     RuntimeConfig
+	}{
+		{
+			name:     "should use the name of the executable",
+			response: `{"config":{"name":"other","type":"local","clusterName":"dev"}}`,
+			want:     RuntimeConfig{Name: "fake", Type: RuntimeTypeLocal, ClusterName: "dev"},
+		},
+		{
+			name:     "should default to a remote runtime",
+			response: `{"config":{"clusterName":"dev"}}`,
+			want:     RuntimeConfig{Name: "fake", Type: RuntimeTypeRemote, ClusterName: "dev"},
+		},
+		{
+			name:     "should fallback to the name of the executable when the plugin fails",
+			response: `{}`,
+			exitCode: 2,
+			want:     RuntimeConfig{Name: "fake", Type: RuntimeTypeRemote},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			pr := newFakePlugin(t, dir, tt.response, tt.exitCode)
+			if got := pr.GetConfig(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PluginRuntime.GetConfig() = %+v, want %+v", got, tt.want)
+			}
+
+			// The config, or its fallback, is only requested once
+			if err := os.Remove(filepath.Join(dir, "request.json")); err != nil {
+				t.Fatal(err)
+			}
+			if got := pr.GetConfig(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PluginRuntime.GetConfig() = %+v, want %+v", got, tt.want)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "request.json")); !os.IsNotExist(err) {
+				t.Errorf("PluginRuntime.GetConfig() invoked the plugin again")
+			}
+		})
+	}
+}
+
+func TestPluginRuntime_LoadImage(t *testing.T) {
+	dir := t.TempDir()
+	pr := newFakePlugin(t, dir, `{}`, 0)
+	if err := pr.LoadImage(context.Background(), "gcr.io/outreach-docker/flagship"); err != nil {
+		t.Fatalf("PluginRuntime.LoadImage() error = %v", err)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "request.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var req PluginRequest
+	if err := json.Unmarshal(b, &req); err != nil {
+		t.Fatalf("failed to decode request %q: %v", b, err)
+	}
+
+	if req.Method != PluginMethodLoadImage || req.Image != "gcr.io/outreach-docker/flagship" {
+		t.Errorf("PluginRuntime.LoadImage() sent %s", b)
+	}
+}
@@ -0,0 +1,370 @@
+// Package repocache implements a persistent cache of git repositories.
+// Each repository is stored as a bare clone that is updated with git
+// fetch, versions are checked out into worktrees.
+package repocache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/getoutreach/devenv/pkg/filelock"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultMaxAge is how long a repository can go unused before it's
+	// pruned
+	DefaultMaxAge = 30 * 24 * time.Hour
+
+	// DefaultMaxSize is the size, in bytes, the cache is pruned to
+	DefaultMaxSize int64 = 10 * 1024 * 1024 * 1024
+
+	// DefaultPruneInterval is how often PruneIfDue prunes the cache
+	DefaultPruneInterval = 24 * time.Hour
+
+	// lastPrunedFile is the file, in the cache dir, whose modification
+	// time is when the cache was last pruned
+	lastPrunedFile = ".last-pruned"
+
+	// staleWorktreeAge is the age after which a worktree is considered
+	// to be left over from a process that didn't clean up after itself
+	staleWorktreeAge = 24 * time.Hour
+)
+
+// CachePath is the path, relative to the user's home directory, of the
+// default cache
+var CachePath = filepath.Join(".outreach", ".cache", "dev-environment", "repositories") //nolint:gochecknoglobals
+
+// Cache is a cache of git repositories
+type Cache struct {
+	dir string
+}
+
+// New returns a cache stored in dir
+func New(dir string) *Cache {
+	return &Cache{dir: dir}
+}
+
+// NewDefault returns the cache stored in the user's home directory,
+// see CachePath
+func NewDefault() (*Cache, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read user's home dir")
+	}
+
+	return New(filepath.Join(homeDir, CachePath)), nil
+}
+
+// Dir returns the directory the cache is stored in
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// repoDir returns the directory a repository is stored in
+func (c *Cache) repoDir(name string) string {
+	return filepath.Join(c.dir, name)
+}
+
+// Checkout ensures that a repository is cached, and checks out version
+// into a new worktree. If version is empty, the default branch is checked
+// out. The repository is only fetched if version isn't already cached or
+// could have moved, e.g. a branch. If fetching fails, e.g. when offline,
+// the cached version is used. The returned function removes the worktree
+// and must always be called.
+func (c *Cache) Checkout(ctx context.Context, log logrus.FieldLogger, url, name, version string) (path string, cleanup func(), err error) {
+	cleanup = func() {}
+	repoDir := c.repoDir(name)
+	bareDir := filepath.Join(repoDir, "repo.git")
+
+	unlock, err := filelock.Lock(lockPath(repoDir))
+	if err != nil {
+		return "", cleanup, err
+	}
+	defer unlock()
+
+	if _, err := os.Stat(bareDir); os.IsNotExist(err) { //nolint:govet // Why: We're OK shadowing err
+		log.Info("Cloning repository into cache")
+		if err := c.clone(ctx, url, bareDir); err != nil {
+			return "", cleanup, err
+		}
+	} else if version == "" || !isImmutable(ctx, bareDir, version) {
+		log.Info("Fetching repository")
+		if _, err := git(ctx, bareDir, "fetch", "--prune", "--tags", "origin"); err != nil {
+			log.WithError(err).Warn("Failed to fetch repository, using cached version")
+		}
+	}
+
+	rev := "HEAD"
+	if version != "" {
+		rev = version
+	}
+
+	commit, err := git(ctx, bareDir, "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	if err != nil {
+		return "", cleanup, fmt.Errorf("version '%s' not found in repository %s", rev, name)
+	}
+
+	path = filepath.Join(repoDir, "worktrees", time.Now().Format("20060102T150405.000000000"))
+	if _, err := git(ctx, bareDir, "worktree", "add", "--detach", path, commit); err != nil { //nolint:govet // Why: We're OK shadowing err
+		return "", cleanup, errors.Wrap(err, "failed to create worktree")
+	}
+
+	// Mark the repository as recently used, see Prune
+	now := time.Now()
+	os.Chtimes(repoDir, now, now) //nolint:errcheck // Why: Only used for pruning
+
+	cleanup = func() {
+		unlock, err := filelock.Lock(lockPath(repoDir))
+		if err != nil {
+			return
+		}
+		defer unlock()
+
+		os.RemoveAll(path)                                      //nolint:errcheck // Why: Best effort
+		git(context.Background(), bareDir, "worktree", "prune") //nolint:errcheck // Why: Best effort
+	}
+
+	return path, cleanup, nil
+}
+
+// clone creates a bare clone of a repository that tracks all of its
+// branches
+func (c *Cache) clone(ctx context.Context, url, bareDir string) error {
+	tmpDir := bareDir + ".tmp"
+	os.RemoveAll(tmpDir) //nolint:errcheck // Why: Left over from a failed clone
+
+	if _, err := git(ctx, "", "clone", "--bare", url, tmpDir); err != nil {
+		os.RemoveAll(tmpDir) //nolint:errcheck // Why: Best effort
+		return errors.Wrap(err, "failed to clone repository")
+	}
+
+	// Bare clones don't fetch branches by default
+	if _, err := git(ctx, tmpDir, "config", "remote.origin.fetch", "+refs/heads/*:refs/heads/*"); err != nil {
+		os.RemoveAll(tmpDir) //nolint:errcheck // Why: Best effort
+		return errors.Wrap(err, "failed to configure repository")
+	}
+
+	return errors.Wrap(os.Rename(tmpDir, bareDir), "failed to move repository into cache")
+}
+
+// isImmutable returns true if version is a tag or commit that's already
+// cached, these don't change so fetching can be skipped
+func isImmutable(ctx context.Context, bareDir, version string) bool {
+	if _, err := git(ctx, bareDir, "rev-parse", "--verify", "--quiet", "refs/tags/"+version); err == nil {
+		return true
+	}
+
+	commit, err := git(ctx, bareDir, "rev-parse", "--verify", "--quiet", version+"^{commit}")
+	return err == nil && strings.HasPrefix(commit, strings.ToLower(version))
+}
+
+// Entry is a repository in the cache
+type Entry struct {
+	// Name is the name of the repository
+	Name string
+
+	// Size is the size of the repository on disk, in bytes
+	Size int64
+
+	// LastUsed is when the repository was last checked out
+	LastUsed time.Time
+}
+
+// List returns all repositories in the cache, least recently used first
+func (c *Cache) List() ([]Entry, error) {
+	files, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to read cache")
+	}
+
+	entries := make([]Entry, 0, len(files))
+	for _, f := range files {
+		if !f.IsDir() {
+			continue
+		}
+
+		size, err := dirSize(filepath.Join(c.dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, Entry{Name: f.Name(), Size: size, LastUsed: f.ModTime()})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].LastUsed.Before(entries[j].LastUsed)
+	})
+
+	return entries, nil
+}
+
+// Prune removes repositories that haven't been used for maxAge, and then
+// the least recently used repositories until the cache is smaller than
+// maxSize. Repositories that are checked out are never removed. A zero
+// maxAge or maxSize disables that limit. The removed repositories are
+// returned.
+func (c *Cache) Prune(maxAge time.Duration, maxSize int64) ([]Entry, error) {
+	entries, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	for i := range entries {
+		total += entries[i].Size
+	}
+
+	removed := make([]Entry, 0)
+	for i := range entries {
+		e := &entries[i]
+
+		expired := maxAge != 0 && time.Since(e.LastUsed) > maxAge
+		tooLarge := maxSize != 0 && total > maxSize
+		if !expired && !tooLarge {
+			continue
+		}
+
+		ok, err := c.remove(e.Name, false)
+		if err != nil {
+			return removed, err
+		}
+		if !ok {
+			continue
+		}
+
+		total -= e.Size
+		removed = append(removed, *e)
+	}
+
+	return removed, c.markPruned()
+}
+
+// PruneIfDue prunes the cache, see Prune, unless it was already pruned
+// within interval
+func (c *Cache) PruneIfDue(interval, maxAge time.Duration, maxSize int64) ([]Entry, error) {
+	info, err := os.Stat(filepath.Join(c.dir, lastPrunedFile))
+	if err == nil && time.Since(info.ModTime()) < interval {
+		return nil, nil
+	}
+
+	return c.Prune(maxAge, maxSize)
+}
+
+// markPruned records that the cache was just pruned, see PruneIfDue
+func (c *Cache) markPruned() error {
+	err := ioutil.WriteFile(filepath.Join(c.dir, lastPrunedFile), nil, 0600)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to record when the cache was pruned")
+	}
+
+	return nil
+}
+
+// Clean removes all repositories from the cache, even if they're
+// checked out
+func (c *Cache) Clean() error {
+	entries, err := c.List()
+	if err != nil {
+		return err
+	}
+
+	for i := range entries {
+		if _, err := c.remove(entries[i].Name, true); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// remove removes a repository from the cache. Unless force is set,
+// repositories that are in use are skipped, in which case false is
+// returned.
+func (c *Cache) remove(name string, force bool) (bool, error) {
+	repoDir := c.repoDir(name)
+
+	if !force {
+		unlock, ok, err := filelock.TryLock(lockPath(repoDir))
+		if err != nil || !ok {
+			return false, err
+		}
+		defer unlock()
+
+		if inUse(repoDir) {
+			return false, nil
+		}
+	}
+
+	return true, errors.Wrapf(os.RemoveAll(repoDir), "failed to remove repository %s", name)
+}
+
+// inUse returns true if a repository has worktrees that aren't stale
+func inUse(repoDir string) bool {
+	worktrees, err := ioutil.ReadDir(filepath.Join(repoDir, "worktrees"))
+	if err != nil {
+		return false
+	}
+
+	for _, wt := range worktrees {
+		if time.Since(wt.ModTime()) < staleWorktreeAge {
+			return true
+		}
+	}
+
+	return false
+}
+
+// dirSize returns the size of all files in a directory
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			// Files can be removed while walking, e.g. by git
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+
+	return size, errors.Wrap(err, "failed to calculate size of cache")
+}
+
+// git runs a git command in dir and returns its trimmed output
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	b, err := cmd.Output()
+	if err != nil {
+		return "", errors.Wrapf(err, "git %s: %s", args[0], strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
+
+// lockPath returns the path of the lock file of a repository
+func lockPath(repoDir string) string {
+	return filepath.Join(repoDir, ".lock")
+}
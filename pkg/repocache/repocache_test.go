@@ -0,0 +1,213 @@
+package repocache
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// newOrigin creates a repository with a v1.0.0 tag and a newer commit on
+// its default branch
+func newOrigin(t *testing.T, dir string) string {
+	origin := filepath.Join(dir, "origin")
+	run := func(args ...string) {
+		if _, err := git(context.Background(), origin, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	commit := func(version string) {
+		if err := ioutil.WriteFile(filepath.Join(origin, "VERSION"), []byte(version), 0600); err != nil {
+			t.Fatal(err)
+		}
+		run("add", "VERSION")
+		run("-c", "user.name=devenv", "-c", "user.email=devenv@example.com", "commit", "-m", version)
+	}
+
+	if err := os.MkdirAll(origin, 0755); err != nil {
+		t.Fatal(err)
+	}
+	run("init")
+	commit("v1.0.0")
+	run("tag", "v1.0.0")
+	commit("v1.1.0")
+
+	return origin
+}
+
+func TestCache_Checkout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "devenv-repocache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	origin := newOrigin(t, dir)
+	c := New(filepath.Join(dir, "cache"))
+	log := logrus.New()
+
+	tests := []struct {
+		name        string
+		version     string
+		removeRepo  bool
+		wantVersion string
+		wantErr     bool
+	}{
+		{
+			name:        "should clone the default branch",
+			wantVersion: "v1.1.0",
+		},
+		{
+			name:        "should checkout a tag",
+			version:     "v1.0.0",
+			wantVersion: "v1.0.0",
+		},
+		{
+			name:        "should use cached versions when the repository is unavailable",
+			version:     "v1.0.0",
+			removeRepo:  true,
+			wantVersion: "v1.0.0",
+		},
+		{
+			name:        "should use the cached default branch when the repository is unavailable",
+			removeRepo:  true,
+			wantVersion: "v1.1.0",
+		},
+		{
+			name:       "should fail for versions that aren't cached",
+			version:    "v2.0.0",
+			removeRepo: true,
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.removeRepo {
+				if err := os.RemoveAll(origin); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			path, cleanup, err := c.Checkout(context.Background(), log, origin, "app", tt.version)
+			defer cleanup()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Cache.Checkout() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			b, err := ioutil.ReadFile(filepath.Join(path, "VERSION"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := string(b); got != tt.wantVersion {
+				t.Errorf("Cache.Checkout() checked out %v, want %v", got, tt.wantVersion)
+			}
+
+			cleanup()
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Errorf("Cache.Checkout() cleanup didn't remove worktree %s", path)
+			}
+		})
+	}
+}
+
+func TestCache_Prune(t *testing.T) {
+	dir, err := ioutil.TempDir("", "devenv-repocache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	origin := newOrigin(t, dir)
+	c := New(filepath.Join(dir, "cache"))
+	log := logrus.New()
+
+	for _, name := range []string{"old", "new", "checked-out"} {
+		_, cleanup, err := c.Checkout(context.Background(), log, origin, name, "") //nolint:govet // Why: We're OK shadowing err
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if name != "checked-out" {
+			cleanup()
+		}
+	}
+
+	old := time.Now().Add(-2 * DefaultMaxAge)
+	for _, name := range []string{"old", "checked-out"} {
+		if err := os.Chtimes(filepath.Join(c.Dir(), name), old, old); err != nil { //nolint:govet // Why: We're OK shadowing err
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := c.Prune(DefaultMaxAge, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(removed) != 1 || removed[0].Name != "old" {
+		t.Errorf("Cache.Prune() removed %v, want only old", removed)
+	}
+
+	entries, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 2 {
+		t.Errorf("Cache.List() = %v, want checked-out and new", entries)
+	}
+
+	if err := c.Clean(); err != nil {
+		t.Fatal(err)
+	}
+
+	if entries, err := c.List(); err != nil || len(entries) != 0 { //nolint:govet // Why: We're OK shadowing err
+		t.Errorf("Cache.List() = %v, %v after Clean, want nothing", entries, err)
+	}
+}
+
+func TestCache_PruneIfDue(t *testing.T) {
+	dir := t.TempDir()
+	c := New(dir)
+
+	if err := os.MkdirAll(filepath.Join(dir, "old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	old := time.Now().Add(-2 * DefaultMaxAge)
+	if err := os.Chtimes(filepath.Join(dir, "old"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, lastPrunedFile), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if removed, err := c.PruneIfDue(DefaultPruneInterval, DefaultMaxAge, 0); err != nil || len(removed) != 0 {
+		t.Errorf("Cache.PruneIfDue() = %v, %v, want nothing pruned within the interval", removed, err)
+	}
+
+	pruned := time.Now().Add(-2 * DefaultPruneInterval)
+	if err := os.Chtimes(filepath.Join(dir, lastPrunedFile), pruned, pruned); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := c.PruneIfDue(DefaultPruneInterval, DefaultMaxAge, 0)
+	if err != nil || len(removed) != 1 || removed[0].Name != "old" {
+		t.Errorf("Cache.PruneIfDue() = %v, %v, want old pruned after the interval", removed, err)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, lastPrunedFile))
+	if err != nil || time.Since(info.ModTime()) > time.Minute {
+		t.Errorf("Cache.PruneIfDue() didn't record when the cache was pruned")
+	}
+}